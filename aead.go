@@ -0,0 +1,232 @@
+package crop
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// AEADType identifies an authenticated encryption algorithm.
+type AEADType string
+
+const (
+	// AEADTypeChaCha20Poly1305 uses ChaCha20-Poly1305 (RFC 8439).
+	AEADTypeChaCha20Poly1305 AEADType = "ChaCha20-Poly1305"
+)
+
+// AllAEADTypes returns all supported AEAD types.
+func AllAEADTypes() []AEADType {
+	return []AEADType{
+		AEADTypeChaCha20Poly1305,
+	}
+}
+
+// IsValid returns whether this AEAD type is supported.
+func (at AEADType) IsValid() bool {
+	switch at {
+	case AEADTypeChaCha20Poly1305:
+		return true
+	}
+	return false
+}
+
+// IsFIPSApproved returns whether this AEAD type is FIPS-approved.
+func (at AEADType) IsFIPSApproved() bool {
+	return false
+}
+
+// KeySize returns the key size required by this AEAD type.
+func (at AEADType) KeySize() int {
+	switch at {
+	case AEADTypeChaCha20Poly1305:
+		return chacha20poly1305.KeySize
+	default:
+		return 0
+	}
+}
+
+// NewAEAD creates a new AEAD with separate keys for sealing and opening.
+// Both keys are derived from the key maker with the given context and
+// the respective party. The peer must use the same context and swapped parties.
+// The sequence checker provides nonces for sealing and replay protection for
+// opening and must not be shared with another AEAD using the same keys.
+func NewAEAD(at AEADType, km KeyMaker, keyContext, sealParty, openParty string, seqChecker SequenceChecker) (AEAD, error) {
+	return at.New(km, keyContext, sealParty, openParty, seqChecker)
+}
+
+func (at AEADType) New(km KeyMaker, keyContext, sealParty, openParty string, seqChecker SequenceChecker) (AEAD, error) {
+	if !at.IsValid() {
+		return nil, fmt.Errorf("invalid AEAD type: %q", at)
+	}
+	if sealParty == openParty {
+		return nil, fmt.Errorf("seal and open party must differ")
+	}
+
+	// Derive keys.
+	keys, err := km.DeriveKeys(keyContext, []KeySpec{
+		{Party: sealParty, Length: at.KeySize()},
+		{Party: openParty, Length: at.KeySize()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("derive AEAD keys: %w", err)
+	}
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	defer clear(keys[0])
+	defer clear(keys[1])
+
+	// Create AEAD based on type.
+	switch at {
+	case AEADTypeChaCha20Poly1305:
+		sealer, err := chacha20poly1305.New(keys[0])
+		if err != nil {
+			return nil, err
+		}
+		opener, err := chacha20poly1305.New(keys[1])
+		if err != nil {
+			return nil, err
+		}
+		return &ChaCha20Poly1305{
+			seqChecker: seqChecker,
+			sealer:     sealer,
+			opener:     opener,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("AEAD type %s not yet implemented", at)
+	}
+}
+
+func (at AEADType) String() string {
+	return string(at)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (at AEADType) MarshalText() ([]byte, error) {
+	return []byte(at), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unknown names are rejected with ErrInvalidFormat.
+func (at *AEADType) UnmarshalText(text []byte) error {
+	parsed := AEADType(text)
+	if !parsed.IsValid() {
+		return fmt.Errorf("%w: unknown AEAD type %q", ErrInvalidFormat, text)
+	}
+	*at = parsed
+	return nil
+}
+
+// AEAD encrypts and authenticates messages.
+type AEAD interface {
+	// Type returns the AEAD algorithm type.
+	Type() AEADType
+	// Seal encrypts and authenticates the plaintext and authenticates the
+	// additional data. The returned ciphertext includes the sequence number.
+	// Returns nil if the AEAD is burned or the nonce would be reused.
+	Seal(plaintext, aad []byte) (ciphertext []byte)
+	// Open authenticates and decrypts the ciphertext and authenticates the
+	// additional data. The sequence number is checked after authentication.
+	Open(ciphertext, aad []byte) ([]byte, error)
+	// Burn securely erases key material from memory.
+	Burn()
+}
+
+// ChaCha20Poly1305 implements AEAD using ChaCha20-Poly1305.
+// The nonce is built from the sequence number, which is sent in front of the
+// ciphertext. Format: [uvarint seq][ciphertext][tag].
+type ChaCha20Poly1305 struct {
+	seqChecker SequenceChecker
+
+	sealLock sync.Mutex
+	sealer   cipher.AEAD
+	sealSeq  uint64
+
+	openLock sync.RWMutex
+	opener   cipher.AEAD
+}
+
+func (cp *ChaCha20Poly1305) Type() AEADType {
+	return AEADTypeChaCha20Poly1305
+}
+
+func (cp *ChaCha20Poly1305) Seal(plaintext, aad []byte) (ciphertext []byte) {
+	cp.sealLock.Lock()
+	defer cp.sealLock.Unlock()
+
+	// Check if burned.
+	if cp.sealer == nil {
+		return nil
+	}
+
+	// Get sequence number and never reuse a nonce.
+	// Sequence numbers must strictly increase, which may be violated if the
+	// sequence checker is reset or shared.
+	sequence := cp.seqChecker.NextOutSequence()
+	if sequence <= cp.sealSeq {
+		return nil
+	}
+	cp.sealSeq = sequence
+
+	// Add sequence number and seal.
+	ciphertext = make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(plaintext)+cp.sealer.Overhead())
+	size := binary.PutUvarint(ciphertext, sequence)
+	nonce := aeadNonce(sequence)
+	return cp.sealer.Seal(ciphertext[:size], nonce[:], plaintext, aad)
+}
+
+func (cp *ChaCha20Poly1305) Open(ciphertext, aad []byte) ([]byte, error) {
+	cp.openLock.RLock()
+	defer cp.openLock.RUnlock()
+
+	// Check if burned.
+	if cp.opener == nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, ErrBurned)
+	}
+
+	// Extract sequence number (validated after authentication).
+	seqNum, seqSize := binary.Uvarint(ciphertext)
+	switch {
+	case seqSize == 0:
+		return nil, fmt.Errorf("%w: too short", ErrDecryptionFailed)
+	case seqSize < 0:
+		return nil, fmt.Errorf("%w: sequence overflow", ErrDecryptionFailed)
+	}
+
+	// Authenticate and decrypt.
+	nonce := aeadNonce(seqNum)
+	plaintext, err := cp.opener.Open(nil, nonce[:], ciphertext[seqSize:], aad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	// Check sequence number.
+	if !cp.seqChecker.CheckInSequence(seqNum) {
+		clear(plaintext)
+		return nil, fmt.Errorf("%w: sequence violation", ErrDecryptionFailed)
+	}
+
+	return plaintext, nil
+}
+
+// Burn disables the AEAD. The underlying cipher does not expose its key
+// schedule, so it is dropped for garbage collection.
+// Afterwards, Seal returns nil and Open fails with ErrBurned.
+func (cp *ChaCha20Poly1305) Burn() {
+	cp.sealLock.Lock()
+	defer cp.sealLock.Unlock()
+	cp.openLock.Lock()
+	defer cp.openLock.Unlock()
+
+	cp.sealer = nil
+	cp.opener = nil
+	cp.seqChecker = nil
+}
+
+// aeadNonce returns the nonce for the given sequence number.
+func aeadNonce(sequence uint64) (nonce [chacha20poly1305.NonceSize]byte) {
+	binary.BigEndian.PutUint64(nonce[chacha20poly1305.NonceSize-8:], sequence)
+	return nonce
+}
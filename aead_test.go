@@ -0,0 +1,112 @@
+package crop
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newTestAEADPair(t *testing.T, at AEADType) (a, b AEAD) {
+	t.Helper()
+
+	km, err := NewKeyMaker(KeyMakerTypeBlake3, NewSecret(32))
+	if err != nil {
+		t.Fatalf("create key maker: %v", err)
+	}
+	a, err = NewAEAD(at, km, "aead test", "a", "b", NewStrictSequenceChecker())
+	if err != nil {
+		t.Fatalf("create AEAD a: %v", err)
+	}
+	b, err = NewAEAD(at, km, "aead test", "b", "a", NewStrictSequenceChecker())
+	if err != nil {
+		t.Fatalf("create AEAD b: %v", err)
+	}
+	return a, b
+}
+
+func TestAEAD(t *testing.T) {
+	t.Parallel()
+
+	for _, at := range AllAEADTypes() {
+		a, b := newTestAEADPair(t, at)
+		plaintext := []byte("hello world")
+		aad := []byte("header")
+
+		// Round trip in both directions.
+		ciphertext := a.Seal(plaintext, aad)
+		if bytes.Contains(ciphertext, plaintext) {
+			t.Fatalf("%s: ciphertext contains plaintext", at)
+		}
+		opened, err := b.Open(ciphertext, aad)
+		if err != nil {
+			t.Fatalf("%s: open: %v", at, err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Fatalf("%s: opened %q, want %q", at, opened, plaintext)
+		}
+		if _, err := a.Open(b.Seal(plaintext, aad), aad); err != nil {
+			t.Fatalf("%s: open reverse: %v", at, err)
+		}
+
+		// Replay is rejected.
+		if _, err := b.Open(ciphertext, aad); !errors.Is(err, ErrDecryptionFailed) {
+			t.Fatalf("%s: expected replay to fail, got: %v", at, err)
+		}
+
+		// Own messages cannot be opened.
+		if _, err := a.Open(a.Seal(plaintext, aad), aad); !errors.Is(err, ErrDecryptionFailed) {
+			t.Fatalf("%s: expected own message to fail, got: %v", at, err)
+		}
+
+		// Wrong additional data and tampering are rejected.
+		ciphertext = a.Seal(plaintext, aad)
+		if _, err := b.Open(ciphertext, []byte("other")); !errors.Is(err, ErrDecryptionFailed) {
+			t.Fatalf("%s: expected wrong aad to fail, got: %v", at, err)
+		}
+		ciphertext[len(ciphertext)-1] ^= 1
+		if _, err := b.Open(ciphertext, aad); !errors.Is(err, ErrDecryptionFailed) {
+			t.Fatalf("%s: expected tampered ciphertext to fail, got: %v", at, err)
+		}
+		if _, err := b.Open(nil, aad); !errors.Is(err, ErrDecryptionFailed) {
+			t.Fatalf("%s: expected empty ciphertext to fail, got: %v", at, err)
+		}
+
+		// Burned AEAD refuses to work.
+		a.Burn()
+		if a.Seal(plaintext, aad) != nil {
+			t.Fatalf("%s: expected burned AEAD to not seal", at)
+		}
+		if _, err := a.Open(b.Seal(plaintext, aad), aad); !errors.Is(err, ErrBurned) {
+			t.Fatalf("%s: expected ErrBurned, got: %v", at, err)
+		}
+	}
+}
+
+func TestAEAD_NonceReuse(t *testing.T) {
+	t.Parallel()
+
+	km, err := NewKeyMaker(KeyMakerTypeBlake3, NewSecret(32))
+	if err != nil {
+		t.Fatalf("create key maker: %v", err)
+	}
+	seqChecker := NewStrictSequenceChecker()
+	a, err := NewAEAD(AEADTypeChaCha20Poly1305, km, "aead test", "a", "b", seqChecker)
+	if err != nil {
+		t.Fatalf("create AEAD: %v", err)
+	}
+
+	if a.Seal([]byte("first"), nil) == nil {
+		t.Fatal("expected first seal to succeed")
+	}
+
+	// Resetting the sequence checker would reuse the nonce.
+	seqChecker.Reset()
+	if a.Seal([]byte("second"), nil) != nil {
+		t.Fatal("expected seal with reused sequence number to fail")
+	}
+
+	// Same party for both directions is rejected.
+	if _, err := NewAEAD(AEADTypeChaCha20Poly1305, km, "aead test", "a", "a", seqChecker); err == nil {
+		t.Fatal("expected error for same seal and open party")
+	}
+}
@@ -0,0 +1,52 @@
+package crop
+
+// Version is the version of this package.
+const Version = "0.1.0"
+
+// Capabilities lists the package version and all supported algorithms.
+type Capabilities struct {
+	Version      string          `json:"version"`
+	KeyExchanges []AlgorithmInfo `json:"keyExchanges"`
+	KeyMakers    []AlgorithmInfo `json:"keyMakers"`
+	KeyPairs     []AlgorithmInfo `json:"keyPairs"`
+	Hashes       []AlgorithmInfo `json:"hashes"`
+	Challenges   []AlgorithmInfo `json:"challenges"`
+	MsgAuthCodes []AlgorithmInfo `json:"msgAuthCodes"`
+	AEADs        []AlgorithmInfo `json:"aeads"`
+}
+
+// AlgorithmInfo describes a supported algorithm.
+type AlgorithmInfo struct {
+	Name         string `json:"name"`
+	FIPSApproved bool   `json:"fipsApproved"`
+}
+
+type algorithm interface {
+	String() string
+	IsFIPSApproved() bool
+}
+
+// GetCapabilities returns the package version and all supported algorithms.
+func GetCapabilities() Capabilities {
+	return Capabilities{
+		Version:      Version,
+		KeyExchanges: algorithmInfos(AllKeyExchangeTypes()),
+		KeyMakers:    algorithmInfos(AllKeyMakerTypes()),
+		KeyPairs:     algorithmInfos(AllKeyPairTypes()),
+		Hashes:       algorithmInfos(AllHashes()),
+		Challenges:   algorithmInfos(AllChallengeTypes()),
+		MsgAuthCodes: algorithmInfos(AllMsgAuthCodeTypes()),
+		AEADs:        algorithmInfos(AllAEADTypes()),
+	}
+}
+
+func algorithmInfos[T algorithm](algs []T) []AlgorithmInfo {
+	infos := make([]AlgorithmInfo, 0, len(algs))
+	for _, alg := range algs {
+		infos = append(infos, AlgorithmInfo{
+			Name:         alg.String(),
+			FIPSApproved: alg.IsFIPSApproved(),
+		})
+	}
+	return infos
+}
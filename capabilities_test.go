@@ -0,0 +1,58 @@
+package crop
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	t.Parallel()
+
+	caps := GetCapabilities()
+	if caps.Version != Version {
+		t.Fatalf("Version = %q, want %q", caps.Version, Version)
+	}
+
+	// Check that every supported algorithm is listed.
+	checkListed(t, "key exchange", caps.KeyExchanges, AllKeyExchangeTypes())
+	checkListed(t, "key maker", caps.KeyMakers, AllKeyMakerTypes())
+	checkListed(t, "key pair", caps.KeyPairs, AllKeyPairTypes())
+	checkListed(t, "hash", caps.Hashes, AllHashes())
+	checkListed(t, "challenge", caps.Challenges, AllChallengeTypes())
+	checkListed(t, "mac", caps.MsgAuthCodes, AllMsgAuthCodeTypes())
+	checkListed(t, "aead", caps.AEADs, AllAEADTypes())
+
+	// Check that the capabilities marshal to JSON.
+	data, err := json.Marshal(caps)
+	if err != nil {
+		t.Fatalf("marshal capabilities: %v", err)
+	}
+	var parsed Capabilities
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("unmarshal capabilities: %v", err)
+	}
+	if len(parsed.Hashes) != len(caps.Hashes) {
+		t.Fatalf("parsed hashes = %d, want %d", len(parsed.Hashes), len(caps.Hashes))
+	}
+}
+
+func checkListed[T algorithm](t *testing.T, family string, infos []AlgorithmInfo, algs []T) {
+	t.Helper()
+
+	if len(algs) == 0 {
+		t.Fatalf("no supported %s algorithms", family)
+	}
+	for _, alg := range algs {
+		idx := slices.IndexFunc(infos, func(info AlgorithmInfo) bool {
+			return info.Name == alg.String()
+		})
+		if idx < 0 {
+			t.Errorf("%s %s missing from capabilities", family, alg)
+			continue
+		}
+		if infos[idx].FIPSApproved != alg.IsFIPSApproved() {
+			t.Errorf("%s %s has wrong FIPS approval", family, alg)
+		}
+	}
+}
@@ -2,7 +2,18 @@ package crop
 
 import (
 	"crypto/subtle"
+	"encoding/binary"
 	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	// defaultChallengeSize is the default size of challenge data.
+	defaultChallengeSize = 32
+	// challengeExpirySize is the size of the expiry timestamp in responses.
+	challengeExpirySize = 8
 )
 
 // ChallengeType identifies a challenge-response authentication algorithm.
@@ -11,23 +22,57 @@ type ChallengeType string
 const (
 	// ChallengeTypeContextHashBl3 uses context-bound hashing with BLAKE3.
 	ChallengeTypeContextHashBl3 ChallengeType = "context-hash-bl3"
+	// ChallengeTypeSignature proves possession of a private key by signing
+	// the challenge. Create with NewSignatureChallenge.
+	ChallengeTypeSignature ChallengeType = "signature"
+	// ChallengeTypeMutualContextHashBl3 authenticates both peers in a single
+	// round trip using context-bound hashing with BLAKE3. See MutualChallenge.
+	ChallengeTypeMutualContextHashBl3 ChallengeType = "mutual-context-hash-bl3"
 )
 
+// AllChallengeTypes returns all supported challenge types.
+func AllChallengeTypes() []ChallengeType {
+	return []ChallengeType{
+		ChallengeTypeContextHashBl3,
+		ChallengeTypeSignature,
+		ChallengeTypeMutualContextHashBl3,
+	}
+}
+
 // IsValid returns whether this challenge type is supported.
 func (ct ChallengeType) IsValid() bool {
 	switch ct {
 	case ChallengeTypeContextHashBl3:
 		return true
+	case ChallengeTypeSignature:
+		return true
+	case ChallengeTypeMutualContextHashBl3:
+		return true
 	}
 	return false
 }
 
+// IsFIPSApproved returns whether this challenge type only uses FIPS-approved algorithms.
+func (ct ChallengeType) IsFIPSApproved() bool {
+	return false
+}
+
 // NewChallenge creates a new challenge for authentication.
 func NewChallenge(ct ChallengeType, purpose, requesterContext, responderContext string) (Challenge, error) {
 	return ct.New(purpose, requesterContext, responderContext)
 }
 
+// NewChallengeWithSize creates a new challenge for authentication with the
+// given challenge size in bytes. The size is raised to the minimum of 32 bytes.
+func NewChallengeWithSize(ct ChallengeType, size int, purpose, requesterContext, responderContext string) (Challenge, error) {
+	return ct.NewWithSize(size, purpose, requesterContext, responderContext)
+}
+
 func (ct ChallengeType) New(purpose, requesterContext, responderContext string) (Challenge, error) {
+	return ct.NewWithSize(defaultChallengeSize, purpose, requesterContext, responderContext)
+}
+
+func (ct ChallengeType) NewWithSize(size int, purpose, requesterContext, responderContext string) (Challenge, error) {
 	if !ct.IsValid() {
 		return nil, fmt.Errorf("invalid challenge type: %q", ct)
 	}
@@ -38,7 +83,19 @@ func (ct ChallengeType) New(purpose, requesterContext, responderContext string)
 		return &HashedContextChallenge{
 			challengeType:    ChallengeTypeContextHashBl3,
 			hash:             BLAKE3,
-			challengeData:    NewSecret(32),
+			challengeData:    NewSecretValue(size),
+			purpose:          purpose,
+			requesterContext: requesterContext,
+			responderContext: responderContext,
+		}, nil
+
+	case ChallengeTypeSignature:
+		return nil, fmt.Errorf("challenge type %s requires key pairs, use NewSignatureChallenge", ct)
+
+	case ChallengeTypeMutualContextHashBl3:
+		return &MutualChallenge{
+			hash:             BLAKE3,
+			challengeData:    NewSecretValue(size),
 			purpose:          purpose,
 			requesterContext: requesterContext,
 			responderContext: responderContext,
@@ -53,6 +110,22 @@ func (ct ChallengeType) String() string {
 	return string(ct)
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (ct ChallengeType) MarshalText() ([]byte, error) {
+	return []byte(ct), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unknown names are rejected with ErrInvalidFormat.
+func (ct *ChallengeType) UnmarshalText(text []byte) error {
+	parsed := ChallengeType(text)
+	if !parsed.IsValid() {
+		return fmt.Errorf("%w: unknown challenge type %q", ErrInvalidFormat, text)
+	}
+	*ct = parsed
+	return nil
+}
+
 // Challenge implements challenge-response authentication between peers.
 type Challenge interface {
 	// Type returns the challenge algorithm type.
@@ -63,13 +136,54 @@ type Challenge interface {
 	CheckResponse(data []byte) error
 	// MakeResponse generates a response to a received challenge.
 	MakeResponse(challenge []byte) (response []byte, err error)
+	// MarshalWire returns the challenge as a self-describing binary envelope.
+	// Load it with UnmarshalChallenge.
+	MarshalWire() ([]byte, error)
+}
+
+// challengeEnvelope is the wire format of a challenge.
+type challengeEnvelope struct {
+	Type      ChallengeType `cbor:"t"`
+	Purpose   string        `cbor:"p"`
+	Challenge []byte        `cbor:"c"`
+	// Responder signals that the receiver acts as the responder and must
+	// create its challenge with swapped requester and responder contexts.
+	Responder bool `cbor:"r"`
+}
+
+func marshalChallenge(ct ChallengeType, purpose string, challenge []byte) ([]byte, error) {
+	return cbor.Marshal(&challengeEnvelope{
+		Type:      ct,
+		Purpose:   purpose,
+		Challenge: challenge,
+		Responder: true,
+	})
+}
+
+// UnmarshalChallenge loads a challenge envelope created by MarshalWire.
+// The receiver is the responder and must create its challenge for the
+// returned type and purpose with swapped requester and responder contexts.
+func UnmarshalChallenge(data []byte) (ct ChallengeType, purpose string, challenge []byte, err error) {
+	env := &challengeEnvelope{}
+	if err := cbor.Unmarshal(data, env); err != nil {
+		return "", "", nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	switch {
+	case !env.Type.IsValid():
+		return "", "", nil, fmt.Errorf("%w: invalid challenge type: %q", ErrInvalidFormat, env.Type)
+	case len(env.Challenge) < defaultChallengeSize:
+		return "", "", nil, fmt.Errorf("%w: challenge too short", ErrInvalidFormat)
+	case !env.Responder:
+		return "", "", nil, fmt.Errorf("%w: receiver role not set", ErrInvalidFormat)
+	}
+	return env.Type, env.Purpose, env.Challenge, nil
 }
 
 // HashedContextChallenge implements Challenge using context-bound hashing.
 type HashedContextChallenge struct {
 	challengeType    ChallengeType
 	hash             Hash
-	challengeData    []byte
+	challengeData    Secret
 	purpose          string
 	requesterContext string
 	responderContext string
@@ -80,36 +194,151 @@ func (hcc *HashedContextChallenge) Type() ChallengeType {
 }
 
 func (hcc *HashedContextChallenge) GetChallenge() []byte {
-	return hcc.challengeData
+	return hcc.challengeData.Bytes()
 }
 
+func (hcc *HashedContextChallenge) MarshalWire() ([]byte, error) {
+	return marshalChallenge(hcc.challengeType, hcc.purpose, hcc.challengeData)
+}
+
+// CheckResponse verifies a response to the challenge.
+// Responses made with MakeResponseWithExpiry are rejected with
+// ErrChallengeExpired once their embedded expiry time has passed.
+// Responses of the wrong size are rejected with ErrResponseMalformed.
 func (hcc *HashedContextChallenge) CheckResponse(data []byte) error {
-	comparison := hcc.makeHash(hcc.challengeData, false)
-	if subtle.ConstantTimeCompare(data, comparison) != 1 {
-		return ErrChallengeFailed
+	return hcc.checkResponse(data, nil)
+}
+
+// CheckResponseBound verifies a response made with MakeResponseBound.
+// The extra data must be the same as given to MakeResponseBound, otherwise
+// the check fails with ErrChallengeFailed.
+func (hcc *HashedContextChallenge) CheckResponseBound(data, extra []byte) error {
+	return hcc.checkResponse(data, boundExtra(extra))
+}
+
+// checkResponse verifies a response. If extra is not nil, it is bound to the response.
+func (hcc *HashedContextChallenge) checkResponse(data, extra []byte) error {
+	hashSize := hcc.hash.Size()
+	switch len(data) {
+	case hashSize:
+		comparison, err := hcc.makeHash(hcc.challengeData, false, nil, extra)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(data, comparison) != 1 {
+			return ErrChallengeFailed
+		}
+		return nil
+
+	case challengeExpirySize + hashSize:
+		// Responses with expiry are prefixed with the timestamp.
+		notAfter := int64(binary.BigEndian.Uint64(data[:challengeExpirySize]))
+		comparison, err := hcc.makeHash(hcc.challengeData, false, &notAfter, extra)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(data[challengeExpirySize:], comparison) != 1 {
+			return ErrChallengeFailed
+		}
+		if time.Now().Unix() > notAfter {
+			return ErrChallengeExpired
+		}
+		return nil
+
+	default:
+		return ErrResponseMalformed
 	}
-	return nil
 }
 
 func (hcc *HashedContextChallenge) MakeResponse(challenge []byte) (response []byte, err error) {
-	return hcc.makeHash(challenge, true), nil
+	return hcc.makeHash(challenge, true, nil, nil)
 }
 
-func (hcc *HashedContextChallenge) makeHash(input []byte, reverse bool) []byte {
-	vh := NewValueHasher(hcc.hash.New())
+// MakeResponseBound generates a response to a received challenge that is bound
+// to the given extra data, such as a TLS exporter value or a connection ID.
+// This prevents relaying the response to another channel.
+// Check it with CheckResponseBound and the same extra data.
+func (hcc *HashedContextChallenge) MakeResponseBound(challenge, extra []byte) (response []byte, err error) {
+	return hcc.makeHash(challenge, true, nil, boundExtra(extra))
+}
 
-	vh.AddString("hashed context challenge") // Fixed internal value.
-	vh.AddString(hcc.purpose)                // Add purpose.
-	if !reverse {
-		// Add request, then response context for checking response.
-		vh.AddString(hcc.requesterContext)
-		vh.AddString(hcc.responderContext)
-	} else {
-		// Add response, then request context for making response.
-		vh.AddString(hcc.responderContext)
-		vh.AddString(hcc.requesterContext)
+// boundExtra returns the extra data to bind, which is never nil, so that
+// binding empty extra data differs from not binding any.
+func boundExtra(extra []byte) []byte {
+	if extra == nil {
+		return []byte{}
 	}
-	vh.Add(input)
+	return extra
+}
+
+// MakeResponseWithExpiry generates a response to a received challenge that is
+// only valid until the given time, with a resolution of seconds.
+// The expiry time is embedded in the response and bound by the hash.
+func (hcc *HashedContextChallenge) MakeResponseWithExpiry(challenge []byte, notAfter time.Time) (response []byte, err error) {
+	notAfterUnix := notAfter.Unix()
+	checksum, err := hcc.makeHash(challenge, true, &notAfterUnix, nil)
+	if err != nil {
+		return nil, err
+	}
+	response = binary.BigEndian.AppendUint64(nil, uint64(notAfterUnix))
+	return append(response, checksum...), nil
+}
 
+// ContextHash computes the domain separated hash used by the hashed context
+// challenge, so that compatible implementations can be built independently.
+// The fields are hashed with a ValueHasher in this order:
+//
+//  1. The fixed string "hashed context challenge"
+//  2. The purpose
+//  3. The first context: the requester context when checking a response
+//  4. The second context: the responder context when checking a response
+//  5. The input, ie. the challenge
+//
+// When making a response, the contexts are in reverse order.
+// Responses with expiry or binding add more fields and are not covered.
+// Returns nil if the hash algorithm is invalid.
+func ContextHash(purpose, firstCtx, secondCtx string, input []byte, h Hash) []byte {
+	vh, err := contextHasher(purpose, firstCtx, secondCtx, input, h)
+	if err != nil {
+		return nil
+	}
 	return vh.Sum(nil)
 }
+
+// contextHasher returns a value hasher with the fields of ContextHash added.
+func contextHasher(purpose, firstCtx, secondCtx string, input []byte, h Hash) (*ValueHasher, error) {
+	vh, err := NewValueHasherChecked(h)
+	if err != nil {
+		return nil, err
+	}
+
+	vh.AddString("hashed context challenge") // Fixed internal value.
+	vh.AddString(purpose)
+	vh.AddString(firstCtx)
+	vh.AddString(secondCtx)
+	vh.Add(input)
+	return vh, nil
+}
+
+func (hcc *HashedContextChallenge) makeHash(input []byte, reverse bool, notAfter *int64, extra []byte) ([]byte, error) {
+	// Add request, then response context for checking response.
+	// Add response, then request context for making response.
+	firstCtx, secondCtx := hcc.requesterContext, hcc.responderContext
+	if reverse {
+		firstCtx, secondCtx = secondCtx, firstCtx
+	}
+	vh, err := contextHasher(hcc.purpose, firstCtx, secondCtx, input, hcc.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if notAfter != nil {
+		vh.AddUint(uint64(*notAfter))
+	}
+	if extra != nil {
+		vh.AddString("bound")
+		vh.Add(extra)
+	}
+
+	return vh.Sum(nil), nil
+}
@@ -0,0 +1,107 @@
+package crop
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"sync"
+)
+
+// MutualChallenge implements Challenge for authenticating both peers in a
+// single round trip. Both peers create a MutualChallenge with their own
+// context as the requester context, exchange their challenges at the same
+// time and then exchange their responses. Each response is bound to both
+// challenges, so MakeResponse must be called with the peer's challenge before
+// CheckResponse can check the peer's response.
+type MutualChallenge struct {
+	hash             Hash
+	challengeData    Secret
+	purpose          string
+	requesterContext string
+	responderContext string
+
+	lock          sync.Mutex
+	peerChallenge []byte
+}
+
+func (mc *MutualChallenge) Type() ChallengeType {
+	return ChallengeTypeMutualContextHashBl3
+}
+
+func (mc *MutualChallenge) GetChallenge() []byte {
+	return mc.challengeData.Bytes()
+}
+
+func (mc *MutualChallenge) MarshalWire() ([]byte, error) {
+	return marshalChallenge(ChallengeTypeMutualContextHashBl3, mc.purpose, mc.challengeData)
+}
+
+// CheckResponse verifies the peer's response to the challenge.
+// MakeResponse must have been called with the peer's challenge before.
+func (mc *MutualChallenge) CheckResponse(data []byte) error {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	switch {
+	case mc.peerChallenge == nil:
+		return fmt.Errorf("%w: peer challenge unknown, make response first", ErrChallengeFailed)
+	case len(data) != mc.hash.Size():
+		return ErrResponseMalformed
+	}
+
+	comparison, err := mc.makeHash(mc.challengeData, mc.peerChallenge, false)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(data, comparison) != 1 {
+		return ErrChallengeFailed
+	}
+	return nil
+}
+
+// MakeResponse generates a response to the peer's challenge, bound to the own
+// challenge. It may only be called with a single peer challenge.
+func (mc *MutualChallenge) MakeResponse(challenge []byte) (response []byte, err error) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	switch {
+	case len(challenge) < defaultChallengeSize:
+		return nil, fmt.Errorf("%w: challenge too short", ErrInvalidFormat)
+	case mc.peerChallenge != nil && !bytes.Equal(mc.peerChallenge, challenge):
+		return nil, fmt.Errorf("%w: already responded to another challenge", ErrCannotReuse)
+	case subtle.ConstantTimeCompare(challenge, mc.challengeData) == 1:
+		return nil, fmt.Errorf("%w: peer challenge equals own challenge", ErrChallengeFailed)
+	}
+
+	response, err = mc.makeHash(challenge, mc.challengeData, true)
+	if err != nil {
+		return nil, err
+	}
+	mc.peerChallenge = bytes.Clone(challenge)
+	return response, nil
+}
+
+// makeHash hashes both challenges, with the challenge being answered first.
+func (mc *MutualChallenge) makeHash(answered, other []byte, reverse bool) ([]byte, error) {
+	vh, err := NewValueHasherChecked(mc.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	vh.AddString("mutual context challenge") // Fixed internal value.
+	vh.AddString(mc.purpose)                 // Add purpose.
+	if !reverse {
+		// Add request, then response context for checking response.
+		vh.AddString(mc.requesterContext)
+		vh.AddString(mc.responderContext)
+	} else {
+		// Add response, then request context for making response.
+		vh.AddString(mc.responderContext)
+		vh.AddString(mc.requesterContext)
+	}
+	vh.Add(answered)
+	vh.Add(other)
+
+	return vh.Sum(nil), nil
+}
@@ -0,0 +1,86 @@
+package crop
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SignatureChallenge implements Challenge by signing the challenge with a
+// key pair, proving possession of the private key.
+type SignatureChallenge struct {
+	challengeData    Secret
+	purpose          string
+	requesterContext string
+	responderContext string
+
+	keyPair KeyPair
+	peerKey KeyPair
+}
+
+// NewSignatureChallenge creates a new signature based challenge.
+// The key pair is used to make responses and must include the private key.
+// The peer key is used to check responses.
+// Either may be nil if the challenge is only used in one direction.
+// As with other challenges, the responder must swap the contexts.
+func NewSignatureChallenge(purpose, requesterContext, responderContext string, keyPair, peerKey KeyPair) (*SignatureChallenge, error) {
+	if keyPair == nil && peerKey == nil {
+		return nil, errors.New("signature challenge requires a key pair or a peer key")
+	}
+
+	return &SignatureChallenge{
+		challengeData:    NewSecretValue(defaultChallengeSize),
+		purpose:          purpose,
+		requesterContext: requesterContext,
+		responderContext: responderContext,
+		keyPair:          keyPair,
+		peerKey:          peerKey,
+	}, nil
+}
+
+func (sc *SignatureChallenge) Type() ChallengeType {
+	return ChallengeTypeSignature
+}
+
+func (sc *SignatureChallenge) GetChallenge() []byte {
+	return sc.challengeData.Bytes()
+}
+
+func (sc *SignatureChallenge) MarshalWire() ([]byte, error) {
+	return marshalChallenge(ChallengeTypeSignature, sc.purpose, sc.challengeData)
+}
+
+func (sc *SignatureChallenge) CheckResponse(data []byte) error {
+	if sc.peerKey == nil {
+		return ErrNoPublicKey
+	}
+	if err := sc.peerKey.Verify(sc.makeSignedData(sc.challengeData, false), data); err != nil {
+		return fmt.Errorf("%w: %w", ErrChallengeFailed, err)
+	}
+	return nil
+}
+
+func (sc *SignatureChallenge) MakeResponse(challenge []byte) (response []byte, err error) {
+	if sc.keyPair == nil || !sc.keyPair.HasPrivate() {
+		return nil, ErrNoPrivateKey
+	}
+	return sc.keyPair.Sign(sc.makeSignedData(challenge, true))
+}
+
+func (sc *SignatureChallenge) makeSignedData(input []byte, reverse bool) []byte {
+	vh := NewValueHasher(BLAKE3.New())
+
+	vh.AddString("signature challenge") // Fixed internal value.
+	vh.AddString(sc.purpose)            // Add purpose.
+	if !reverse {
+		// Add request, then response context for checking response.
+		vh.AddString(sc.requesterContext)
+		vh.AddString(sc.responderContext)
+	} else {
+		// Add response, then request context for making response.
+		vh.AddString(sc.responderContext)
+		vh.AddString(sc.requesterContext)
+	}
+	vh.Add(input)
+
+	return vh.Sum(nil)
+}
@@ -4,8 +4,10 @@ package crop
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"testing"
+	"time"
 )
 
 func TestChallengeType_IsValid(t *testing.T) {
@@ -256,8 +258,295 @@ func TestHashedContextChallenge_ResponseMatchesIndependentComputation(t *testing
 		t.Fatalf("independent computation mismatch\n got: %x\nwant: %x", resp1, resp2)
 	}
 
+	// The exported construction matches as well.
+	if resp3 := ContextHash(purpose, reqCtx, resCtx, chal, BLAKE3); !bytes.Equal(resp1, resp3) {
+		t.Fatalf("ContextHash mismatch\n got: %x\nwant: %x", resp3, resp1)
+	}
+	if ContextHash(purpose, reqCtx, resCtx, chal, "invalid") != nil {
+		t.Fatal("expected nil for invalid hash")
+	}
+
 	// Ensure CheckResponse accepts the response.
 	if err := hReq.CheckResponse(resp1); err != nil {
 		t.Fatalf("CheckResponse failed for valid response: %v", err)
 	}
 }
+
+func TestSignatureChallenge_Flow(t *testing.T) {
+	t.Parallel()
+
+	const (
+		purpose = "identity"
+		reqCtx  = "alice"
+		resCtx  = "bob"
+	)
+
+	if _, err := NewChallenge(ChallengeTypeSignature, purpose, reqCtx, resCtx); err == nil {
+		t.Fatalf("expected error creating signature challenge without keys")
+	}
+
+	bobKey, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	malloryKey, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+
+	// Alice challenges Bob, knowing his public key.
+	reqCh, err := NewSignatureChallenge(purpose, reqCtx, resCtx, nil, bobKey.ToPublic())
+	if err != nil {
+		t.Fatalf("NewSignatureChallenge requester: %v", err)
+	}
+	if reqCh.Type() != ChallengeTypeSignature {
+		t.Fatalf("Type() = %q, want %q", reqCh.Type(), ChallengeTypeSignature)
+	}
+
+	// Bob responds with swapped contexts.
+	resCh, err := NewSignatureChallenge(purpose, resCtx, reqCtx, bobKey, nil)
+	if err != nil {
+		t.Fatalf("NewSignatureChallenge responder: %v", err)
+	}
+	resp, err := resCh.MakeResponse(reqCh.GetChallenge())
+	if err != nil {
+		t.Fatalf("MakeResponse: %v", err)
+	}
+	if err := reqCh.CheckResponse(resp); err != nil {
+		t.Fatalf("CheckResponse failed: %v", err)
+	}
+
+	// Mallory cannot respond for Bob.
+	malCh, _ := NewSignatureChallenge(purpose, resCtx, reqCtx, malloryKey, nil)
+	resp, err = malCh.MakeResponse(reqCh.GetChallenge())
+	if err != nil {
+		t.Fatalf("MakeResponse: %v", err)
+	}
+	if err := reqCh.CheckResponse(resp); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed for wrong key, got %v", err)
+	}
+
+	// Responder must swap contexts.
+	badCh, _ := NewSignatureChallenge(purpose, reqCtx, resCtx, bobKey, nil)
+	resp, _ = badCh.MakeResponse(reqCh.GetChallenge())
+	if err := reqCh.CheckResponse(resp); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed for unswapped contexts, got %v", err)
+	}
+
+	// Missing keys.
+	if _, err := reqCh.MakeResponse(reqCh.GetChallenge()); !errors.Is(err, ErrNoPrivateKey) {
+		t.Fatalf("expected ErrNoPrivateKey, got %v", err)
+	}
+	if err := resCh.CheckResponse(resp); !errors.Is(err, ErrNoPublicKey) {
+		t.Fatalf("expected ErrNoPublicKey, got %v", err)
+	}
+}
+
+func TestHashedContextChallenge_WithSize(t *testing.T) {
+	t.Parallel()
+
+	reqCh, err := NewChallengeWithSize(ChallengeTypeContextHashBl3, 64, "p", "req", "res")
+	if err != nil {
+		t.Fatalf("NewChallengeWithSize: %v", err)
+	}
+	if len(reqCh.GetChallenge()) != 64 {
+		t.Fatalf("GetChallenge len=%d, want 64", len(reqCh.GetChallenge()))
+	}
+
+	// Responder does not need to know the size.
+	resCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "p", "res", "req")
+	resp, err := resCh.MakeResponse(reqCh.GetChallenge())
+	if err != nil {
+		t.Fatalf("MakeResponse: %v", err)
+	}
+	if err := reqCh.CheckResponse(resp); err != nil {
+		t.Fatalf("CheckResponse failed: %v", err)
+	}
+
+	// Size is raised to the minimum.
+	small, _ := NewChallengeWithSize(ChallengeTypeContextHashBl3, 8, "p", "req", "res")
+	if len(small.GetChallenge()) != 32 {
+		t.Fatalf("GetChallenge len=%d, want minimum of 32", len(small.GetChallenge()))
+	}
+}
+
+func TestHashedContextChallenge_Expiry(t *testing.T) {
+	t.Parallel()
+
+	reqCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "p", "req", "res")
+	hReq := reqCh.(*HashedContextChallenge)
+	resCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "p", "res", "req")
+	hRes := resCh.(*HashedContextChallenge)
+
+	// Valid response within window.
+	resp, err := hRes.MakeResponseWithExpiry(hReq.GetChallenge(), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("MakeResponseWithExpiry: %v", err)
+	}
+	if err := hReq.CheckResponse(resp); err != nil {
+		t.Fatalf("CheckResponse failed: %v", err)
+	}
+
+	// Expired response.
+	resp, _ = hRes.MakeResponseWithExpiry(hReq.GetChallenge(), time.Now().Add(-time.Minute))
+	if err := hReq.CheckResponse(resp); !errors.Is(err, ErrChallengeExpired) {
+		t.Fatalf("expected ErrChallengeExpired, got %v", err)
+	}
+
+	// Extending the embedded expiry time is detected.
+	binary.BigEndian.PutUint64(resp, uint64(time.Now().Add(time.Hour).Unix()))
+	if err := hReq.CheckResponse(resp); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed for tampered expiry, got %v", err)
+	}
+}
+
+func TestChallenge_WireRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	reqCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "wire", "req", "res")
+	data, err := reqCh.MarshalWire()
+	if err != nil {
+		t.Fatalf("MarshalWire: %v", err)
+	}
+
+	// Peer reconstructs the responder from the envelope.
+	ct, purpose, chal, err := UnmarshalChallenge(data)
+	if err != nil {
+		t.Fatalf("UnmarshalChallenge: %v", err)
+	}
+	if ct != ChallengeTypeContextHashBl3 || purpose != "wire" {
+		t.Fatalf("got type=%q purpose=%q", ct, purpose)
+	}
+	if !bytes.Equal(chal, reqCh.GetChallenge()) {
+		t.Fatalf("challenge bytes mismatch")
+	}
+	resCh, err := NewChallenge(ct, purpose, "res", "req")
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	resp, err := resCh.MakeResponse(chal)
+	if err != nil {
+		t.Fatalf("MakeResponse: %v", err)
+	}
+	if err := reqCh.CheckResponse(resp); err != nil {
+		t.Fatalf("CheckResponse failed: %v", err)
+	}
+
+	// Invalid envelopes.
+	if _, _, _, err := UnmarshalChallenge([]byte("garbage")); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("expected ErrInvalidFormat, got %v", err)
+	}
+	if _, _, _, err := UnmarshalChallenge(data[:len(data)-1]); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("expected ErrInvalidFormat for truncated envelope, got %v", err)
+	}
+}
+
+func TestHashedContextChallenge_MalformedResponse(t *testing.T) {
+	t.Parallel()
+
+	reqCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "p", "req", "res")
+	resCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "p", "res", "req")
+	resp, _ := resCh.MakeResponse(reqCh.GetChallenge())
+
+	for _, bad := range [][]byte{nil, resp[:len(resp)-1], append(resp, 0)} {
+		if err := reqCh.CheckResponse(bad); !errors.Is(err, ErrResponseMalformed) {
+			t.Fatalf("expected ErrResponseMalformed for length %d, got %v", len(bad), err)
+		}
+	}
+}
+
+func TestMutualChallenge_Flow(t *testing.T) {
+	t.Parallel()
+
+	const purpose = "mutual-test"
+	alice, err := NewChallenge(ChallengeTypeMutualContextHashBl3, purpose, "alice", "bob")
+	if err != nil {
+		t.Fatalf("create alice challenge: %v", err)
+	}
+	bob, err := NewChallenge(ChallengeTypeMutualContextHashBl3, purpose, "bob", "alice")
+	if err != nil {
+		t.Fatalf("create bob challenge: %v", err)
+	}
+
+	// Responses can only be checked after responding to the peer.
+	if err := alice.CheckResponse(make([]byte, 32)); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed before MakeResponse, got: %v", err)
+	}
+
+	// Both exchange challenges at the same time, then responses.
+	aliceResp, err := alice.MakeResponse(bob.GetChallenge())
+	if err != nil {
+		t.Fatalf("alice make response: %v", err)
+	}
+	bobResp, err := bob.MakeResponse(alice.GetChallenge())
+	if err != nil {
+		t.Fatalf("bob make response: %v", err)
+	}
+	if err := alice.CheckResponse(bobResp); err != nil {
+		t.Fatalf("alice check response: %v", err)
+	}
+	if err := bob.CheckResponse(aliceResp); err != nil {
+		t.Fatalf("bob check response: %v", err)
+	}
+
+	// Reflected responses are rejected.
+	if err := alice.CheckResponse(aliceResp); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected reflected response to fail, got: %v", err)
+	}
+	if _, err := alice.MakeResponse(alice.GetChallenge()); err == nil {
+		t.Fatal("expected error when responding to own challenge")
+	}
+
+	// Responding to a different challenge is not allowed.
+	mallory, _ := NewChallenge(ChallengeTypeMutualContextHashBl3, purpose, "bob", "alice")
+	if _, err := alice.MakeResponse(mallory.GetChallenge()); !errors.Is(err, ErrCannotReuse) {
+		t.Fatalf("expected ErrCannotReuse, got: %v", err)
+	}
+
+	// Response bound to another challenge is rejected.
+	carol, _ := NewChallenge(ChallengeTypeMutualContextHashBl3, purpose, "alice", "bob")
+	if _, err := carol.MakeResponse(bob.GetChallenge()); err != nil {
+		t.Fatalf("carol make response: %v", err)
+	}
+	if err := carol.CheckResponse(bobResp); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected response for other challenge to fail, got: %v", err)
+	}
+	if err := alice.CheckResponse(bobResp[:16]); !errors.Is(err, ErrResponseMalformed) {
+		t.Fatalf("expected ErrResponseMalformed, got: %v", err)
+	}
+}
+
+func TestHashedContextChallenge_Bound(t *testing.T) {
+	t.Parallel()
+
+	reqCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "bound", "req", "res")
+	resCh, _ := NewChallenge(ChallengeTypeContextHashBl3, "bound", "res", "req")
+	req := reqCh.(*HashedContextChallenge)
+	res := resCh.(*HashedContextChallenge)
+	connID := []byte("connection 1")
+
+	resp, err := res.MakeResponseBound(req.GetChallenge(), connID)
+	if err != nil {
+		t.Fatalf("MakeResponseBound error: %v", err)
+	}
+	if err := req.CheckResponseBound(resp, connID); err != nil {
+		t.Fatalf("CheckResponseBound error: %v", err)
+	}
+
+	// Other or missing extra data fails.
+	if err := req.CheckResponseBound(resp, []byte("connection 2")); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed for other extra data, got: %v", err)
+	}
+	if err := req.CheckResponse(resp); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed for unbound check, got: %v", err)
+	}
+
+	// Empty extra data differs from no binding.
+	resp, _ = res.MakeResponseBound(req.GetChallenge(), nil)
+	if err := req.CheckResponse(resp); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed for empty binding, got: %v", err)
+	}
+	if err := req.CheckResponseBound(resp, []byte{}); err != nil {
+		t.Fatalf("CheckResponseBound with empty extra data error: %v", err)
+	}
+}
@@ -4,12 +4,22 @@ import "errors"
 
 var (
 	ErrAuthCodeInvalid            = errors.New("invalid message authentication code")
+	ErrBurned                     = errors.New("key material burned")
 	ErrCannotReuse                = errors.New("cannot reuse")
+	ErrChallengeExpired           = errors.New("challenge expired")
 	ErrChallengeFailed            = errors.New("challenge failed")
 	ErrChecksumMismatch           = errors.New("checksum mismatch")
+	ErrDecryptionFailed           = errors.New("decryption failed")
+	ErrInvalidExchangeMsg         = errors.New("invalid exchange message")
 	ErrInvalidFormat              = errors.New("invalid format")
+	ErrInvalidHash                = errors.New("invalid hash algorithm")
 	ErrInvalidKeyPairType         = errors.New("invalid key pair type")
+	ErrInvalidSignature           = errors.New("invalid signature")
+	ErrInvalidSuite               = errors.New("invalid suite")
+	ErrKeyGeneration              = errors.New("key generation failed")
+	ErrNoMatchingKey              = errors.New("no matching key")
 	ErrNoPrivateKey               = errors.New("no private key available")
 	ErrNoPublicKey                = errors.New("no public key available")
 	ErrRequestedKeyLengthTooSmall = errors.New("request key length too small")
+	ErrResponseMalformed          = errors.New("response malformed")
 )
@@ -3,6 +3,7 @@ module github.com/mycoria/crop
 go 1.25.1
 
 require (
+	github.com/cloudflare/circl v1.6.1
 	github.com/fxamacker/cbor/v2 v2.9.0
 	github.com/mr-tron/base58 v1.2.0
 	github.com/stretchr/testify v1.11.1
@@ -0,0 +1,200 @@
+package crop
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const handshakePurpose = "crop handshake"
+
+// Handshake drives the message flow to establish a Session between two peers:
+//
+//  1. The initiator sends InitiatorHello with its exchange message and a challenge.
+//  2. The responder answers with ResponderHello, which includes its exchange
+//     message and the response to the challenge, authenticated with the new
+//     session keys.
+//  3. The initiator calls Finish to check the response and get the session.
+//
+// The challenge contexts are swapped for the responder internally.
+// A handshake can only be used once.
+type Handshake struct {
+	lock      sync.Mutex
+	session   *Session
+	challenge Challenge
+	done      bool
+}
+
+// handshakeMsg is the wire format of handshake messages.
+type handshakeMsg struct {
+	Suite     string `cbor:"s,omitempty"`
+	Exchange  []byte `cbor:"x"`
+	Challenge []byte `cbor:"c,omitempty"`
+	Response  []byte `cbor:"r,omitempty"`
+	MAC       []byte `cbor:"m,omitempty"`
+}
+
+// NewInitiatorHandshake returns a new handshake for the initiating side.
+// The challenge type of the suite must be one-way and must not require key pairs.
+func NewInitiatorHandshake(s Suite) (*Handshake, error) {
+	return newHandshake(s, false)
+}
+
+// NewResponderHandshake returns a new handshake for the responding side.
+// The challenge type of the suite must be one-way and must not require key pairs.
+func NewResponderHandshake(s Suite) (*Handshake, error) {
+	return newHandshake(s, true)
+}
+
+func newHandshake(s Suite, responder bool) (*Handshake, error) {
+	if s.challenge == ChallengeTypeMutualContextHashBl3 {
+		return nil, fmt.Errorf("challenge type %s is not supported for handshakes", s.challenge)
+	}
+	session, err := s.newSession(responder)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create challenge, with swapped contexts for the responder.
+	requesterContext, responderContext := sessionInitiatorParty, sessionResponderParty
+	if responder {
+		requesterContext, responderContext = responderContext, requesterContext
+	}
+	challenge, err := s.challenge.New(handshakePurpose, requesterContext, responderContext)
+	if err != nil {
+		session.Burn()
+		return nil, fmt.Errorf("create challenge: %w", err)
+	}
+
+	return &Handshake{
+		session:   session,
+		challenge: challenge,
+	}, nil
+}
+
+// InitiatorHello returns the first handshake message to send to the responder.
+// Returns nil if called on a responder handshake or after the handshake failed.
+func (hs *Handshake) InitiatorHello() []byte {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	if hs.done || !hs.session.IsInitiator() {
+		return nil
+	}
+
+	exchMsg, err := hs.session.ExchangeMsg()
+	if err != nil {
+		return nil
+	}
+	out, err := cbor.Marshal(&handshakeMsg{
+		Suite:     hs.session.Suite().String(),
+		Exchange:  exchMsg,
+		Challenge: hs.challenge.GetChallenge(),
+	})
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// ResponderHello processes the initiator's hello and returns the answer to
+// send back. Afterwards, the responder's session is available via Session.
+func (hs *Handshake) ResponderHello(in []byte) (out []byte, err error) {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	switch {
+	case hs.done:
+		return nil, fmt.Errorf("handshake already finished: %w", ErrCannotReuse)
+	case hs.session.IsInitiator():
+		return nil, fmt.Errorf("cannot respond with initiator handshake")
+	}
+	hs.done = true
+
+	// Never leave a session of a failed handshake behind.
+	defer func() {
+		if err != nil {
+			hs.session.Burn()
+		}
+	}()
+
+	// Parse message and check suite.
+	msg := &handshakeMsg{}
+	if err := cbor.Unmarshal(in, msg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	if msg.Suite != hs.session.Suite().String() {
+		return nil, fmt.Errorf("%w: peer uses suite %q", ErrInvalidSuite, msg.Suite)
+	}
+
+	// Establish session.
+	if err := hs.session.Establish(msg.Exchange); err != nil {
+		return nil, err
+	}
+	exchMsg, err := hs.session.ExchangeMsg()
+	if err != nil {
+		return nil, err
+	}
+
+	// Respond to challenge and bind the response to the session keys.
+	response, err := hs.challenge.MakeResponse(msg.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(&handshakeMsg{
+		Exchange: exchMsg,
+		Response: response,
+		MAC:      hs.session.Sign(handshakePurpose, response),
+	})
+}
+
+// Finish processes the responder's hello and returns the established session.
+func (hs *Handshake) Finish(in []byte) (*Session, error) {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	switch {
+	case hs.done:
+		return nil, fmt.Errorf("handshake already finished: %w", ErrCannotReuse)
+	case !hs.session.IsInitiator():
+		return nil, fmt.Errorf("cannot finish responder handshake")
+	}
+	hs.done = true
+
+	// Parse message.
+	msg := &handshakeMsg{}
+	if err := cbor.Unmarshal(in, msg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	// Establish session.
+	if err := hs.session.Establish(msg.Exchange); err != nil {
+		return nil, err
+	}
+
+	// Check that the responder derived the same keys and answered the challenge.
+	if err := hs.session.Verify(handshakePurpose, msg.Response, msg.MAC); err != nil {
+		hs.session.Burn()
+		return nil, fmt.Errorf("%w: %w", ErrChallengeFailed, err)
+	}
+	if err := hs.challenge.CheckResponse(msg.Response); err != nil {
+		hs.session.Burn()
+		return nil, err
+	}
+
+	return hs.session, nil
+}
+
+// Session returns the established session.
+// It is available to the responder after ResponderHello and to the initiator
+// after Finish.
+func (hs *Handshake) Session() (*Session, error) {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	if !hs.done || !hs.session.IsEstablished() {
+		return nil, errSessionNotEstablished
+	}
+	return hs.session, nil
+}
@@ -0,0 +1,121 @@
+package crop
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestHandshake(t *testing.T) {
+	t.Parallel()
+
+	initHS, err := NewInitiatorHandshake(Default)
+	if err != nil {
+		t.Fatalf("create initiator handshake: %v", err)
+	}
+	respHS, err := NewResponderHandshake(Default)
+	if err != nil {
+		t.Fatalf("create responder handshake: %v", err)
+	}
+
+	// Run handshake between in-memory peers.
+	hello := initHS.InitiatorHello()
+	if hello == nil {
+		t.Fatal("initiator hello failed")
+	}
+	answer, err := respHS.ResponderHello(hello)
+	if err != nil {
+		t.Fatalf("responder hello: %v", err)
+	}
+	initSession, err := initHS.Finish(answer)
+	if err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	respSession, err := respHS.Session()
+	if err != nil {
+		t.Fatalf("responder session: %v", err)
+	}
+
+	// Sessions are usable in both directions.
+	ciphertext := initSession.Seal([]byte("ping"), nil)
+	plaintext, err := respSession.Open(ciphertext, nil)
+	if err != nil || !bytes.Equal(plaintext, []byte("ping")) {
+		t.Fatalf("open ping: %q, %v", plaintext, err)
+	}
+	mac := respSession.Sign("test", []byte("pong"))
+	if err := initSession.Verify("test", []byte("pong"), mac); err != nil {
+		t.Fatalf("verify pong: %v", err)
+	}
+
+	// Handshakes cannot be reused or used in the wrong role.
+	if _, err := initHS.Finish(answer); !errors.Is(err, ErrCannotReuse) {
+		t.Fatalf("expected ErrCannotReuse, got: %v", err)
+	}
+	if respHS.InitiatorHello() != nil {
+		t.Fatal("expected responder to not create initiator hello")
+	}
+}
+
+func TestHandshake_Failures(t *testing.T) {
+	t.Parallel()
+
+	// Suite mismatch.
+	other, err := NewSuite(WithMsgAuthCode(MsgAuthCodeTypeBlake3))
+	if err != nil {
+		t.Fatalf("create suite: %v", err)
+	}
+	initHS, err := NewInitiatorHandshake(other)
+	if err != nil {
+		t.Fatalf("create initiator handshake: %v", err)
+	}
+	respHS, err := NewResponderHandshake(Default)
+	if err != nil {
+		t.Fatalf("create responder handshake: %v", err)
+	}
+	if _, err := respHS.ResponderHello(initHS.InitiatorHello()); !errors.Is(err, ErrInvalidSuite) {
+		t.Fatalf("expected ErrInvalidSuite, got: %v", err)
+	}
+	if _, err := respHS.Session(); err == nil {
+		t.Fatal("expected no session after failed handshake")
+	}
+
+	// Tampered response.
+	initHS, err = NewInitiatorHandshake(Default)
+	if err != nil {
+		t.Fatalf("create initiator handshake: %v", err)
+	}
+	respHS, err = NewResponderHandshake(Default)
+	if err != nil {
+		t.Fatalf("create responder handshake: %v", err)
+	}
+	answer, err := respHS.ResponderHello(initHS.InitiatorHello())
+	if err != nil {
+		t.Fatalf("responder hello: %v", err)
+	}
+	msg := &handshakeMsg{}
+	if err := cbor.Unmarshal(answer, msg); err != nil {
+		t.Fatalf("unmarshal answer: %v", err)
+	}
+	msg.Response[0] ^= 1
+	answer, err = cbor.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal answer: %v", err)
+	}
+	if _, err := initHS.Finish(answer); !errors.Is(err, ErrChallengeFailed) {
+		t.Fatalf("expected ErrChallengeFailed, got: %v", err)
+	}
+	if _, err := initHS.Session(); err == nil {
+		t.Fatal("expected no session after failed handshake")
+	}
+
+	// Challenge types requiring key pairs are not supported.
+	sigSuite, err := NewSuite(WithChallenge(ChallengeTypeSignature))
+	if err != nil {
+		t.Fatalf("create suite: %v", err)
+	}
+	if _, err := NewInitiatorHandshake(sigSuite); err == nil {
+		t.Fatal("expected error for signature challenge suite")
+	}
+}
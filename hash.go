@@ -1,18 +1,27 @@
 package crop
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/hmac"
 	_ "crypto/sha256" // Register algorithms.
 	_ "crypto/sha512" // Register algorithms.
 	"crypto/subtle"
+	"errors"
+	"fmt"
 	"hash"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
 
 	"encoding/binary"
 
 	"github.com/zeebo/blake3"
-	_ "golang.org/x/crypto/blake2b" // Register algorithms.
-	_ "golang.org/x/crypto/blake2s" // Register algorithms.
-	_ "golang.org/x/crypto/sha3"    // Register algorithms.
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/sha3"
 )
 
 // Hash is a hash algorithm.
@@ -36,6 +45,11 @@ const (
 	SHA3_384 Hash = "SHA3_384"
 	SHA3_512 Hash = "SHA3_512"
 
+	// SHAKE (extendable output, see Hash.XOF).
+	// Used as a plain hash, they produce 32 and 64 bytes respectively.
+	SHAKE128 Hash = "SHAKE128"
+	SHAKE256 Hash = "SHAKE256"
+
 	// BLAKE2.
 	BLAKE2s_256 Hash = "BLAKE2s_256"
 	BLAKE2b_256 Hash = "BLAKE2b_256"
@@ -46,6 +60,28 @@ const (
 	BLAKE3 Hash = "BLAKE3"
 )
 
+// AllHashes returns all supported hashes.
+func AllHashes() []Hash {
+	return []Hash{
+		SHA2_224, SHA2_256, SHA2_384, SHA2_512, SHA2_512_224, SHA2_512_256,
+		SHA3_224, SHA3_256, SHA3_384, SHA3_512,
+		SHAKE128, SHAKE256,
+		BLAKE2s_256, BLAKE2b_256, BLAKE2b_384, BLAKE2b_512,
+		BLAKE3,
+	}
+}
+
+// ParseHash returns the hash with the given name, ignoring case.
+// Unknown names are rejected with ErrInvalidFormat.
+func ParseHash(name string) (Hash, error) {
+	for _, h := range AllHashes() {
+		if strings.EqualFold(name, h.String()) {
+			return h, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown hash %q", ErrInvalidFormat, name)
+}
+
 // New returns a new hash.Hash.
 func (h Hash) New() hash.Hash {
 	switch h {
@@ -73,6 +109,12 @@ func (h Hash) New() hash.Hash {
 	case SHA3_512:
 		return crypto.SHA3_512.New()
 
+		// SHAKE
+	case SHAKE128:
+		return sha3.NewShake128()
+	case SHAKE256:
+		return sha3.NewShake256()
+
 		// BLAKE2
 	case BLAKE2s_256:
 		return crypto.BLAKE2s_256.New()
@@ -92,15 +134,96 @@ func (h Hash) New() hash.Hash {
 	}
 }
 
+// NewKeyed returns a new hash.Hash using the native keyed mode of the hash.
+// Only BLAKE2 and BLAKE3 support native keying. BLAKE2b accepts keys of 1 to
+// 64 bytes, BLAKE2s keys of 1 to 32 bytes and BLAKE3 requires a 32 byte key.
+func (h Hash) NewKeyed(key []byte) (hash.Hash, error) {
+	// Check key size.
+	var minKeySize, maxKeySize int
+	switch h {
+	case BLAKE2b_256, BLAKE2b_384, BLAKE2b_512:
+		minKeySize, maxKeySize = 1, blake2b.Size
+	case BLAKE2s_256:
+		minKeySize, maxKeySize = 1, blake2s.Size
+	case BLAKE3:
+		minKeySize, maxKeySize = blake3KeySize, blake3KeySize
+	default:
+		return nil, fmt.Errorf("hash %s does not support native keying", h)
+	}
+	if len(key) < minKeySize || len(key) > maxKeySize {
+		return nil, fmt.Errorf("hash %s requires a key of %d to %d bytes, got %d", h, minKeySize, maxKeySize, len(key))
+	}
+
+	// Create keyed hash.
+	switch h {
+	case BLAKE2b_256:
+		return blake2b.New256(key)
+	case BLAKE2b_384:
+		return blake2b.New384(key)
+	case BLAKE2b_512:
+		return blake2b.New512(key)
+	case BLAKE2s_256:
+		return blake2s.New256(key)
+	default: // BLAKE3
+		return blake3.NewKeyed(key)
+	}
+}
+
+// Size returns the digest size of the hash in bytes, or 0 if the hash is unknown.
+// It does not create a hasher.
+func (h Hash) Size() int {
+	switch h {
+	case SHA2_224, SHA2_512_224, SHA3_224:
+		return 28
+	case SHA2_256, SHA2_512_256, SHA3_256, SHAKE128, BLAKE2s_256, BLAKE2b_256, BLAKE3:
+		return 32
+	case SHA2_384, SHA3_384, BLAKE2b_384:
+		return 48
+	case SHA2_512, SHA3_512, SHAKE256, BLAKE2b_512:
+		return 64
+	default:
+		return 0
+	}
+}
+
 func (h Hash) String() string {
 	return string(h)
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unknown names are rejected with ErrInvalidFormat.
+func (h *Hash) UnmarshalText(text []byte) error {
+	parsed := Hash(text)
+	if !parsed.IsValid() {
+		return fmt.Errorf("%w: unknown hash %q", ErrInvalidFormat, text)
+	}
+	*h = parsed
+	return nil
+}
+
 // IsValid returns whether the hash is known.
 func (h Hash) IsValid() bool {
 	return h.New() != nil
 }
 
+// IsFIPSApproved returns whether the hash is approved by FIPS 180-4 or FIPS 202.
+func (h Hash) IsFIPSApproved() bool {
+	switch h {
+	case SHA2_224, SHA2_256, SHA2_384, SHA2_512, SHA2_512_224, SHA2_512_256:
+		return true
+	case SHA3_224, SHA3_256, SHA3_384, SHA3_512:
+		return true
+	case SHAKE128, SHAKE256:
+		return true
+	}
+	return false
+}
+
 // Digest calculate and returns the hash sum over the given data.
 func (h Hash) Digest(data []byte) []byte {
 	hasher := h.New()
@@ -115,6 +238,62 @@ func (h Hash) Digest(data []byte) []byte {
 	return hasher.Sum(nil)
 }
 
+// TryDigest calculates and returns the hash sum over the given data.
+// In contrast to Digest, it returns ErrInvalidHash instead of panicking if
+// the hash algorithm is unknown.
+func (h Hash) TryDigest(data []byte) ([]byte, error) {
+	if !h.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHash, h)
+	}
+	return h.Digest(data), nil
+}
+
+// DigestInto calculates the hash sum over the given data and writes it into dst,
+// which must be exactly Size() bytes long.
+// This avoids allocating the checksum in hot loops.
+func (h Hash) DigestInto(data, dst []byte) error {
+	hasher := h.New()
+	switch {
+	case hasher == nil:
+		return fmt.Errorf("%w: %q", ErrInvalidHash, h)
+	case len(dst) != hasher.Size():
+		return fmt.Errorf("destination buffer must be %d bytes, got %d", hasher.Size(), len(dst))
+	}
+
+	// Calculate into destination.
+	_, _ = hasher.Write(data) // Never returns an error.
+	defer hasher.Reset()      // Internal state may leak data if kept in memory.
+	hasher.Sum(dst[:0])
+	return nil
+}
+
+// DigestReader calculates and returns the hash sum over all data from the reader.
+// The data is streamed through the hasher in chunks.
+func (h Hash) DigestReader(r io.Reader) ([]byte, error) {
+	hasher := h.New()
+	if hasher == nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHash, h)
+	}
+	defer hasher.Reset() // Internal state may leak data if kept in memory.
+
+	if _, err := io.Copy(hasher, r); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// DigestFile calculates and returns the hash sum over the contents of the file
+// at the given path. The file is streamed through the hasher in chunks.
+func (h Hash) DigestFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	return h.DigestReader(f)
+}
+
 // Verify calculates the checksum of the given data and checks if it matches the given checksum.
 func (h Hash) Verify(data, checksum []byte) error {
 	newChecksum := h.Digest(data)
@@ -124,6 +303,110 @@ func (h Hash) Verify(data, checksum []byte) error {
 	return nil
 }
 
+// VerifyInto is like Verify, but computes the checksum into the given scratch
+// buffer, which must be at least Size() bytes long.
+// This avoids allocating the checksum in hot verification loops.
+func (h Hash) VerifyInto(data, checksum, scratch []byte) error {
+	hasher := h.New()
+	switch {
+	case hasher == nil:
+		return fmt.Errorf("%w: %q", ErrInvalidHash, h)
+	case len(scratch) < hasher.Size():
+		return fmt.Errorf("scratch buffer must be at least %d bytes", hasher.Size())
+	}
+
+	// Calculate and compare.
+	_, _ = hasher.Write(data) // Never returns an error.
+	defer hasher.Reset()      // Internal state may leak data if kept in memory.
+	newChecksum := hasher.Sum(scratch[:0])
+	if subtle.ConstantTimeCompare(checksum, newChecksum) != 1 {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// XOF is an extendable-output function.
+// Write all input first, then read an arbitrary amount of output.
+type XOF interface {
+	// Write adds more data to the input.
+	// It returns an error if output has already been read.
+	io.Writer
+	// Read reads more output. It never returns an error.
+	io.Reader
+	// Reset resets the XOF to its initial state.
+	Reset()
+}
+
+var errXOFWriteAfterRead = errors.New("cannot write to XOF after reading output")
+
+// XOF returns a new extendable-output function for this hash.
+// Only BLAKE3, SHAKE128 and SHAKE256 support extendable output.
+func (h Hash) XOF() (XOF, error) {
+	switch h {
+	case BLAKE3, SHAKE128, SHAKE256:
+		x := &hashXOF{hash: h}
+		x.Reset()
+		return x, nil
+	default:
+		return nil, fmt.Errorf("hash %s does not support extendable output", h)
+	}
+}
+
+// hashXOF implements XOF for BLAKE3 and SHAKE.
+type hashXOF struct {
+	hash   Hash
+	input  io.Writer
+	output func() io.Reader
+	reader io.Reader
+}
+
+func (x *hashXOF) Write(p []byte) (n int, err error) {
+	if x.reader != nil {
+		return 0, errXOFWriteAfterRead
+	}
+	return x.input.Write(p)
+}
+
+func (x *hashXOF) Read(p []byte) (n int, err error) {
+	if x.reader == nil {
+		x.reader = x.output()
+	}
+	return x.reader.Read(p)
+}
+
+func (x *hashXOF) Reset() {
+	x.reader = nil
+	switch x.hash {
+	case BLAKE3:
+		hasher := blake3.New()
+		x.input = hasher
+		x.output = func() io.Reader { return hasher.Digest() }
+	case SHAKE128, SHAKE256:
+		hasher := x.hash.New().(sha3.ShakeHash) //nolint:forcetypeassert
+		x.input = hasher
+		x.output = func() io.Reader { return hasher }
+	}
+}
+
+// NewHMAC returns a new HMAC using this hash with the given key.
+func (h Hash) NewHMAC(key []byte) hash.Hash {
+	if !h.IsValid() {
+		// TODO: Find a better way to handle this.
+		panic("invalid hash algorithm")
+	}
+	return hmac.New(h.New, key)
+}
+
+// HMAC calculates and returns the HMAC over the given data with the given key.
+func (h Hash) HMAC(key, data []byte) []byte {
+	mac := h.NewHMAC(key)
+
+	// Calculate and return.
+	_, _ = mac.Write(data) // Never returns an error.
+	defer mac.Reset()      // Internal state may leak data if kept in memory.
+	return mac.Sum(nil)
+}
+
 // NewValueHasher creates a structured hasher for multiple values.
 func NewValueHasher(h hash.Hash) *ValueHasher {
 	return &ValueHasher{
@@ -131,74 +414,204 @@ func NewValueHasher(h hash.Hash) *ValueHasher {
 	}
 }
 
+// NewValueHasherChecked creates a structured hasher for multiple values using
+// the given hash algorithm. Unlike NewValueHasher, an invalid algorithm is
+// rejected with ErrInvalidHash instead of panicking on first use.
+func NewValueHasherChecked(h Hash) (*ValueHasher, error) {
+	if !h.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHash, h)
+	}
+	return NewValueHasher(h.New()), nil
+}
+
 // ValueHasher hashes structured data with field separation.
 type ValueHasher struct {
 	hasher   hash.Hash
 	fieldCnt uint64
+	keyed    []keyedField
+
+	// Buffers for encoding, kept here to avoid allocations when writing to
+	// the hasher interface.
+	headerBuf [16]byte
+	valueBuf  [8]byte
+	stringBuf []byte
+}
+
+// reset resets the value hasher to use the given hasher, keeping its buffers.
+func (vh *ValueHasher) reset(h hash.Hash) {
+	vh.hasher = h
+	vh.fieldCnt = 0
+	vh.keyed = nil
+}
+
+// keyedField is a field added with AddKeyed, which is hashed when finalizing.
+type keyedField struct {
+	key   string
+	value []byte
 }
 
 // Add hashes a byte slice field.
 func (vh *ValueHasher) Add(data []byte) {
+	vh.addFieldHeader(uint64(len(data)))
+
+	// Write field data.
+	if len(data) > 0 {
+		_, err := vh.hasher.Write(data)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// addFieldHeader starts a new field with the given length.
+func (vh *ValueHasher) addFieldHeader(length uint64) {
 	vh.fieldCnt++
 
 	// Note: All writes here cannot fail.
 	// If things are so bad that they do, it is okay to panic.
 
-	// Make buffer for writing encoding numbers.
-	var buf [8]byte
-	b := buf[:]
-
-	// Write field "ID".
-	binary.BigEndian.PutUint64(b, vh.fieldCnt)
-	_, err := vh.hasher.Write(b)
+	// Write field "ID" and length.
+	binary.BigEndian.PutUint64(vh.headerBuf[:8], vh.fieldCnt)
+	binary.BigEndian.PutUint64(vh.headerBuf[8:], length)
+	_, err := vh.hasher.Write(vh.headerBuf[:])
 	if err != nil {
 		panic(err)
 	}
+}
 
-	// Write field length.
-	binary.BigEndian.PutUint64(b, uint64(len(data)))
-	_, err = vh.hasher.Write(b)
+// AddReader hashes a field with all data from the reader.
+// If the reader implements io.Seeker, the remaining length is determined by
+// seeking and the data is streamed into the hasher. Otherwise, the data is
+// read into memory first, as the length must be known up front.
+// The result is the same as adding the data with Add.
+func (vh *ValueHasher) AddReader(r io.Reader) (n int64, err error) {
+	// Stream if length can be determined.
+	if seeker, ok := r.(io.Seeker); ok {
+		current, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+			return 0, err
+		}
+		n = end - current
+		return n, vh.AddReaderN(r, n)
+	}
+
+	// Otherwise read into memory.
+	data, err := io.ReadAll(r)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
+	vh.Add(data)
+	return int64(len(data)), nil
+}
 
-	// Write field data.
-	if len(data) > 0 {
-		_, err = vh.hasher.Write(data)
-		if err != nil {
-			panic(err)
-		}
+// AddReaderN hashes a field with exactly n bytes from the reader, streaming
+// them into the hasher. The result is the same as adding the data with Add.
+// If the reader returns fewer bytes, the field is incomplete and the
+// ValueHasher must be discarded.
+func (vh *ValueHasher) AddReaderN(r io.Reader, n int64) error {
+	if n < 0 {
+		return fmt.Errorf("invalid field length %d", n)
+	}
+
+	vh.addFieldHeader(uint64(n))
+	written, err := io.CopyN(vh.hasher, r, n)
+	switch {
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("field incomplete, got %d of %d bytes: %w", written, n, io.ErrUnexpectedEOF)
+	case err != nil:
+		return err
 	}
+	return nil
 }
 
 // AddString hashes a string field.
 func (vh *ValueHasher) AddString(data string) {
-	vh.Add([]byte(data))
+	vh.stringBuf = append(vh.stringBuf[:0], data...)
+	vh.Add(vh.stringBuf)
 }
 
 // AddUint hashes an uint field.
+// It is the same as AddUint64.
 func (vh *ValueHasher) AddUint(n uint64) {
-	var buf [8]byte
-	b := buf[:]
-	binary.BigEndian.PutUint64(b, n)
-	vh.Add(b)
+	vh.AddUint64(n)
+}
+
+// AddUint64 hashes an uint64 field as 8 bytes big-endian.
+func (vh *ValueHasher) AddUint64(n uint64) {
+	binary.BigEndian.PutUint64(vh.valueBuf[:], n)
+	vh.Add(vh.valueBuf[:])
+}
+
+// AddUint32 hashes an uint32 field as 4 bytes big-endian.
+func (vh *ValueHasher) AddUint32(n uint32) {
+	binary.BigEndian.PutUint32(vh.valueBuf[:4], n)
+	vh.Add(vh.valueBuf[:4])
+}
+
+// AddInt64 hashes an int64 field as 8 bytes big-endian two's complement.
+func (vh *ValueHasher) AddInt64(n int64) {
+	vh.AddUint64(uint64(n))
+}
+
+// AddBool hashes a bool field as a single byte of 1 (true) or 0 (false).
+func (vh *ValueHasher) AddBool(v bool) {
+	vh.valueBuf[0] = 0
+	if v {
+		vh.valueBuf[0] = 1
+	}
+	vh.Add(vh.valueBuf[:1])
+}
+
+// AddKeyed adds a field with a key, for example an entry of a map.
+// Both key and value are hashed as separate fields when finalizing.
+// Keyed fields must not be mixed with other fields in a single hash, as this
+// makes the result ambiguous.
+func (vh *ValueHasher) AddKeyed(key string, value []byte) {
+	vh.keyed = append(vh.keyed, keyedField{
+		key:   key,
+		value: bytes.Clone(value),
+	})
+}
+
+// SumSorted finalizes and returns the hash result, with the keyed fields
+// sorted by key (and value for duplicate keys), so that the insertion order
+// does not matter.
+func (vh *ValueHasher) SumSorted(dst []byte) []byte {
+	slices.SortFunc(vh.keyed, func(a, b keyedField) int {
+		if c := strings.Compare(a.key, b.key); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.value, b.value)
+	})
+	return vh.Sum(dst)
 }
 
 // Sum finalizes and returns the hash result.
+// Keyed fields are hashed in the order they were added.
 func (vh *ValueHasher) Sum(dst []byte) []byte {
-	// Create finisher.
-	finisher := [16]byte{
-		// Total field count.
-		0, 0, 0, 0,
-		0, 0, 0, 0,
-		// Use a max uint64 in the place of the "field length" of a next field as an otherwise impossible finalizer.
-		0xFF, 0xFF, 0xFF, 0xFF,
-		0xFF, 0xFF, 0xFF, 0xFF,
+	// Hash keyed fields.
+	for _, field := range vh.keyed {
+		vh.AddString(field.key)
+		vh.Add(field.value)
 	}
+	vh.keyed = nil
+
+	// Create finisher: the total field count, followed by a max uint64 in the
+	// place of the "field length" of a next field as an otherwise impossible
+	// finalizer.
+	finisher := vh.headerBuf[:]
 	binary.BigEndian.PutUint64(finisher[:8], vh.fieldCnt)
+	binary.BigEndian.PutUint64(finisher[8:], math.MaxUint64)
 
 	// Write finisher.
-	_, err := vh.hasher.Write(finisher[:])
+	_, err := vh.hasher.Write(finisher)
 	if err != nil {
 		panic(err)
 	}
@@ -4,10 +4,15 @@ package crop
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/binary"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/zeebo/blake3"
@@ -38,6 +43,10 @@ func TestHash_New_IsValid_AndDigestAgainstReference(t *testing.T) {
 		{"SHA3_384", SHA3_384, func(b []byte) []byte { sum := sha3.Sum384(b); return sum[:] }},
 		{"SHA3_512", SHA3_512, func(b []byte) []byte { sum := sha3.Sum512(b); return sum[:] }},
 
+		// SHAKE
+		{"SHAKE128", SHAKE128, func(b []byte) []byte { sum := make([]byte, 32); sha3.ShakeSum128(sum, b); return sum }},
+		{"SHAKE256", SHAKE256, func(b []byte) []byte { sum := make([]byte, 64); sha3.ShakeSum256(sum, b); return sum }},
+
 		// BLAKE2
 		{"BLAKE2s_256", BLAKE2s_256, func(b []byte) []byte { sum := blake2s.Sum256(b); return sum[:] }},
 		{"BLAKE2b_256", BLAKE2b_256, func(b []byte) []byte { sum := blake2b.Sum256(b); return sum[:] }},
@@ -86,6 +95,19 @@ func TestHash_New_IsValid_AndDigestAgainstReference(t *testing.T) {
 	}
 }
 
+func TestHash_Size(t *testing.T) {
+	for _, algo := range AllHashes() {
+		if algo.Size() != algo.New().Size() {
+			t.Errorf("%s: Size() = %d, want %d", algo, algo.Size(), algo.New().Size())
+		}
+	}
+
+	var unknown Hash = "UNKNOWN_ALGO"
+	if unknown.Size() != 0 {
+		t.Fatalf("expected Size() 0 for unknown algo")
+	}
+}
+
 func TestHash_IsValid_FalseForUnknown(t *testing.T) {
 	var unknown Hash = "UNKNOWN_ALGO"
 	if unknown.IsValid() {
@@ -103,6 +125,50 @@ func TestHash_Digest_PanicsOnInvalid(t *testing.T) {
 	_ = unknown.Digest([]byte("data"))
 }
 
+func TestHash_TryDigest(t *testing.T) {
+	data := []byte("data")
+	for _, algo := range AllHashes() {
+		got, err := algo.TryDigest(data)
+		if err != nil {
+			t.Fatalf("%s: TryDigest error: %v", algo, err)
+		}
+		if !bytes.Equal(got, algo.Digest(data)) {
+			t.Fatalf("%s: TryDigest mismatch with Digest", algo)
+		}
+	}
+
+	var unknown Hash = "NOT_A_HASH"
+	if _, err := unknown.TryDigest(data); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got: %v", err)
+	}
+}
+
+func TestHash_DigestInto(t *testing.T) {
+	data := []byte("data")
+	for _, algo := range AllHashes() {
+		dst := make([]byte, algo.Size())
+		if err := algo.DigestInto(data, dst); err != nil {
+			t.Fatalf("%s: DigestInto error: %v", algo, err)
+		}
+		if !bytes.Equal(dst, algo.Digest(data)) {
+			t.Fatalf("%s: DigestInto mismatch with Digest", algo)
+		}
+
+		// Wrong sized destination is rejected.
+		if err := algo.DigestInto(data, make([]byte, algo.Size()-1)); err == nil {
+			t.Fatalf("%s: expected error for short destination", algo)
+		}
+		if err := algo.DigestInto(data, make([]byte, algo.Size()+1)); err == nil {
+			t.Fatalf("%s: expected error for long destination", algo)
+		}
+	}
+
+	var unknown Hash = "NOT_A_HASH"
+	if err := unknown.DigestInto(data, make([]byte, 32)); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got: %v", err)
+	}
+}
+
 func TestHash_Verify(t *testing.T) {
 	data := []byte("some payload to hash and verify")
 
@@ -168,33 +234,26 @@ func TestValueHasher_Sum_FormatAndDeterminism(t *testing.T) {
 				t.Fatalf("algo.New() is nil")
 			}
 
-			// Expect finisher prefix (16 bytes): [fieldCnt(8)][0xFF * 8]
-			if len(sum) < 16+hasher.Size() {
-				t.Fatalf("sum too short: got %d, need at least %d", len(sum), 16+hasher.Size())
+			// Expect exactly one digest of the underlying hash.
+			if len(sum) != hasher.Size() {
+				t.Fatalf("sum size mismatch: got %d want %d", len(sum), hasher.Size())
 			}
-			prefix := sum[:16]
-			tail := sum[16:]
 
-			// Build expected finisher
+			// Build expected finisher: [fieldCnt(8)][0xFF * 8]
 			var finisher [16]byte
 			binary.BigEndian.PutUint64(finisher[:8], uint64(len(fields)))
 			for i := 8; i < 16; i++ {
 				finisher[i] = 0xFF
 			}
-			if !bytes.Equal(prefix, finisher[:]) {
-				t.Fatalf("finisher prefix mismatch\n got: %x\nwant: %x", prefix, finisher[:])
-			}
-			if len(tail) != hasher.Size() {
-				t.Fatalf("digest tail size mismatch: got %d want %d", len(tail), hasher.Size())
-			}
 
-			// Reconstruct the exact stream written by ValueHasher.Add and verify the digest.
+			// Reconstruct the exact stream written by ValueHasher.Add and Sum and verify the digest.
 			stream := buildValueHasherStream(fields)
 			_, _ = hasher.Write(stream)
+			_, _ = hasher.Write(finisher[:])
 			expectedDigest := hasher.Sum(nil)
 
-			if !bytes.Equal(tail, expectedDigest) {
-				t.Fatalf("tail digest mismatch\n got: %x\nwant: %x", tail, expectedDigest)
+			if !bytes.Equal(sum, expectedDigest) {
+				t.Fatalf("digest mismatch\n got: %x\nwant: %x", sum, expectedDigest)
 			}
 
 			// Determinism: re-run and expect the same output.
@@ -226,6 +285,37 @@ func TestValueHasher_AddString(t *testing.T) {
 	}
 }
 
+func TestValueHasher_TypedAdders(t *testing.T) {
+	algo := SHA2_256
+
+	vh1 := NewValueHasher(algo.New())
+	vh1.Add([]byte{0, 0, 0, 0, 0, 0, 0x01, 0x02})
+	vh1.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE})
+	vh1.Add([]byte{0, 0, 0x01, 0x02})
+	vh1.Add([]byte{1})
+	vh1.Add([]byte{0})
+
+	vh2 := NewValueHasher(algo.New())
+	vh2.AddUint64(0x0102)
+	vh2.AddInt64(-2)
+	vh2.AddUint32(0x0102)
+	vh2.AddBool(true)
+	vh2.AddBool(false)
+
+	if got1, got2 := vh1.Sum(nil), vh2.Sum(nil); !bytes.Equal(got1, got2) {
+		t.Fatalf("typed adders mismatch with Add\nAdd:   %x\nTyped: %x", got1, got2)
+	}
+
+	// Same value with different widths is different.
+	vh3 := NewValueHasher(algo.New())
+	vh3.AddUint32(1)
+	vh4 := NewValueHasher(algo.New())
+	vh4.AddUint64(1)
+	if bytes.Equal(vh3.Sum(nil), vh4.Sum(nil)) {
+		t.Fatalf("expected different result for uint32 and uint64")
+	}
+}
+
 func TestValueHasher_OrderMatters(t *testing.T) {
 	algo := BLAKE2b_256
 
@@ -254,6 +344,33 @@ func TestNewValueHasher_WithInvalidAlgo_PanicsOnUse(t *testing.T) {
 	vh.Add([]byte("data"))
 }
 
+func TestNewValueHasherChecked(t *testing.T) {
+	t.Parallel()
+
+	// Invalid algorithm fails up front.
+	if _, err := NewValueHasherChecked("NOPE"); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+
+	// Valid algorithm matches the unchecked constructor.
+	vh, err := NewValueHasherChecked(BLAKE3)
+	if err != nil {
+		t.Fatalf("create value hasher: %v", err)
+	}
+	vh.AddString("data")
+	ref := NewValueHasher(BLAKE3.New())
+	ref.AddString("data")
+	if !bytes.Equal(vh.Sum(nil), ref.Sum(nil)) {
+		t.Fatal("checked value hasher differs from unchecked")
+	}
+
+	// Challenges with an invalid hash fail cleanly instead of panicking.
+	hcc := &HashedContextChallenge{hash: "NOPE"}
+	if _, err := hcc.MakeResponse([]byte("challenge")); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
+
 // Helper to build the exact byte stream ValueHasher writes.
 func buildValueHasherStream(fields [][]byte) []byte {
 	var buf bytes.Buffer
@@ -285,3 +402,379 @@ func preview(b []byte) string {
 	}
 	return string(b[:maxLen]) + "..."
 }
+
+func TestHash_IsFIPSApproved(t *testing.T) {
+	t.Parallel()
+
+	approved := []Hash{
+		SHA2_224, SHA2_256, SHA2_384, SHA2_512, SHA2_512_224, SHA2_512_256,
+		SHA3_224, SHA3_256, SHA3_384, SHA3_512,
+		SHAKE128, SHAKE256,
+	}
+	notApproved := []Hash{
+		BLAKE2s_256, BLAKE2b_256, BLAKE2b_384, BLAKE2b_512,
+		BLAKE3,
+		Hash("invalid"),
+	}
+
+	for _, h := range approved {
+		if !h.IsFIPSApproved() {
+			t.Errorf("expected %s to be FIPS-approved", h)
+		}
+	}
+	for _, h := range notApproved {
+		if h.IsFIPSApproved() {
+			t.Errorf("expected %s to not be FIPS-approved", h)
+		}
+	}
+}
+
+func TestHash_HMAC(t *testing.T) {
+	key := []byte("secret key")
+	data := []byte("some payload to authenticate")
+
+	// Compare against crypto/hmac reference.
+	ref := hmac.New(sha256.New, key)
+	ref.Write(data)
+	if got := SHA2_256.HMAC(key, data); !bytes.Equal(got, ref.Sum(nil)) {
+		t.Fatalf("SHA2_256.HMAC mismatch: %x", got)
+	}
+
+	for _, algo := range AllHashes() {
+		t.Run(string(algo), func(t *testing.T) {
+			sum := algo.HMAC(key, data)
+			if len(sum) != algo.New().Size() {
+				t.Fatalf("HMAC size = %d, want %d", len(sum), algo.New().Size())
+			}
+
+			// Streaming matches one-shot.
+			mac := algo.NewHMAC(key)
+			mac.Write(data[:5])
+			mac.Write(data[5:])
+			if !bytes.Equal(mac.Sum(nil), sum) {
+				t.Fatalf("NewHMAC result does not match HMAC")
+			}
+
+			// Different key yields different MAC.
+			if bytes.Equal(algo.HMAC([]byte("other key"), data), sum) {
+				t.Fatalf("expected different MAC for different key")
+			}
+		})
+	}
+
+	// Invalid hash panics like Digest.
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("expected panic on invalid hash.HMAC, got none")
+		}
+	}()
+	var unknown Hash = "NOT_A_HASH"
+	_ = unknown.HMAC(key, data)
+}
+
+func TestParseHash(t *testing.T) {
+	for _, algo := range AllHashes() {
+		if !algo.IsValid() || algo.New() == nil {
+			t.Fatalf("%s listed in AllHashes but not usable", algo)
+		}
+
+		// Parsing is case-insensitive.
+		for _, name := range []string{algo.String(), strings.ToLower(algo.String()), strings.ToUpper(algo.String())} {
+			parsed, err := ParseHash(name)
+			if err != nil {
+				t.Fatalf("ParseHash(%q) error: %v", name, err)
+			}
+			if parsed != algo {
+				t.Fatalf("ParseHash(%q) = %s, want %s", name, parsed, algo)
+			}
+		}
+	}
+
+	for _, name := range []string{"", "NOT_A_HASH", "SHA2-256"} {
+		if _, err := ParseHash(name); !errors.Is(err, ErrInvalidFormat) {
+			t.Fatalf("ParseHash(%q): expected ErrInvalidFormat, got: %v", name, err)
+		}
+	}
+}
+
+func TestHash_XOF(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+
+	refs := map[Hash]func(out []byte){
+		BLAKE3: func(out []byte) {
+			h := blake3.New()
+			h.Write(data)
+			h.Digest().Read(out)
+		},
+		SHAKE128: func(out []byte) { sha3.ShakeSum128(out, data) },
+		SHAKE256: func(out []byte) { sha3.ShakeSum256(out, data) },
+	}
+	for algo, ref := range refs {
+		t.Run(string(algo), func(t *testing.T) {
+			xof, err := algo.XOF()
+			if err != nil {
+				t.Fatalf("XOF() error: %v", err)
+			}
+
+			// Write in parts, read in parts.
+			xof.Write(data[:10])
+			xof.Write(data[10:])
+			got := make([]byte, 200)
+			xof.Read(got[:7])
+			xof.Read(got[7:])
+
+			want := make([]byte, len(got))
+			ref(want)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("XOF output mismatch\n got:  %x\n want: %x", got, want)
+			}
+
+			// Prefix of output matches digest.
+			if digest := algo.Digest(data); !bytes.Equal(got[:len(digest)], digest) {
+				t.Fatalf("XOF output does not start with digest")
+			}
+
+			// Writing after reading fails.
+			if _, err := xof.Write(data); err == nil {
+				t.Fatalf("expected error when writing after reading")
+			}
+
+			// Reset starts over.
+			xof.Reset()
+			xof.Write(data)
+			again := make([]byte, len(got))
+			xof.Read(again)
+			if !bytes.Equal(again, want) {
+				t.Fatalf("XOF output after Reset mismatch")
+			}
+		})
+	}
+
+	// Fixed output hashes are not supported.
+	if _, err := SHA2_256.XOF(); err == nil {
+		t.Fatalf("expected error for fixed output hash")
+	}
+}
+
+func TestValueHasher_KeyedSorted(t *testing.T) {
+	algo := SHA2_256
+	m := map[string][]byte{
+		"alpha": []byte("1"),
+		"beta":  []byte("2"),
+		"gamma": []byte("3"),
+		"delta": nil,
+	}
+
+	// Map iteration order does not matter.
+	var first []byte
+	for i := range 10 {
+		vh := NewValueHasher(algo.New())
+		for k, v := range m {
+			vh.AddKeyed(k, v)
+		}
+		sum := vh.SumSorted(nil)
+		if i == 0 {
+			first = sum
+		} else if !bytes.Equal(sum, first) {
+			t.Fatalf("SumSorted depends on insertion order")
+		}
+	}
+
+	// Equals sorted key and value fields.
+	vh := NewValueHasher(algo.New())
+	for _, k := range []string{"alpha", "beta", "delta", "gamma"} {
+		vh.AddString(k)
+		vh.Add(m[k])
+	}
+	if !bytes.Equal(vh.Sum(nil), first) {
+		t.Fatalf("SumSorted does not match sorted fields")
+	}
+
+	// Keys are bound to their values.
+	vh = NewValueHasher(algo.New())
+	vh.AddKeyed("alpha", []byte("2"))
+	vh.AddKeyed("beta", []byte("1"))
+	vh.AddKeyed("gamma", []byte("3"))
+	vh.AddKeyed("delta", nil)
+	if bytes.Equal(vh.SumSorted(nil), first) {
+		t.Fatalf("expected different result for swapped values")
+	}
+}
+
+func TestValueHasher_AddReader(t *testing.T) {
+	algo := SHA2_256
+	blob := bytes.Repeat([]byte("large blob "), 100_000)
+
+	vh1 := NewValueHasher(algo.New())
+	vh1.AddString("before")
+	vh1.Add(blob)
+	vh1.AddString("after")
+	want := vh1.Sum(nil)
+
+	// Seekable reader is streamed.
+	vh2 := NewValueHasher(algo.New())
+	vh2.AddString("before")
+	n, err := vh2.AddReader(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatalf("AddReader error: %v", err)
+	}
+	if n != int64(len(blob)) {
+		t.Fatalf("AddReader n = %d, want %d", n, len(blob))
+	}
+	vh2.AddString("after")
+	if !bytes.Equal(vh2.Sum(nil), want) {
+		t.Fatalf("AddReader mismatch with Add")
+	}
+
+	// Non-seekable reader is buffered.
+	vh3 := NewValueHasher(algo.New())
+	vh3.AddString("before")
+	if _, err := vh3.AddReader(io.MultiReader(bytes.NewReader(blob))); err != nil {
+		t.Fatalf("AddReader error: %v", err)
+	}
+	vh3.AddString("after")
+	if !bytes.Equal(vh3.Sum(nil), want) {
+		t.Fatalf("AddReader (non-seekable) mismatch with Add")
+	}
+
+	// Known length is streamed.
+	vh4 := NewValueHasher(algo.New())
+	vh4.AddString("before")
+	if err := vh4.AddReaderN(io.MultiReader(bytes.NewReader(blob)), int64(len(blob))); err != nil {
+		t.Fatalf("AddReaderN error: %v", err)
+	}
+	vh4.AddString("after")
+	if !bytes.Equal(vh4.Sum(nil), want) {
+		t.Fatalf("AddReaderN mismatch with Add")
+	}
+
+	// Short reader fails.
+	vh5 := NewValueHasher(algo.New())
+	err = vh5.AddReaderN(bytes.NewReader(blob), int64(len(blob))+1)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got: %v", err)
+	}
+}
+
+func TestHash_DigestReader(t *testing.T) {
+	data := bytes.Repeat([]byte("streamed data "), 10_000)
+
+	for _, algo := range AllHashes() {
+		t.Run(string(algo), func(t *testing.T) {
+			got, err := algo.DigestReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("DigestReader error: %v", err)
+			}
+			if !bytes.Equal(got, algo.Digest(data)) {
+				t.Fatalf("DigestReader mismatch with Digest")
+			}
+		})
+	}
+
+	// File.
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	got, err := BLAKE3.DigestFile(path)
+	if err != nil {
+		t.Fatalf("DigestFile error: %v", err)
+	}
+	if !bytes.Equal(got, BLAKE3.Digest(data)) {
+		t.Fatalf("DigestFile mismatch with Digest")
+	}
+
+	// Errors.
+	if _, err := BLAKE3.DigestFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+	var unknown Hash = "NOT_A_HASH"
+	if _, err := unknown.DigestReader(bytes.NewReader(data)); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got: %v", err)
+	}
+}
+
+func TestHash_NewKeyed(t *testing.T) {
+	data := []byte("data to authenticate")
+
+	keySizes := map[Hash][2]int{
+		BLAKE2s_256: {1, 32},
+		BLAKE2b_256: {1, 64},
+		BLAKE2b_384: {1, 64},
+		BLAKE2b_512: {1, 64},
+		BLAKE3:      {32, 32},
+	}
+	for algo, sizes := range keySizes {
+		t.Run(string(algo), func(t *testing.T) {
+			key := bytes.Repeat([]byte{0x42}, sizes[1])
+			hasher, err := algo.NewKeyed(key)
+			if err != nil {
+				t.Fatalf("NewKeyed error: %v", err)
+			}
+			hasher.Write(data)
+			sum := hasher.Sum(nil)
+			if len(sum) != algo.Size() {
+				t.Fatalf("keyed sum size = %d, want %d", len(sum), algo.Size())
+			}
+			if bytes.Equal(sum, algo.Digest(data)) {
+				t.Fatalf("keyed sum equals unkeyed digest")
+			}
+
+			// Key sizes are validated.
+			if _, err := algo.NewKeyed(key[:sizes[0]]); err != nil {
+				t.Fatalf("NewKeyed with minimum key size error: %v", err)
+			}
+			if _, err := algo.NewKeyed(append(key, 0)); err == nil {
+				t.Fatalf("expected error for too long key")
+			}
+			if _, err := algo.NewKeyed(nil); err == nil {
+				t.Fatalf("expected error for empty key")
+			}
+		})
+	}
+
+	// Reference.
+	key := bytes.Repeat([]byte{0x42}, 32)
+	hasher, _ := BLAKE3.NewKeyed(key)
+	hasher.Write(data)
+	ref, _ := blake3.NewKeyed(key)
+	ref.Write(data)
+	if !bytes.Equal(hasher.Sum(nil), ref.Sum(nil)) {
+		t.Fatalf("BLAKE3 keyed mismatch with reference")
+	}
+
+	// Hashes without native keying are rejected.
+	if _, err := SHA2_256.NewKeyed(key); err == nil {
+		t.Fatalf("expected error for hash without native keying")
+	}
+}
+
+func TestHash_VerifyInto(t *testing.T) {
+	data := []byte("some payload to hash and verify")
+
+	for _, algo := range AllHashes() {
+		sum := algo.Digest(data)
+		scratch := make([]byte, algo.Size())
+
+		if err := algo.VerifyInto(data, sum, scratch); err != nil {
+			t.Fatalf("%s: VerifyInto error for matching checksum: %v", algo, err)
+		}
+		if !bytes.Equal(scratch, sum) {
+			t.Fatalf("%s: checksum not computed into scratch buffer", algo)
+		}
+
+		sum[0] ^= 0xFF
+		if err := algo.VerifyInto(data, sum, scratch); !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("%s: expected ErrChecksumMismatch, got %v", algo, err)
+		}
+
+		if err := algo.VerifyInto(data, sum, scratch[:len(scratch)-1]); err == nil {
+			t.Fatalf("%s: expected error for too small scratch buffer", algo)
+		}
+	}
+
+	var unknown Hash = "NOT_A_HASH"
+	if err := unknown.VerifyInto(data, nil, make([]byte, 64)); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got: %v", err)
+	}
+}
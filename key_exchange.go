@@ -1,9 +1,12 @@
 package crop
 
 import (
+	"crypto"
 	"crypto/ecdh"
-	"crypto/rand"
+	"crypto/mlkem"
+	"errors"
 	"fmt"
+	"io"
 )
 
 // KeyExchangeType identifies a key exchange algorithm.
@@ -12,35 +15,210 @@ type KeyExchangeType string
 const (
 	// KeyExchangeTypeX25519 is the X25519 Diffie-Hellman key exchange.
 	KeyExchangeTypeX25519 KeyExchangeType = "X25519"
+	// KeyExchangeTypeP256 is the ECDH key exchange over NIST P-256.
+	KeyExchangeTypeP256 KeyExchangeType = "P-256"
+	// KeyExchangeTypeP384 is the ECDH key exchange over NIST P-384.
+	KeyExchangeTypeP384 KeyExchangeType = "P-384"
+	// KeyExchangeTypeMLKEM768 is the ML-KEM-768 post-quantum key encapsulation mechanism.
+	KeyExchangeTypeMLKEM768 KeyExchangeType = "ML-KEM-768"
+	// KeyExchangeTypeX25519MLKEM768 is a hybrid of X25519 and ML-KEM-768.
+	KeyExchangeTypeX25519MLKEM768 KeyExchangeType = "X25519-ML-KEM-768"
+
+	hybridKeyExchangeContext = "_crop hybrid key exchange_"
 )
 
+// AllKeyExchangeTypes returns all supported key exchange types.
+func AllKeyExchangeTypes() []KeyExchangeType {
+	return []KeyExchangeType{
+		KeyExchangeTypeX25519,
+		KeyExchangeTypeP256,
+		KeyExchangeTypeP384,
+		KeyExchangeTypeMLKEM768,
+		KeyExchangeTypeX25519MLKEM768,
+	}
+}
+
 // IsValid returns whether this key exchange type is supported.
 func (kmt KeyExchangeType) IsValid() bool {
 	switch kmt {
 	case KeyExchangeTypeX25519:
 		return true
+	case KeyExchangeTypeP256:
+		return true
+	case KeyExchangeTypeP384:
+		return true
+	case KeyExchangeTypeMLKEM768:
+		return true
+	case KeyExchangeTypeX25519MLKEM768:
+		return true
+	}
+	return false
+}
+
+// IsFIPSApproved returns whether this key exchange type is FIPS-approved.
+func (kmt KeyExchangeType) IsFIPSApproved() bool {
+	switch kmt {
+	case KeyExchangeTypeP256, KeyExchangeTypeP384, KeyExchangeTypeMLKEM768:
+		return true
+	}
+	return false
+}
+
+// curve returns the elliptic curve for ECDH based key exchange types.
+func (kmt KeyExchangeType) curve() ecdh.Curve {
+	switch kmt {
+	case KeyExchangeTypeX25519:
+		return ecdh.X25519()
+	case KeyExchangeTypeP256:
+		return ecdh.P256()
+	case KeyExchangeTypeP384:
+		return ecdh.P384()
+	default:
+		return nil
+	}
+}
+
+// SupportsKeyMaker returns whether the shared secret of this key exchange type
+// may be used as material for the given key maker type.
+func (kmt KeyExchangeType) SupportsKeyMaker(keyMakerType KeyMakerType) bool {
+	switch kmt {
+	case KeyExchangeTypeX25519, KeyExchangeTypeP256, KeyExchangeTypeP384,
+		KeyExchangeTypeMLKEM768, KeyExchangeTypeX25519MLKEM768:
+		// Password based key makers are not suitable for shared secrets.
+		return keyMakerType.IsValid() && keyMakerType != KeyMakerTypeArgon2id
 	}
 	return false
 }
 
-// NewKeyExchange creates a new key exchange instance of the specified type.
+// NewKeyExchange creates a new key exchange instance of the specified type
+// for the initiator of the exchange.
 func NewKeyExchange(kmt KeyExchangeType) (KeyExchange, error) {
 	return kmt.New()
 }
 
+// NewKeyExchangeResponder creates a new key exchange instance of the specified
+// type for the responder of the exchange.
+func NewKeyExchangeResponder(kmt KeyExchangeType) (KeyExchange, error) {
+	return kmt.NewResponder()
+}
+
+// New creates a new key exchange instance for the initiator of the exchange.
 func (kmt KeyExchangeType) New() (KeyExchange, error) {
+	return kmt.newKeyExchange(false)
+}
+
+// NewResponder creates a new key exchange instance for the responder of the exchange.
+func (kmt KeyExchangeType) NewResponder() (KeyExchange, error) {
+	return kmt.newKeyExchange(true)
+}
+
+func (kmt KeyExchangeType) newKeyExchange(responder bool) (KeyExchange, error) {
 	if !kmt.IsValid() {
 		return nil, fmt.Errorf("invalid key exchange type: %q", kmt)
 	}
 
 	switch kmt {
-	case KeyExchangeTypeX25519:
-		privKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	case KeyExchangeTypeX25519, KeyExchangeTypeP256, KeyExchangeTypeP384:
+		return newECDHKeyExchange(kmt, responder)
+
+	case KeyExchangeTypeMLKEM768:
+		return newMLKEM768KeyExchange(responder)
+
+	case KeyExchangeTypeX25519MLKEM768:
+		x25519, err := newECDHKeyExchange(KeyExchangeTypeX25519, responder)
+		if err != nil {
+			return nil, err
+		}
+		mlkem768, err := newMLKEM768KeyExchange(responder)
+		if err != nil {
+			return nil, err
+		}
+		return &HybridX25519MLKEM768KeyExchange{
+			x25519:   x25519,
+			mlkem768: mlkem768,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("key exchange type %s not yet implemented", kmt)
+	}
+}
+
+func newECDHKeyExchange(kmt KeyExchangeType, responder bool) (*ECDHKeyExchange, error) {
+	curve := kmt.curve()
+	keySize := 32
+	if kmt == KeyExchangeTypeP384 {
+		keySize = 48
+	}
+
+	// Generate from raw key material to only depend on the package random source.
+	var (
+		keyMaterial [48]byte
+		privKey     *ecdh.PrivateKey
+	)
+	defer clear(keyMaterial[:])
+	err := generateWithRetry(func() (err error) {
+		_, err = io.ReadFull(randReader, keyMaterial[:keySize])
+		if err != nil {
+			return err
+		}
+		// NIST curves reject the rare scalars out of range of the curve order.
+		privKey, err = curve.NewPrivateKey(keyMaterial[:keySize])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ECDHKeyExchange{
+		keyExchangeType: kmt,
+		privKey:         privKey,
+		responder:       responder,
+	}, nil
+}
+
+func newMLKEM768KeyExchange(responder bool) (*MLKEM768KeyExchange, error) {
+	// The responder only encapsulates and needs no key.
+	if responder {
+		return &MLKEM768KeyExchange{}, nil
+	}
+
+	// Generate from seed to only depend on the package random source.
+	var seed [mlkem.SeedSize]byte
+	defer clear(seed[:])
+	err := generateWithRetry(func() (err error) {
+		_, err = io.ReadFull(randReader, seed[:])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	decapKey, err := mlkem.NewDecapsulationKey768(seed[:])
+	if err != nil {
+		return nil, err
+	}
+	return &MLKEM768KeyExchange{
+		initiator: true,
+		decapKey:  decapKey,
+	}, nil
+}
+
+// NewStaticKeyExchange creates a key exchange instance of the specified type
+// from an existing private key. Unlike key exchanges created with
+// NewKeyExchange, static key exchanges may be used with any number of peers.
+func NewStaticKeyExchange(kmt KeyExchangeType, privKey []byte) (KeyExchange, error) {
+	if !kmt.IsValid() {
+		return nil, fmt.Errorf("invalid key exchange type: %q", kmt)
+	}
+
+	switch kmt {
+	case KeyExchangeTypeX25519, KeyExchangeTypeP256, KeyExchangeTypeP384:
+		key, err := kmt.curve().NewPrivateKey(privKey)
 		if err != nil {
 			return nil, err
 		}
-		return &X25519KeyExchange{
-			privKey: privKey,
+		return &ECDHKeyExchange{
+			keyExchangeType: kmt,
+			privKey:         key,
+			static:          true,
 		}, nil
 
 	default:
@@ -52,54 +230,358 @@ func (kxt KeyExchangeType) String() string {
 	return string(kxt)
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (kxt KeyExchangeType) MarshalText() ([]byte, error) {
+	return []byte(kxt), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unknown names are rejected with ErrInvalidFormat.
+func (kxt *KeyExchangeType) UnmarshalText(text []byte) error {
+	parsed := KeyExchangeType(text)
+	if !parsed.IsValid() {
+		return fmt.Errorf("%w: unknown key exchange type %q", ErrInvalidFormat, text)
+	}
+	*kxt = parsed
+	return nil
+}
+
 // KeyExchange performs key agreement between two parties.
+// The initiator sends its exchange message first. For key encapsulation
+// mechanisms, the responder's exchange message is only available after it
+// called MakeKeys with the initiator's exchange message.
 type KeyExchange interface {
 	// Type returns the key exchange algorithm type.
 	Type() KeyExchangeType
-	// ExchangeMsg returns the public key to send to the peer.
+	// IsInitiator returns whether this is the initiating side of the exchange.
+	IsInitiator() bool
+	// ExchangeMsg returns the message to send to the peer.
 	ExchangeMsg() ([]byte, error)
+	// PublicKey returns the parsed public key of this side of the exchange.
+	// Returns nil if there is none, which is the case for the ML-KEM responder
+	// and after Burn.
+	PublicKey() crypto.PublicKey
 	// MakeKeys derives shared keys from the peer's public key.
+	// The shared secret is owned by the returned key maker and is only
+	// zeroized when the key maker is burned.
 	MakeKeys(exchMsg []byte, keyMakerType KeyMakerType) (KeyMaker, error)
+	// MakeSessionKey derives a single shared key from the peer's public key.
+	MakeSessionKey(exchMsg []byte, keyMakerType KeyMakerType, keyContext, keyParty string, keyLength int) ([]byte, error)
 	// Burn securely erases key material from memory.
 	Burn()
 }
 
-// X25519KeyExchange implements KeyExchange using X25519.
-type X25519KeyExchange struct {
-	privKey *ecdh.PrivateKey
-	used    bool // Prevents key reuse for security
+// ECDHKeyExchange implements KeyExchange using elliptic curve Diffie-Hellman
+// over X25519, P-256 or P-384.
+type ECDHKeyExchange struct {
+	keyExchangeType KeyExchangeType
+	privKey         *ecdh.PrivateKey
+	used            bool // Prevents key reuse for security
+	static          bool // Allows reuse for long-lived keys
+	responder       bool
+}
+
+// X25519KeyExchange is the previous name of ECDHKeyExchange.
+//
+// Deprecated: Use ECDHKeyExchange.
+type X25519KeyExchange = ECDHKeyExchange
+
+func (xke *ECDHKeyExchange) Type() KeyExchangeType {
+	return xke.keyExchangeType
 }
 
-func (xke *X25519KeyExchange) Type() KeyExchangeType {
-	return KeyExchangeTypeX25519
+func (xke *ECDHKeyExchange) IsInitiator() bool {
+	return !xke.responder
 }
 
-func (xke *X25519KeyExchange) ExchangeMsg() ([]byte, error) {
+func (xke *ECDHKeyExchange) ExchangeMsg() ([]byte, error) {
+	if xke.privKey == nil {
+		return nil, ErrNoPrivateKey
+	}
 	return xke.privKey.PublicKey().Bytes(), nil
 }
 
-func (xke *X25519KeyExchange) MakeKeys(exchMsg []byte, keyMakerType KeyMakerType) (KeyMaker, error) {
-	if xke.used {
+// PublicKey returns the *ecdh.PublicKey.
+func (xke *ECDHKeyExchange) PublicKey() crypto.PublicKey {
+	if xke.privKey == nil {
+		return nil
+	}
+	return xke.privKey.PublicKey()
+}
+
+func (xke *ECDHKeyExchange) MakeKeys(exchMsg []byte, keyMakerType KeyMakerType) (KeyMaker, error) {
+	if xke.used && !xke.static {
 		return nil, ErrCannotReuse
 	}
 
-	remotePubKey, err := ecdh.X25519().NewPublicKey(exchMsg)
+	keyMaterial, err := xke.sharedSecret(exchMsg)
 	if err != nil {
 		return nil, err
 	}
-	keyMaterial, err := xke.privKey.ECDH(remotePubKey)
+	// Mark as used as soon as the secret exists, so that a failure
+	// afterwards does not permit a retry with the same ephemeral key.
+	xke.used = true
+
+	return newExchangeKeyMaker(keyMakerType, keyMaterial)
+}
+
+// newExchangeKeyMaker creates a key maker from a shared secret.
+// On success, the key maker takes ownership of the key material.
+// On failure, the key material is zeroized.
+func newExchangeKeyMaker(keyMakerType KeyMakerType, keyMaterial []byte) (keyMaker KeyMaker, err error) {
+	defer func() {
+		if err != nil {
+			// TODO: Use guaranteed memory wiping as soon as Go supports it.
+			clear(keyMaterial)
+		}
+	}()
+
+	return keyMakerType.New(keyMaterial)
+}
+
+// sharedSecret computes the ECDH shared secret with the peer's public key.
+func (xke *ECDHKeyExchange) sharedSecret(exchMsg []byte) ([]byte, error) {
+	if xke.privKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	// Check length first for a descriptive error.
+	if expected := len(xke.privKey.PublicKey().Bytes()); len(exchMsg) != expected {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidExchangeMsg, expected, len(exchMsg))
+	}
+	remotePubKey, err := xke.privKey.Curve().NewPublicKey(exchMsg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExchangeMsg, err)
 	}
-	keyMaker, err := keyMakerType.New(keyMaterial)
+	return xke.privKey.ECDH(remotePubKey)
+}
+
+func (xke *ECDHKeyExchange) MakeSessionKey(exchMsg []byte, keyMakerType KeyMakerType, keyContext, keyParty string, keyLength int) ([]byte, error) {
+	keyMaker, err := xke.MakeKeys(exchMsg, keyMakerType)
 	if err != nil {
 		return nil, err
 	}
+	defer keyMaker.Burn()
+
+	return keyMaker.DeriveKey(keyContext, keyParty, keyLength)
+}
 
+func (xke *ECDHKeyExchange) Burn() {
+	// TODO: The ecdh private key keeps an internal copy of the scalar that
+	// cannot be wiped. Drop the reference and mark as used instead.
+	xke.privKey = nil
 	xke.used = true
+	xke.static = false
+}
+
+// MLKEM768KeyExchange implements KeyExchange using ML-KEM-768.
+// The initiator sends its encapsulation key, the responder encapsulates a
+// shared secret to it and sends back the ciphertext.
+type MLKEM768KeyExchange struct {
+	initiator  bool
+	decapKey   *mlkem.DecapsulationKey768 // Initiator only.
+	ciphertext []byte                     // Responder only, set by MakeKeys.
+	used       bool                       // Prevents key reuse for security
+}
+
+func (mke *MLKEM768KeyExchange) Type() KeyExchangeType {
+	return KeyExchangeTypeMLKEM768
+}
+
+func (mke *MLKEM768KeyExchange) IsInitiator() bool {
+	return mke.initiator
+}
+
+func (mke *MLKEM768KeyExchange) ExchangeMsg() ([]byte, error) {
+	if mke.initiator {
+		if mke.decapKey == nil {
+			return nil, ErrNoPrivateKey
+		}
+		return mke.decapKey.EncapsulationKey().Bytes(), nil
+	}
+	if mke.ciphertext == nil {
+		return nil, errors.New("responder exchange message is only available after MakeKeys")
+	}
+	return mke.ciphertext, nil
+}
+
+// PublicKey returns the *mlkem.EncapsulationKey768 of the initiator.
+// The responder has no public key.
+func (mke *MLKEM768KeyExchange) PublicKey() crypto.PublicKey {
+	if !mke.initiator || mke.decapKey == nil {
+		return nil
+	}
+	return mke.decapKey.EncapsulationKey()
+}
+
+func (mke *MLKEM768KeyExchange) MakeKeys(exchMsg []byte, keyMakerType KeyMakerType) (KeyMaker, error) {
+	if mke.used {
+		return nil, ErrCannotReuse
+	}
+
+	keyMaterial, ciphertext, err := mke.sharedSecret(exchMsg)
+	if err != nil {
+		return nil, err
+	}
+	// Mark as used as soon as the secret exists.
+	mke.used = true
+
+	keyMaker, err := newExchangeKeyMaker(keyMakerType, keyMaterial)
+	if err != nil {
+		return nil, err
+	}
+
+	mke.ciphertext = ciphertext
 	return keyMaker, nil
 }
 
-func (xke *X25519KeyExchange) Burn() {
-	// TODO: How can we destroy the ecdh private key?
+// sharedSecret decapsulates (initiator) or encapsulates (responder) the
+// shared secret. The ciphertext is only returned for the responder.
+func (mke *MLKEM768KeyExchange) sharedSecret(exchMsg []byte) (secret, ciphertext []byte, err error) {
+	// Initiator: Decapsulate shared secret from ciphertext.
+	if mke.initiator {
+		if mke.decapKey == nil {
+			return nil, nil, ErrNoPrivateKey
+		}
+		if len(exchMsg) != mlkem.CiphertextSize768 {
+			return nil, nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidExchangeMsg, mlkem.CiphertextSize768, len(exchMsg))
+		}
+		secret, err = mke.decapKey.Decapsulate(exchMsg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidExchangeMsg, err)
+		}
+		return secret, nil, nil
+	}
+
+	// Responder: Encapsulate shared secret to encapsulation key.
+	if len(exchMsg) != mlkem.EncapsulationKeySize768 {
+		return nil, nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidExchangeMsg, mlkem.EncapsulationKeySize768, len(exchMsg))
+	}
+	encapKey, err := mlkem.NewEncapsulationKey768(exchMsg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidExchangeMsg, err)
+	}
+	// Note: Encapsulate reads from crypto/rand and bypasses the package random source.
+	secret, ciphertext = encapKey.Encapsulate()
+	return secret, ciphertext, nil
+}
+
+func (mke *MLKEM768KeyExchange) MakeSessionKey(exchMsg []byte, keyMakerType KeyMakerType, keyContext, keyParty string, keyLength int) ([]byte, error) {
+	keyMaker, err := mke.MakeKeys(exchMsg, keyMakerType)
+	if err != nil {
+		return nil, err
+	}
+	defer keyMaker.Burn()
+
+	return keyMaker.DeriveKey(keyContext, keyParty, keyLength)
+}
+
+func (mke *MLKEM768KeyExchange) Burn() {
+	// TODO: How can we destroy the mlkem decapsulation key?
+	mke.decapKey = nil
+	mke.used = true
+}
+
+// HybridX25519MLKEM768KeyExchange implements KeyExchange by combining X25519
+// and ML-KEM-768, so that the exchange stays secure as long as one of them is.
+// The exchange message is the X25519 public key followed by the ML-KEM-768
+// message.
+type HybridX25519MLKEM768KeyExchange struct {
+	x25519   *ECDHKeyExchange
+	mlkem768 *MLKEM768KeyExchange
+	used     bool // Prevents key reuse for security
+}
+
+func (hke *HybridX25519MLKEM768KeyExchange) Type() KeyExchangeType {
+	return KeyExchangeTypeX25519MLKEM768
+}
+
+func (hke *HybridX25519MLKEM768KeyExchange) IsInitiator() bool {
+	return hke.mlkem768.IsInitiator()
+}
+
+func (hke *HybridX25519MLKEM768KeyExchange) ExchangeMsg() ([]byte, error) {
+	x25519Msg, err := hke.x25519.ExchangeMsg()
+	if err != nil {
+		return nil, err
+	}
+	mlkemMsg, err := hke.mlkem768.ExchangeMsg()
+	if err != nil {
+		return nil, err
+	}
+	return append(x25519Msg, mlkemMsg...), nil
+}
+
+// HybridX25519MLKEM768PublicKey holds the public keys of a hybrid key exchange.
+type HybridX25519MLKEM768PublicKey struct {
+	X25519   *ecdh.PublicKey
+	MLKEM768 *mlkem.EncapsulationKey768 // Initiator only.
+}
+
+// PublicKey returns a *HybridX25519MLKEM768PublicKey.
+func (hke *HybridX25519MLKEM768KeyExchange) PublicKey() crypto.PublicKey {
+	x25519PubKey, ok := hke.x25519.PublicKey().(*ecdh.PublicKey)
+	if !ok {
+		return nil
+	}
+	mlkemPubKey, _ := hke.mlkem768.PublicKey().(*mlkem.EncapsulationKey768)
+	return &HybridX25519MLKEM768PublicKey{
+		X25519:   x25519PubKey,
+		MLKEM768: mlkemPubKey,
+	}
+}
+
+func (hke *HybridX25519MLKEM768KeyExchange) MakeKeys(exchMsg []byte, keyMakerType KeyMakerType) (KeyMaker, error) {
+	if hke.used {
+		return nil, ErrCannotReuse
+	}
+
+	// Split exchange message.
+	if len(exchMsg) < 32 {
+		return nil, fmt.Errorf("%w: %w: expected at least 32 bytes, got %d", ErrInvalidExchangeMsg, ErrInvalidFormat, len(exchMsg))
+	}
+	x25519Msg, mlkemMsg := exchMsg[:32], exchMsg[32:]
+
+	// Compute both shared secrets.
+	x25519Secret, err := hke.x25519.sharedSecret(x25519Msg)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(x25519Secret)
+	mlkemSecret, ciphertext, err := hke.mlkem768.sharedSecret(mlkemMsg)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(mlkemSecret)
+	// Mark as used as soon as the secrets exist.
+	hke.used = true
+
+	// Combine shared secrets.
+	vh := NewValueHasher(BLAKE3.New())
+	vh.AddString(hybridKeyExchangeContext)
+	vh.Add(x25519Secret)
+	vh.Add(mlkemSecret)
+	keyMaker, err := newExchangeKeyMaker(keyMakerType, vh.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	hke.mlkem768.ciphertext = ciphertext
+	return keyMaker, nil
+}
+
+func (hke *HybridX25519MLKEM768KeyExchange) MakeSessionKey(exchMsg []byte, keyMakerType KeyMakerType, keyContext, keyParty string, keyLength int) ([]byte, error) {
+	keyMaker, err := hke.MakeKeys(exchMsg, keyMakerType)
+	if err != nil {
+		return nil, err
+	}
+	defer keyMaker.Burn()
+
+	return keyMaker.DeriveKey(keyContext, keyParty, keyLength)
+}
+
+func (hke *HybridX25519MLKEM768KeyExchange) Burn() {
+	hke.x25519.Burn()
+	hke.mlkem768.Burn()
+	hke.used = true
 }
@@ -5,7 +5,10 @@ package crop
 import (
 	"bytes"
 	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -123,6 +126,35 @@ func TestX25519_MakeKeys_ErrOnInvalidRemotePubKey(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected error when passing invalid remote public key bytes")
 	}
+	if !errors.Is(err, ErrInvalidExchangeMsg) {
+		t.Fatalf("expected ErrInvalidExchangeMsg, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "expected 32 bytes, got 5") {
+		t.Fatalf("expected error to include lengths, got %v", err)
+	}
+}
+
+func TestKeyExchange_MakeKeys_ErrInvalidExchangeMsg(t *testing.T) {
+	t.Parallel()
+
+	for _, kxt := range AllKeyExchangeTypes() {
+		for _, responder := range []bool{false, true} {
+			var ke KeyExchange
+			var err error
+			if responder {
+				ke, err = NewKeyExchangeResponder(kxt)
+			} else {
+				ke, err = NewKeyExchange(kxt)
+			}
+			if err != nil {
+				t.Fatalf("%s: create key exchange: %v", kxt, err)
+			}
+			_, err = ke.MakeKeys(make([]byte, 7), KeyMakerTypeBlake3)
+			if !errors.Is(err, ErrInvalidExchangeMsg) {
+				t.Errorf("%s (responder=%v): expected ErrInvalidExchangeMsg, got %v", kxt, responder, err)
+			}
+		}
+	}
 }
 
 func TestX25519_MakeKeys_ErrCannotReuse(t *testing.T) {
@@ -146,6 +178,34 @@ func TestX25519_MakeKeys_ErrCannotReuse(t *testing.T) {
 	}
 }
 
+func TestKeyExchange_MakeKeys_UsedAfterKeyMakerFailure(t *testing.T) {
+	t.Parallel()
+
+	for _, kxt := range AllKeyExchangeTypes() {
+		initiator, err := NewKeyExchange(kxt)
+		if err != nil {
+			t.Fatalf("%s: create initiator: %v", kxt, err)
+		}
+		initMsg, err := initiator.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("%s: initiator exchange msg: %v", kxt, err)
+		}
+		responder, err := NewKeyExchangeResponder(kxt)
+		if err != nil {
+			t.Fatalf("%s: create responder: %v", kxt, err)
+		}
+
+		// Fail key maker construction after the secret was derived.
+		if _, err := responder.MakeKeys(initMsg, KeyMakerType("invalid")); err == nil {
+			t.Fatalf("%s: expected key maker construction to fail", kxt)
+		}
+		// A retry must not recompute the secret.
+		if _, err := responder.MakeKeys(initMsg, KeyMakerTypeBlake3); !errors.Is(err, ErrCannotReuse) {
+			t.Fatalf("%s: expected ErrCannotReuse, got %v", kxt, err)
+		}
+	}
+}
+
 func TestX25519_TypeAndBurn_NoPanic(t *testing.T) {
 	t.Parallel()
 
@@ -158,6 +218,505 @@ func TestX25519_TypeAndBurn_NoPanic(t *testing.T) {
 		t.Fatalf("Type() = %q, want %q", ke.Type(), KeyExchangeTypeX25519)
 	}
 
-	// Burn is currently a no-op; ensure it doesn't panic.
+	// Burn must not panic and renders the instance unusable.
 	ke.Burn()
+	if _, err := ke.ExchangeMsg(); !errors.Is(err, ErrNoPrivateKey) {
+		t.Fatalf("expected ErrNoPrivateKey from ExchangeMsg after Burn, got %v", err)
+	}
+	if _, err := ke.MakeKeys(make([]byte, 32), KeyMakerTypeBlake3); err == nil {
+		t.Fatalf("expected MakeKeys to fail after Burn")
+	}
+}
+
+func TestKeyExchange_FailAfterBurn(t *testing.T) {
+	t.Parallel()
+
+	for _, kxt := range AllKeyExchangeTypes() {
+		initiator, err := NewKeyExchange(kxt)
+		if err != nil {
+			t.Fatalf("%s: NewKeyExchange error: %v", kxt, err)
+		}
+		responder, err := NewKeyExchangeResponder(kxt)
+		if err != nil {
+			t.Fatalf("%s: NewKeyExchangeResponder error: %v", kxt, err)
+		}
+		initMsg, err := initiator.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("%s: ExchangeMsg error: %v", kxt, err)
+		}
+
+		initiator.Burn()
+		responder.Burn()
+		if _, err := initiator.ExchangeMsg(); err == nil {
+			t.Fatalf("%s: expected ExchangeMsg to fail after Burn", kxt)
+		}
+		if _, err := responder.MakeKeys(initMsg, KeyMakerTypeBlake3); err == nil {
+			t.Fatalf("%s: expected MakeKeys to fail after Burn", kxt)
+		}
+	}
+
+	// Static key exchanges also stop working.
+	static, err := NewStaticKeyExchange(KeyExchangeTypeX25519, NewSecret(32))
+	if err != nil {
+		t.Fatalf("NewStaticKeyExchange error: %v", err)
+	}
+	peer, err := NewKeyExchange(KeyExchangeTypeX25519)
+	if err != nil {
+		t.Fatalf("NewKeyExchange error: %v", err)
+	}
+	peerMsg, err := peer.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("ExchangeMsg error: %v", err)
+	}
+	static.Burn()
+	if _, err := static.MakeKeys(peerMsg, KeyMakerTypeBlake3); err == nil {
+		t.Fatalf("expected static MakeKeys to fail after Burn")
+	}
+}
+
+func TestX25519_MakeSessionKey_MatchBetweenPeers(t *testing.T) {
+	t.Parallel()
+
+	alice, err := NewKeyExchange(KeyExchangeTypeX25519)
+	if err != nil {
+		t.Fatalf("alice NewKeyExchange error: %v", err)
+	}
+	bob, err := NewKeyExchange(KeyExchangeTypeX25519)
+	if err != nil {
+		t.Fatalf("bob NewKeyExchange error: %v", err)
+	}
+	aliceMsg, err := alice.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("alice.ExchangeMsg error: %v", err)
+	}
+	bobMsg, err := bob.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("bob.ExchangeMsg error: %v", err)
+	}
+
+	// Both peers derive the same session key.
+	aliceKey, err := alice.MakeSessionKey(bobMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+	if err != nil {
+		t.Fatalf("alice.MakeSessionKey error: %v", err)
+	}
+	bobKey, err := bob.MakeSessionKey(aliceMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+	if err != nil {
+		t.Fatalf("bob.MakeSessionKey error: %v", err)
+	}
+	if len(aliceKey) != 32 || !bytes.Equal(aliceKey, bobKey) {
+		t.Fatalf("session keys differ\nalice: %x\n  bob: %x", aliceKey, bobKey)
+	}
+
+	// The one-shot guard still applies.
+	_, err = alice.MakeSessionKey(bobMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+	if !errors.Is(err, ErrCannotReuse) {
+		t.Fatalf("expected ErrCannotReuse, got %v", err)
+	}
+}
+
+func TestKeyExchangeType_SupportsKeyMaker(t *testing.T) {
+	t.Parallel()
+
+	if !KeyExchangeTypeX25519.SupportsKeyMaker(KeyMakerTypeBlake3) {
+		t.Fatalf("expected X25519 to support BLAKE3 key maker")
+	}
+	if KeyExchangeTypeX25519.SupportsKeyMaker(KeyMakerType("invalid")) {
+		t.Fatalf("expected X25519 to not support invalid key maker")
+	}
+	if KeyExchangeType("invalid").SupportsKeyMaker(KeyMakerTypeBlake3) {
+		t.Fatalf("expected invalid key exchange to not support any key maker")
+	}
+}
+
+func TestNewStaticKeyExchange_AllowsReuse(t *testing.T) {
+	t.Parallel()
+
+	// Invalid input.
+	if _, err := NewStaticKeyExchange(KeyExchangeType("invalid"), make([]byte, 32)); err == nil {
+		t.Fatalf("expected error for invalid key exchange type")
+	}
+	if _, err := NewStaticKeyExchange(KeyExchangeTypeX25519, []byte("short")); err == nil {
+		t.Fatalf("expected error for invalid private key")
+	}
+
+	// Create static key exchange from existing key.
+	staticPriv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+	static, err := NewStaticKeyExchange(KeyExchangeTypeX25519, staticPriv.Bytes())
+	if err != nil {
+		t.Fatalf("NewStaticKeyExchange error: %v", err)
+	}
+	staticMsg, err := static.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("ExchangeMsg error: %v", err)
+	}
+	if !bytes.Equal(staticMsg, staticPriv.PublicKey().Bytes()) {
+		t.Fatalf("static exchange message does not match public key")
+	}
+
+	// Exchange with multiple ephemeral peers.
+	for i := range 3 {
+		peer, err := NewKeyExchange(KeyExchangeTypeX25519)
+		if err != nil {
+			t.Fatalf("NewKeyExchange error: %v", err)
+		}
+		peerMsg, err := peer.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("ExchangeMsg error: %v", err)
+		}
+		staticKey, err := static.MakeSessionKey(peerMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+		if err != nil {
+			t.Fatalf("peer %d: static MakeSessionKey error: %v", i, err)
+		}
+		peerKey, err := peer.MakeSessionKey(staticMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+		if err != nil {
+			t.Fatalf("peer %d: MakeSessionKey error: %v", i, err)
+		}
+		if !bytes.Equal(staticKey, peerKey) {
+			t.Fatalf("peer %d: session keys differ", i)
+		}
+
+		// Ephemeral peer cannot be reused.
+		if _, err := peer.MakeKeys(staticMsg, KeyMakerTypeBlake3); !errors.Is(err, ErrCannotReuse) {
+			t.Fatalf("peer %d: expected ErrCannotReuse, got %v", i, err)
+		}
+	}
+}
+
+func TestMLKEM768_InitiatorResponderFlow(t *testing.T) {
+	t.Parallel()
+
+	initiator, err := NewKeyExchange(KeyExchangeTypeMLKEM768)
+	if err != nil {
+		t.Fatalf("NewKeyExchange error: %v", err)
+	}
+	responder, err := NewKeyExchangeResponder(KeyExchangeTypeMLKEM768)
+	if err != nil {
+		t.Fatalf("NewKeyExchangeResponder error: %v", err)
+	}
+	if initiator.Type() != KeyExchangeTypeMLKEM768 {
+		t.Fatalf("Type() = %q, want %q", initiator.Type(), KeyExchangeTypeMLKEM768)
+	}
+	if !initiator.IsInitiator() || responder.IsInitiator() {
+		t.Fatalf("unexpected roles: initiator=%v responder=%v", initiator.IsInitiator(), responder.IsInitiator())
+	}
+
+	// Responder has no exchange message before MakeKeys.
+	if _, err := responder.ExchangeMsg(); err == nil {
+		t.Fatalf("expected error for responder ExchangeMsg before MakeKeys")
+	}
+
+	// Initiator sends encapsulation key.
+	initMsg, err := initiator.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("initiator ExchangeMsg error: %v", err)
+	}
+	if len(initMsg) != mlkem.EncapsulationKeySize768 {
+		t.Fatalf("initiator ExchangeMsg length = %d, want %d", len(initMsg), mlkem.EncapsulationKeySize768)
+	}
+
+	// Responder encapsulates and sends ciphertext.
+	respKey, err := responder.MakeSessionKey(initMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+	if err != nil {
+		t.Fatalf("responder MakeSessionKey error: %v", err)
+	}
+	respMsg, err := responder.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("responder ExchangeMsg error: %v", err)
+	}
+	if len(respMsg) != mlkem.CiphertextSize768 {
+		t.Fatalf("responder ExchangeMsg length = %d, want %d", len(respMsg), mlkem.CiphertextSize768)
+	}
+
+	// Initiator decapsulates.
+	initKey, err := initiator.MakeSessionKey(respMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+	if err != nil {
+		t.Fatalf("initiator MakeSessionKey error: %v", err)
+	}
+	if !bytes.Equal(initKey, respKey) {
+		t.Fatalf("session keys differ\ninitiator: %x\nresponder: %x", initKey, respKey)
+	}
+
+	// Both sides are one-shot.
+	if _, err := initiator.MakeKeys(respMsg, KeyMakerTypeBlake3); !errors.Is(err, ErrCannotReuse) {
+		t.Fatalf("expected ErrCannotReuse for initiator, got %v", err)
+	}
+	if _, err := responder.MakeKeys(initMsg, KeyMakerTypeBlake3); !errors.Is(err, ErrCannotReuse) {
+		t.Fatalf("expected ErrCannotReuse for responder, got %v", err)
+	}
+}
+
+func TestMLKEM768_MakeKeys_ErrOnInvalidInput(t *testing.T) {
+	t.Parallel()
+
+	initiator, err := NewKeyExchange(KeyExchangeTypeMLKEM768)
+	if err != nil {
+		t.Fatalf("NewKeyExchange error: %v", err)
+	}
+	if _, err := initiator.MakeKeys([]byte("short"), KeyMakerTypeBlake3); err == nil {
+		t.Fatalf("expected error for invalid ciphertext")
+	}
+	responder, err := NewKeyExchangeResponder(KeyExchangeTypeMLKEM768)
+	if err != nil {
+		t.Fatalf("NewKeyExchangeResponder error: %v", err)
+	}
+	if _, err := responder.MakeKeys([]byte("short"), KeyMakerTypeBlake3); err == nil {
+		t.Fatalf("expected error for invalid encapsulation key")
+	}
+}
+
+func TestX25519_Roles(t *testing.T) {
+	t.Parallel()
+
+	initiator, err := NewKeyExchange(KeyExchangeTypeX25519)
+	if err != nil {
+		t.Fatalf("NewKeyExchange error: %v", err)
+	}
+	responder, err := NewKeyExchangeResponder(KeyExchangeTypeX25519)
+	if err != nil {
+		t.Fatalf("NewKeyExchangeResponder error: %v", err)
+	}
+	if !initiator.IsInitiator() || responder.IsInitiator() {
+		t.Fatalf("unexpected roles: initiator=%v responder=%v", initiator.IsInitiator(), responder.IsInitiator())
+	}
+
+	// Diffie-Hellman responders can send their message right away.
+	if _, err := responder.ExchangeMsg(); err != nil {
+		t.Fatalf("responder ExchangeMsg error: %v", err)
+	}
+}
+
+func TestHybridX25519MLKEM768_Flow(t *testing.T) {
+	t.Parallel()
+
+	// Run a full exchange, optionally tampering with the responder message.
+	exchange := func(tamper func(msg []byte)) (initKey, respKey []byte) {
+		t.Helper()
+
+		initiator, err := NewKeyExchange(KeyExchangeTypeX25519MLKEM768)
+		if err != nil {
+			t.Fatalf("NewKeyExchange error: %v", err)
+		}
+		responder, err := NewKeyExchangeResponder(KeyExchangeTypeX25519MLKEM768)
+		if err != nil {
+			t.Fatalf("NewKeyExchangeResponder error: %v", err)
+		}
+		initMsg, err := initiator.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("initiator ExchangeMsg error: %v", err)
+		}
+		if len(initMsg) != 32+mlkem.EncapsulationKeySize768 {
+			t.Fatalf("initiator ExchangeMsg length = %d", len(initMsg))
+		}
+		respKey, err = responder.MakeSessionKey(initMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+		if err != nil {
+			t.Fatalf("responder MakeSessionKey error: %v", err)
+		}
+		respMsg, err := responder.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("responder ExchangeMsg error: %v", err)
+		}
+		if len(respMsg) != 32+mlkem.CiphertextSize768 {
+			t.Fatalf("responder ExchangeMsg length = %d", len(respMsg))
+		}
+		if tamper != nil {
+			tamper(respMsg)
+		}
+		initKey, err = initiator.MakeSessionKey(respMsg, KeyMakerTypeBlake3, "session", "shared", 32)
+		if err != nil {
+			t.Fatalf("initiator MakeSessionKey error: %v", err)
+		}
+		return initKey, respKey
+	}
+
+	// Untampered exchange yields same keys.
+	initKey, respKey := exchange(nil)
+	if !bytes.Equal(initKey, respKey) {
+		t.Fatalf("session keys differ\ninitiator: %x\nresponder: %x", initKey, respKey)
+	}
+
+	// Flipping the X25519 half yields different keys.
+	initKey, respKey = exchange(func(msg []byte) { msg[0] ^= 0x01 })
+	if bytes.Equal(initKey, respKey) {
+		t.Fatalf("expected different keys when tampering with X25519 half")
+	}
+
+	// Flipping the ML-KEM half yields different keys.
+	initKey, respKey = exchange(func(msg []byte) { msg[len(msg)-1] ^= 0x01 })
+	if bytes.Equal(initKey, respKey) {
+		t.Fatalf("expected different keys when tampering with ML-KEM half")
+	}
+
+	// Too short message is rejected.
+	ke, err := NewKeyExchange(KeyExchangeTypeX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("NewKeyExchange error: %v", err)
+	}
+	if _, err := ke.MakeKeys(make([]byte, 16), KeyMakerTypeBlake3); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("expected ErrInvalidFormat, got %v", err)
+	}
+}
+
+func TestECDH_NISTCurves(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		kxt    KeyExchangeType
+		curve  ecdh.Curve
+		msgLen int
+	}{
+		{KeyExchangeTypeP256, ecdh.P256(), 65},
+		{KeyExchangeTypeP384, ecdh.P384(), 97},
+	}
+	for _, test := range tests {
+		alice, err := NewKeyExchange(test.kxt)
+		if err != nil {
+			t.Fatalf("%s: NewKeyExchange error: %v", test.kxt, err)
+		}
+		bob, err := NewKeyExchangeResponder(test.kxt)
+		if err != nil {
+			t.Fatalf("%s: NewKeyExchangeResponder error: %v", test.kxt, err)
+		}
+		if alice.Type() != test.kxt {
+			t.Fatalf("Type() = %q, want %q", alice.Type(), test.kxt)
+		}
+		if !test.kxt.IsFIPSApproved() {
+			t.Fatalf("expected %s to be FIPS-approved", test.kxt)
+		}
+
+		// Exchange messages are valid uncompressed points.
+		aliceMsg, err := alice.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("%s: ExchangeMsg error: %v", test.kxt, err)
+		}
+		bobMsg, err := bob.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("%s: ExchangeMsg error: %v", test.kxt, err)
+		}
+		if len(aliceMsg) != test.msgLen || aliceMsg[0] != 0x04 {
+			t.Fatalf("%s: unexpected exchange message format: %x", test.kxt, aliceMsg)
+		}
+		if _, err := test.curve.NewPublicKey(aliceMsg); err != nil {
+			t.Fatalf("%s: invalid public key: %v", test.kxt, err)
+		}
+
+		// Both derive the same key.
+		aliceKey, err := alice.MakeSessionKey(bobMsg, KeyMakerTypeHKDFSHA256, "session", "shared", 32)
+		if err != nil {
+			t.Fatalf("%s: alice MakeSessionKey error: %v", test.kxt, err)
+		}
+		bobKey, err := bob.MakeSessionKey(aliceMsg, KeyMakerTypeHKDFSHA256, "session", "shared", 32)
+		if err != nil {
+			t.Fatalf("%s: bob MakeSessionKey error: %v", test.kxt, err)
+		}
+		if !bytes.Equal(aliceKey, bobKey) {
+			t.Fatalf("%s: session keys differ", test.kxt)
+		}
+	}
+
+	// A P-256 message cannot be used with a P-384 instance and vice versa.
+	p256, err := NewKeyExchange(KeyExchangeTypeP256)
+	if err != nil {
+		t.Fatalf("NewKeyExchange error: %v", err)
+	}
+	p384, err := NewKeyExchange(KeyExchangeTypeP384)
+	if err != nil {
+		t.Fatalf("NewKeyExchange error: %v", err)
+	}
+	p256Msg, err := p256.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("ExchangeMsg error: %v", err)
+	}
+	p384Msg, err := p384.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("ExchangeMsg error: %v", err)
+	}
+	if _, err := p384.MakeKeys(p256Msg, KeyMakerTypeBlake3); err == nil {
+		t.Fatalf("expected P-384 instance to reject P-256 message")
+	}
+	if _, err := p256.MakeKeys(p384Msg, KeyMakerTypeBlake3); err == nil {
+		t.Fatalf("expected P-256 instance to reject P-384 message")
+	}
+}
+
+func TestKeyExchange_PublicKey(t *testing.T) {
+	t.Parallel()
+
+	// ECDH public key matches the exchange message.
+	for _, kxt := range []KeyExchangeType{KeyExchangeTypeX25519, KeyExchangeTypeP256, KeyExchangeTypeP384} {
+		ke, err := NewKeyExchange(kxt)
+		if err != nil {
+			t.Fatalf("%s: create key exchange: %v", kxt, err)
+		}
+		exchMsg, err := ke.ExchangeMsg()
+		if err != nil {
+			t.Fatalf("%s: exchange msg: %v", kxt, err)
+		}
+		pubKey, ok := ke.PublicKey().(*ecdh.PublicKey)
+		if !ok {
+			t.Fatalf("%s: expected *ecdh.PublicKey, got %T", kxt, ke.PublicKey())
+		}
+		if !bytes.Equal(pubKey.Bytes(), exchMsg) {
+			t.Fatalf("%s: public key does not match exchange message", kxt)
+		}
+		ke.Burn()
+		if ke.PublicKey() != nil {
+			t.Fatalf("%s: expected no public key after burn", kxt)
+		}
+	}
+
+	// ML-KEM initiator has an encapsulation key, the responder has none.
+	initiator, err := NewKeyExchange(KeyExchangeTypeMLKEM768)
+	if err != nil {
+		t.Fatalf("create initiator: %v", err)
+	}
+	if _, ok := initiator.PublicKey().(*mlkem.EncapsulationKey768); !ok {
+		t.Fatalf("expected *mlkem.EncapsulationKey768, got %T", initiator.PublicKey())
+	}
+	responder, err := NewKeyExchangeResponder(KeyExchangeTypeMLKEM768)
+	if err != nil {
+		t.Fatalf("create responder: %v", err)
+	}
+	if responder.PublicKey() != nil {
+		t.Fatalf("expected no public key for responder")
+	}
+
+	// Hybrid.
+	hybrid, err := NewKeyExchange(KeyExchangeTypeX25519MLKEM768)
+	if err != nil {
+		t.Fatalf("create hybrid: %v", err)
+	}
+	hybridPubKey, ok := hybrid.PublicKey().(*HybridX25519MLKEM768PublicKey)
+	if !ok || hybridPubKey.X25519 == nil || hybridPubKey.MLKEM768 == nil {
+		t.Fatalf("expected complete hybrid public key, got %#v", hybrid.PublicKey())
+	}
+}
+
+func TestNewExchangeKeyMaker_ZeroizesOnFailure(t *testing.T) {
+	t.Parallel()
+
+	// Faulting key maker type: material is wiped.
+	material := bytes.Repeat([]byte{0xAA}, 32)
+	if _, err := newExchangeKeyMaker(KeyMakerTypeArgon2id, material); err == nil {
+		t.Fatal("expected key maker construction to fail")
+	}
+	if !bytes.Equal(material, make([]byte, 32)) {
+		t.Fatal("expected key material to be zeroed after failure")
+	}
+
+	// Success: ownership is transferred to the key maker.
+	material = bytes.Repeat([]byte{0xAA}, 32)
+	km, err := newExchangeKeyMaker(KeyMakerTypeBlake3, material)
+	if err != nil {
+		t.Fatalf("create key maker: %v", err)
+	}
+	if !bytes.Equal(material, bytes.Repeat([]byte{0xAA}, 32)) {
+		t.Fatal("expected key material to be untouched on success")
+	}
+	km.Burn()
+	if !bytes.Equal(material, make([]byte, 32)) {
+		t.Fatal("expected key material to be zeroed by key maker burn")
+	}
 }
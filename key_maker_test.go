@@ -4,17 +4,23 @@ package crop
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 	"testing"
 
 	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/hkdf"
 )
 
 func TestKeyMakerType_IsValid(t *testing.T) {
 	t.Parallel()
 
-	if !KeyMakerTypeBlake3.IsValid() {
-		t.Fatalf("expected KeyMakerTypeBlake3 to be valid")
+	for _, kmt := range AllKeyMakerTypes() {
+		if !kmt.IsValid() {
+			t.Fatalf("expected %s to be valid", kmt)
+		}
 	}
 	if KeyMakerType("NOPE").IsValid() {
 		t.Fatalf("expected unknown type to be invalid")
@@ -149,6 +155,11 @@ func TestBlake3Keymaker_DeriveKeyInto_MatchesReference(t *testing.T) {
 		t.Fatalf("DeriveKeyInto error: %v", err)
 	}
 
+	// Derivation context is exposed for reproduction
+	if got := km.(*Blake3Keymaker).DerivationContext(ctx, party); got != fullCtx {
+		t.Fatalf("DerivationContext = %q, want %q", got, fullCtx)
+	}
+
 	// Reference using the blake3 package directly
 	ref := make([]byte, 64)
 	blake3.DeriveKey(fullCtx, material, ref)
@@ -218,6 +229,40 @@ func TestBlake3Keymaker_Burn_ZeroizesMaterialAndCallerSlice(t *testing.T) {
 	}
 }
 
+func TestKeyMaker_Burn_OwnsMaterial(t *testing.T) {
+	t.Parallel()
+
+	// All key makers take ownership of the material.
+	for _, kmt := range []KeyMakerType{KeyMakerTypeBlake3, KeyMakerTypeHKDFSHA256, KeyMakerTypeHKDFSHA512} {
+		src := NewSecret(32)
+		km, err := NewKeyMaker(kmt, src)
+		if err != nil {
+			t.Fatalf("%s: NewKeyMaker error: %v", kmt, err)
+		}
+		km.Burn()
+		if !allZero(src) {
+			t.Fatalf("%s: caller-provided material not zeroized after Burn", kmt)
+		}
+	}
+
+	// Shared secret of a key exchange is owned by the key maker.
+	a, _ := NewKeyExchange(KeyExchangeTypeX25519)
+	b, _ := NewKeyExchangeResponder(KeyExchangeTypeX25519)
+	bMsg, _ := b.ExchangeMsg()
+	km, err := a.MakeKeys(bMsg, KeyMakerTypeBlake3)
+	if err != nil {
+		t.Fatalf("MakeKeys error: %v", err)
+	}
+	sharedSecret := km.(*Blake3Keymaker).material
+	if allZero(sharedSecret) {
+		t.Fatalf("test setup: shared secret should be non-zero")
+	}
+	km.Burn()
+	if !allZero(sharedSecret) {
+		t.Fatalf("shared secret not zeroized after Burn")
+	}
+}
+
 func allZero(b []byte) bool {
 	for _, v := range b {
 		if v != 0 {
@@ -226,3 +271,494 @@ func allZero(b []byte) bool {
 	}
 	return true
 }
+
+func TestCompareKeyMakers(t *testing.T) {
+	t.Parallel()
+
+	material := []byte("shared material")
+	km1, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker km1 error: %v", err)
+	}
+	km2, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker km2 error: %v", err)
+	}
+	km3, err := NewKeyMaker(KeyMakerTypeBlake3, []byte("other material"))
+	if err != nil {
+		t.Fatalf("NewKeyMaker km3 error: %v", err)
+	}
+
+	// Same algorithm and material must produce the same key.
+	aKey, bKey := CompareKeyMakers(km1, km2, "ctx", "party", 32)
+	if len(aKey) != 32 || !bytes.Equal(aKey, bKey) {
+		t.Fatalf("expected equal keys\na: %x\nb: %x", aKey, bKey)
+	}
+
+	// Different material must not collide.
+	aKey, bKey = CompareKeyMakers(km1, km3, "ctx", "party", 32)
+	if bytes.Equal(aKey, bKey) {
+		t.Fatalf("expected different keys for different material")
+	}
+
+	// Derivation errors panic.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected panic for too short key length")
+			}
+		}()
+		CompareKeyMakers(km1, km2, "ctx", "party", keyMakerMinKeySize-1)
+	}()
+}
+
+// CompareKeyMakers derives a key with the same parameters from both key makers
+// and returns both outputs for the caller to compare.
+// This supports running key makers in shadow mode when migrating between key
+// derivation algorithms. Panics if a key maker fails to derive the key.
+func CompareKeyMakers(a, b KeyMaker, context, party string, length int) (aKey, bKey []byte) {
+	aKey, err := a.DeriveKey(context, party, length)
+	if err != nil {
+		panic(fmt.Sprintf("key maker %s: %s", a.Type(), err))
+	}
+	bKey, err = b.DeriveKey(context, party, length)
+	if err != nil {
+		panic(fmt.Sprintf("key maker %s: %s", b.Type(), err))
+	}
+	return aKey, bKey
+}
+
+func TestCachingKeyMaker_MatchesUncachedAndEvicts(t *testing.T) {
+	t.Parallel()
+
+	material := []byte("cache material")
+	plain, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker error: %v", err)
+	}
+	inner, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker error: %v", err)
+	}
+	ckm := NewCachingKeyMaker(inner, 2)
+	if ckm.Type() != KeyMakerTypeBlake3 {
+		t.Fatalf("Type() = %q, want %q", ckm.Type(), KeyMakerTypeBlake3)
+	}
+
+	// Cached and uncached outputs match, on miss and on hit.
+	want, err := plain.DeriveKey("ctx", "party", 32)
+	if err != nil {
+		t.Fatalf("DeriveKey error: %v", err)
+	}
+	for range 2 {
+		got, err := ckm.DeriveKey("ctx", "party", 32)
+		if err != nil {
+			t.Fatalf("cached DeriveKey error: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("cached key mismatch\n got: %x\nwant: %x", got, want)
+		}
+		// Mutating the returned copy must not affect the cache.
+		got[0] ^= 0xFF
+	}
+
+	// Length is part of the cache key.
+	longer, err := ckm.DeriveKey("ctx", "party", 64)
+	if err != nil {
+		t.Fatalf("cached DeriveKey error: %v", err)
+	}
+	if len(longer) != 64 || allZero(longer) {
+		t.Fatalf("unexpected key for different length")
+	}
+
+	// Adding a third entry evicts the least recently used and zeroizes it.
+	ckm.lock.Lock()
+	evicted := ckm.entries[cachedKeyID{keyContext: "ctx", keyParty: "party", keyLength: 32}].Value.(*cachedKey).key
+	ckm.lock.Unlock()
+	if _, err := ckm.DeriveKey("other", "party", 32); err != nil {
+		t.Fatalf("cached DeriveKey error: %v", err)
+	}
+	if ckm.lru.Len() != 2 {
+		t.Fatalf("cache size = %d, want 2", ckm.lru.Len())
+	}
+	if !allZero(evicted) {
+		t.Fatalf("evicted key was not zeroized")
+	}
+
+	// Errors are passed through and not cached.
+	if _, err := ckm.DeriveKey("ctx", "party", keyMakerMinKeySize-1); !errors.Is(err, ErrRequestedKeyLengthTooSmall) {
+		t.Fatalf("expected ErrRequestedKeyLengthTooSmall, got %v", err)
+	}
+
+	// Burn clears the cache.
+	ckm.Burn()
+	if ckm.lru.Len() != 0 || len(ckm.entries) != 0 {
+		t.Fatalf("cache not empty after Burn")
+	}
+}
+
+func BenchmarkKeyMaker_DeriveKeyInto(b *testing.B) {
+	material := []byte("benchmark material")
+	b.Run("uncached", func(b *testing.B) {
+		km, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+		if err != nil {
+			b.Fatal(err)
+		}
+		dst := make([]byte, 32)
+		for b.Loop() {
+			if err := km.DeriveKeyInto("tenant", "server", dst); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		km, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ckm := NewCachingKeyMaker(km, 16)
+		dst := make([]byte, 32)
+		for b.Loop() {
+			if err := ckm.DeriveKeyInto("tenant", "server", dst); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestBlake3Keymaker_KeystreamReader(t *testing.T) {
+	t.Parallel()
+
+	material := []byte("keystream material")
+	km1, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker km1 error: %v", err)
+	}
+	km2, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker km2 error: %v", err)
+	}
+
+	const size = 1 << 20
+	r1, err := km1.KeystreamReader("stream", "party")
+	if err != nil {
+		t.Fatalf("KeystreamReader error: %v", err)
+	}
+	r2, err := km2.KeystreamReader("stream", "party")
+	if err != nil {
+		t.Fatalf("KeystreamReader error: %v", err)
+	}
+	stream1 := make([]byte, size)
+	stream2 := make([]byte, size)
+	if _, err := io.ReadFull(r1, stream1); err != nil {
+		t.Fatalf("read keystream 1: %v", err)
+	}
+	if _, err := io.ReadFull(r2, stream2); err != nil {
+		t.Fatalf("read keystream 2: %v", err)
+	}
+
+	// Both parties produce identical keystreams.
+	if !bytes.Equal(stream1, stream2) {
+		t.Fatalf("keystreams differ")
+	}
+
+	// Keystream does not repeat at block granularity.
+	seen := make(map[string]struct{}, size/64)
+	for i := 0; i < size; i += 64 {
+		block := string(stream1[i : i+64])
+		if _, ok := seen[block]; ok {
+			t.Fatalf("keystream repeats at offset %d", i)
+		}
+		seen[block] = struct{}{}
+	}
+
+	// Domain separation.
+	r3, err := km1.KeystreamReader("stream", "other")
+	if err != nil {
+		t.Fatalf("KeystreamReader error: %v", err)
+	}
+	stream3 := make([]byte, 64)
+	if _, err := io.ReadFull(r3, stream3); err != nil {
+		t.Fatalf("read keystream 3: %v", err)
+	}
+	if bytes.Equal(stream1[:64], stream3) {
+		t.Fatalf("expected different keystream for different party")
+	}
+
+	// Keystream must not reveal keys derived for the same context and party.
+	key, err := km1.DeriveKey("stream", "party", 64)
+	if err != nil {
+		t.Fatalf("DeriveKey error: %v", err)
+	}
+	if bytes.Equal(stream1[:64], key) {
+		t.Fatalf("keystream prefix equals derived key")
+	}
+}
+
+func TestHKDFKeymaker_DeriveKeyInto_MatchesReference(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		kmt  KeyMakerType
+		hash Hash
+	}{
+		{KeyMakerTypeHKDFSHA256, SHA2_256},
+		{KeyMakerTypeHKDFSHA512, SHA2_512},
+	}
+	for _, test := range tests {
+		material := []byte("ref material")
+		km, err := NewKeyMaker(test.kmt, append([]byte(nil), material...))
+		if err != nil {
+			t.Fatalf("NewKeyMaker(%s) error: %v", test.kmt, err)
+		}
+		if km.Type() != test.kmt {
+			t.Fatalf("Type() = %q, want %q", km.Type(), test.kmt)
+		}
+
+		dst, err := km.DeriveKey("ctx", "server", 64)
+		if err != nil {
+			t.Fatalf("%s: DeriveKey error: %v", test.kmt, err)
+		}
+
+		// Reference using the hkdf package directly.
+		ref := make([]byte, 64)
+		reader := hkdf.New(test.hash.New, material, nil, []byte(keyMakerBaseContext+"ctx"+"server"))
+		if _, err := io.ReadFull(reader, ref); err != nil {
+			t.Fatalf("%s: reference hkdf error: %v", test.kmt, err)
+		}
+		if !bytes.Equal(dst, ref) {
+			t.Fatalf("%s: derived key mismatch with reference\n got: %x\nwant: %x", test.kmt, dst, ref)
+		}
+
+		// Same minimum length as BLAKE3.
+		if _, err := km.DeriveKey("", "", keyMakerMinKeySize-1); !errors.Is(err, ErrRequestedKeyLengthTooSmall) {
+			t.Fatalf("%s: expected ErrRequestedKeyLengthTooSmall, got %v", test.kmt, err)
+		}
+
+		// Keystream is not supported.
+		if _, err := km.KeystreamReader("ctx", "server"); !errors.Is(err, errors.ErrUnsupported) {
+			t.Fatalf("%s: expected ErrUnsupported, got %v", test.kmt, err)
+		}
+
+		// Burn zeroizes material.
+		km.Burn()
+		if !allZero(km.(*HKDFKeymaker).material) {
+			t.Fatalf("%s: material not zeroized after Burn", test.kmt)
+		}
+	}
+}
+
+func TestCompareKeyMakers_HKDFAndBlake3DoNotCollide(t *testing.T) {
+	t.Parallel()
+
+	material := []byte("migration material")
+	hkdfKM, err := NewKeyMaker(KeyMakerTypeHKDFSHA256, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker error: %v", err)
+	}
+	b3KM, err := NewKeyMaker(KeyMakerTypeBlake3, append([]byte(nil), material...))
+	if err != nil {
+		t.Fatalf("NewKeyMaker error: %v", err)
+	}
+
+	aKey, bKey := CompareKeyMakers(hkdfKM, b3KM, "ctx", "party", 32)
+	if bytes.Equal(aKey, bKey) {
+		t.Fatalf("HKDF and BLAKE3 key makers produced colliding keys")
+	}
+}
+
+func TestArgon2idKeymaker(t *testing.T) {
+	t.Parallel()
+
+	// Use cheap parameters for testing.
+	params := Argon2Params{Time: 1, Memory: 64, Threads: 1}
+	salt := []byte("0123456789abcdef")
+
+	// Password based key makers need a dedicated constructor.
+	if _, err := NewKeyMaker(KeyMakerTypeArgon2id, []byte("password")); err == nil {
+		t.Fatalf("expected error creating Argon2id key maker without salt")
+	}
+
+	// Invalid parameters.
+	if _, err := NewArgon2idKeyMaker([]byte("password"), salt[:8], params); err == nil {
+		t.Fatalf("expected error for short salt")
+	}
+	if _, err := NewArgon2idKeyMaker([]byte("password"), salt, Argon2Params{Memory: 64, Threads: 1}); err == nil {
+		t.Fatalf("expected error for zero time")
+	}
+
+	password := []byte("correct horse battery staple")
+	km, err := NewArgon2idKeyMaker(password, salt, params)
+	if err != nil {
+		t.Fatalf("NewArgon2idKeyMaker error: %v", err)
+	}
+	if km.Type() != KeyMakerTypeArgon2id {
+		t.Fatalf("Type() = %q, want %q", km.Type(), KeyMakerTypeArgon2id)
+	}
+
+	// Deterministic and domain separated.
+	key1, err := km.DeriveKey("ctx", "party", 32)
+	if err != nil {
+		t.Fatalf("DeriveKey error: %v", err)
+	}
+	key2, err := km.DeriveKey("ctx", "party", 32)
+	if err != nil {
+		t.Fatalf("DeriveKey error: %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatalf("determinism failed\n1: %x\n2: %x", key1, key2)
+	}
+	key3, err := km.DeriveKey("ctx", "other", 32)
+	if err != nil {
+		t.Fatalf("DeriveKey error: %v", err)
+	}
+	if bytes.Equal(key1, key3) {
+		t.Fatalf("expected different keys when party changes")
+	}
+	if _, err := km.DeriveKey("", "", keyMakerMinKeySize-1); !errors.Is(err, ErrRequestedKeyLengthTooSmall) {
+		t.Fatalf("expected ErrRequestedKeyLengthTooSmall, got %v", err)
+	}
+
+	// Not usable with key exchanges.
+	if KeyExchangeTypeX25519.SupportsKeyMaker(KeyMakerTypeArgon2id) {
+		t.Fatalf("expected X25519 to not support Argon2id key maker")
+	}
+
+	// Burn zeroizes the password.
+	km.Burn()
+	if !allZero(password) {
+		t.Fatalf("password not zeroized after Burn")
+	}
+}
+
+func TestKeyMaker_DeriveKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, kmt := range []KeyMakerType{KeyMakerTypeBlake3, KeyMakerTypeHKDFSHA256} {
+		km, err := NewKeyMaker(kmt, []byte("handshake secret"))
+		if err != nil {
+			t.Fatalf("NewKeyMaker(%s) error: %v", kmt, err)
+		}
+
+		specs := []KeySpec{
+			{Party: "client-key", Length: 32},
+			{Party: "client-iv", Length: 16},
+			{Party: "server-key", Length: 32},
+			{Party: "server-iv", Length: 16},
+		}
+		keys, err := km.DeriveKeys("handshake", specs)
+		if err != nil {
+			t.Fatalf("%s: DeriveKeys error: %v", kmt, err)
+		}
+		if len(keys) != len(specs) {
+			t.Fatalf("%s: got %d keys, want %d", kmt, len(keys), len(specs))
+		}
+
+		// Same order and same domain separation as DeriveKey.
+		for i, spec := range specs {
+			want, err := km.DeriveKey("handshake", spec.Party, spec.Length)
+			if err != nil {
+				t.Fatalf("%s: DeriveKey error: %v", kmt, err)
+			}
+			if !bytes.Equal(keys[i], want) {
+				t.Fatalf("%s: key %d mismatch\n got: %x\nwant: %x", kmt, i, keys[i], want)
+			}
+		}
+
+		// Fails atomically if any length is too small.
+		specs = append(specs, KeySpec{Party: "short", Length: keyMakerMinKeySize - 1})
+		keys, err = km.DeriveKeys("handshake", specs)
+		if !errors.Is(err, ErrRequestedKeyLengthTooSmall) {
+			t.Fatalf("%s: expected ErrRequestedKeyLengthTooSmall, got %v", kmt, err)
+		}
+		if keys != nil {
+			t.Fatalf("%s: expected no keys on error", kmt)
+		}
+	}
+}
+
+func TestKeyMaker_KnownAnswers(t *testing.T) {
+	t.Parallel()
+
+	// Pin the base context and derived outputs, so that they never silently change.
+	if keyMakerBaseContext != "_crop key maker_" {
+		t.Fatalf("key maker base context changed: %q", keyMakerBaseContext)
+	}
+
+	tests := map[KeyMakerType]string{
+		KeyMakerTypeBlake3:     "9c04d6920e4cb11bbd93a60dddddf369a8be9e2578d977b7d1cae435f99f764f",
+		KeyMakerTypeHKDFSHA256: "5de2e6111473ec83ab64a0c57b460ec7c1e92cd2b84f99f2eabf4a928f192df7",
+		KeyMakerTypeHKDFSHA512: "427358af8239f66c5911e3fe810fce658f81d364bc728e06b81c4f6ecf0966c3",
+	}
+	for kmt, want := range tests {
+		km, err := NewKeyMaker(kmt, []byte("known answer material"))
+		if err != nil {
+			t.Fatalf("NewKeyMaker(%s) error: %v", kmt, err)
+		}
+		key, err := km.DeriveKey("ctx", "party", 32)
+		if err != nil {
+			t.Fatalf("%s: DeriveKey error: %v", kmt, err)
+		}
+		if got := hex.EncodeToString(key); got != want {
+			t.Fatalf("%s: derived key changed\n got: %s\nwant: %s", kmt, got, want)
+		}
+	}
+}
+
+func TestKeyMaker_DeriveKeyIntoWithInfo(t *testing.T) {
+	t.Parallel()
+
+	newKeyMakers := func() []KeyMaker {
+		argon2km, err := NewArgon2idKeyMaker([]byte("password"), []byte("0123456789abcdef"), Argon2Params{Time: 1, Memory: 64, Threads: 1})
+		if err != nil {
+			t.Fatalf("create Argon2id key maker: %v", err)
+		}
+		kms := []KeyMaker{argon2km}
+		for _, kmt := range []KeyMakerType{KeyMakerTypeBlake3, KeyMakerTypeHKDFSHA256, KeyMakerTypeHKDFSHA512} {
+			km, err := NewKeyMaker(kmt, bytes.Repeat([]byte{0x42}, 32))
+			if err != nil {
+				t.Fatalf("create %s key maker: %v", kmt, err)
+			}
+			kms = append(kms, km, NewCachingKeyMaker(km, 4))
+		}
+		return kms
+	}
+
+	for _, km := range newKeyMakers() {
+		derive := func(info []byte) []byte {
+			t.Helper()
+
+			dst := make([]byte, 32)
+			if err := km.DeriveKeyIntoWithInfo("ctx", "party", info, dst); err != nil {
+				t.Fatalf("%s: derive with info: %v", km.Type(), err)
+			}
+			return dst
+		}
+
+		// Empty info matches DeriveKeyInto.
+		plain := make([]byte, 32)
+		if err := km.DeriveKeyInto("ctx", "party", plain); err != nil {
+			t.Fatalf("%s: derive: %v", km.Type(), err)
+		}
+		if !bytes.Equal(derive(nil), plain) {
+			t.Fatalf("%s: empty info does not match DeriveKeyInto", km.Type())
+		}
+
+		// Info is deterministic and domain separated.
+		transcript := BLAKE3.Digest([]byte("transcript"))
+		if !bytes.Equal(derive(transcript), derive(transcript)) {
+			t.Fatalf("%s: derive with info is not deterministic", km.Type())
+		}
+		if bytes.Equal(derive(transcript), plain) {
+			t.Fatalf("%s: info does not change the key", km.Type())
+		}
+		if bytes.Equal(derive(transcript), derive([]byte("other transcript"))) {
+			t.Fatalf("%s: different info yields same key", km.Type())
+		}
+
+		// Minimum length is enforced.
+		if err := km.DeriveKeyIntoWithInfo("ctx", "party", transcript, make([]byte, 8)); !errors.Is(err, ErrRequestedKeyLengthTooSmall) {
+			t.Fatalf("%s: expected ErrRequestedKeyLengthTooSmall, got %v", km.Type(), err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package crop
+
+import "fmt"
+
+const keyGenMaxAttempts = 3
+
+// generateWithRetry calls generate up to keyGenMaxAttempts times until it
+// succeeds. This prevents a momentary failure of the random source from
+// failing key generation altogether.
+// Only the generate function itself is retried, so deterministic errors, such
+// as an invalid algorithm type, must be checked before and are never retried.
+func generateWithRetry(generate func() error) error {
+	var err error
+	for range keyGenMaxAttempts {
+		err = generate()
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w after %d attempts: %w", ErrKeyGeneration, keyGenMaxAttempts, err)
+}
@@ -0,0 +1,74 @@
+package crop
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// flakyReader fails the first n reads and then reads from crypto/rand.
+type flakyReader struct {
+	failures int
+}
+
+func (fr *flakyReader) Read(p []byte) (int, error) {
+	if fr.failures > 0 {
+		fr.failures--
+		return 0, errors.New("transient rng failure")
+	}
+	return rand.Read(p)
+}
+
+// useRandReader replaces the package random source for the duration of the test.
+// Note: Tests using this must not run in parallel.
+func useRandReader(t *testing.T, r io.Reader) {
+	t.Helper()
+
+	randReader = r
+	t.Cleanup(func() {
+		randReader = rand.Reader
+	})
+}
+
+func TestKeyGeneration_RecoversFromTransientFailure(t *testing.T) {
+	// Note: Must not run in parallel, as it replaces the package random source.
+
+	for _, kpt := range AllKeyPairTypes() {
+		t.Run(string(kpt), func(t *testing.T) {
+			useRandReader(t, &flakyReader{failures: keyGenMaxAttempts - 1})
+			if _, err := NewKeyPair(kpt); err != nil {
+				t.Fatalf("expected key generation to succeed after retries: %v", err)
+			}
+		})
+	}
+	for _, kxt := range AllKeyExchangeTypes() {
+		t.Run(string(kxt), func(t *testing.T) {
+			useRandReader(t, &flakyReader{failures: keyGenMaxAttempts - 1})
+			if _, err := NewKeyExchange(kxt); err != nil {
+				t.Fatalf("expected key generation to succeed after retries: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyGeneration_GivesUp(t *testing.T) {
+	// Note: Must not run in parallel, as it replaces the package random source.
+
+	for _, kpt := range AllKeyPairTypes() {
+		t.Run(string(kpt), func(t *testing.T) {
+			useRandReader(t, &flakyReader{failures: keyGenMaxAttempts})
+			if _, err := NewKeyPair(kpt); !errors.Is(err, ErrKeyGeneration) {
+				t.Fatalf("expected ErrKeyGeneration, got %v", err)
+			}
+		})
+	}
+	for _, kxt := range AllKeyExchangeTypes() {
+		t.Run(string(kxt), func(t *testing.T) {
+			useRandReader(t, &flakyReader{failures: keyGenMaxAttempts})
+			if _, err := NewKeyExchange(kxt); !errors.Is(err, ErrKeyGeneration) {
+				t.Fatalf("expected ErrKeyGeneration, got %v", err)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package crop
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/hkdf"
 )
 
 // KeyMakerType identifies a key derivation algorithm.
@@ -12,22 +17,58 @@ type KeyMakerType string
 const (
 	// KeyMakerTypeBlake3 derives keys using BLAKE3.
 	KeyMakerTypeBlake3 KeyMakerType = "BLAKE3"
+	// KeyMakerTypeHKDFSHA256 derives keys using HKDF with SHA2-256.
+	KeyMakerTypeHKDFSHA256 KeyMakerType = "HKDF-SHA256"
+	// KeyMakerTypeHKDFSHA512 derives keys using HKDF with SHA2-512.
+	KeyMakerTypeHKDFSHA512 KeyMakerType = "HKDF-SHA512"
+	// KeyMakerTypeArgon2id derives keys from passwords using Argon2id.
+	// Create with NewArgon2idKeyMaker.
+	KeyMakerTypeArgon2id KeyMakerType = "Argon2id"
 
-	keyMakerBaseContext = "_crop key maker_"
+	keyMakerBaseContext      = "_crop key maker_"
+	keyMakerKeystreamContext = "_crop key maker keystream_"
 
 	keyMakerMinKeySize = 16
 )
 
+// AllKeyMakerTypes returns all supported key maker types.
+func AllKeyMakerTypes() []KeyMakerType {
+	return []KeyMakerType{
+		KeyMakerTypeBlake3,
+		KeyMakerTypeHKDFSHA256,
+		KeyMakerTypeHKDFSHA512,
+		KeyMakerTypeArgon2id,
+	}
+}
+
 // IsValid returns whether this key maker type is supported.
 func (kmt KeyMakerType) IsValid() bool {
 	switch kmt {
 	case KeyMakerTypeBlake3:
 		return true
+	case KeyMakerTypeHKDFSHA256:
+		return true
+	case KeyMakerTypeHKDFSHA512:
+		return true
+	case KeyMakerTypeArgon2id:
+		return true
+	}
+	return false
+}
+
+// IsFIPSApproved returns whether this key maker type is FIPS-approved.
+func (kmt KeyMakerType) IsFIPSApproved() bool {
+	switch kmt {
+	case KeyMakerTypeHKDFSHA256, KeyMakerTypeHKDFSHA512:
+		return true
 	}
 	return false
 }
 
 // NewKeyMaker creates a new key derivation instance from key material.
+// The key maker takes ownership of the key material: it is not copied and is
+// zeroized when the key maker is burned. Do not use or modify the key material
+// afterwards.
 func NewKeyMaker(kmt KeyMakerType, key []byte) (KeyMaker, error) {
 	return kmt.New(key)
 }
@@ -43,6 +84,23 @@ func (kmt KeyMakerType) New(keyMaterial []byte) (KeyMaker, error) {
 			material: keyMaterial,
 		}, nil
 
+	case KeyMakerTypeHKDFSHA256:
+		return &HKDFKeymaker{
+			keyMakerType: KeyMakerTypeHKDFSHA256,
+			hash:         SHA2_256,
+			material:     keyMaterial,
+		}, nil
+
+	case KeyMakerTypeHKDFSHA512:
+		return &HKDFKeymaker{
+			keyMakerType: KeyMakerTypeHKDFSHA512,
+			hash:         SHA2_512,
+			material:     keyMaterial,
+		}, nil
+
+	case KeyMakerTypeArgon2id:
+		return nil, fmt.Errorf("key maker type %s requires a salt, use NewArgon2idKeyMaker", kmt)
+
 	default:
 		return nil, fmt.Errorf("key maker type %s not yet implemented", kmt)
 	}
@@ -52,6 +110,22 @@ func (kmt KeyMakerType) String() string {
 	return string(kmt)
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (kmt KeyMakerType) MarshalText() ([]byte, error) {
+	return []byte(kmt), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unknown names are rejected with ErrInvalidFormat.
+func (kmt *KeyMakerType) UnmarshalText(text []byte) error {
+	parsed := KeyMakerType(text)
+	if !parsed.IsValid() {
+		return fmt.Errorf("%w: unknown key maker type %q", ErrInvalidFormat, text)
+	}
+	*kmt = parsed
+	return nil
+}
+
 // KeyMaker derives multiple keys from shared key material.
 type KeyMaker interface {
 	// Type returns the key maker algorithm type.
@@ -60,10 +134,59 @@ type KeyMaker interface {
 	DeriveKey(keyContext, keyParty string, keyLength int) ([]byte, error)
 	// DeriveKeyInto writes a derived key directly into dst.
 	DeriveKeyInto(keyContext, keyParty string, dst []byte) error
+	// DeriveKeyIntoWithInfo writes a derived key directly into dst and
+	// additionally binds it to info, eg. a transcript hash of a handshake.
+	// Empty info derives the same key as DeriveKeyInto.
+	DeriveKeyIntoWithInfo(keyContext, keyParty string, info, dst []byte) error
+	// DeriveKeys creates multiple keys with the same context, in the order of the given specs.
+	DeriveKeys(keyContext string, specs []KeySpec) ([][]byte, error)
+	// KeystreamReader returns an endless keystream with domain separation.
+	// The keystream is not authenticated and must be paired with a MAC.
+	KeystreamReader(keyContext, keyParty string) (io.Reader, error)
 	// Burn securely erases key material from memory.
 	Burn()
 }
 
+// KeySpec specifies a key to derive with DeriveKeys.
+type KeySpec struct {
+	Party  string
+	Length int
+}
+
+// infoSuffix returns the encoding of info that is appended to the derivation
+// input. The length is appended, so that info cannot be confused with the
+// preceding input. Empty info is encoded as nothing to stay compatible with
+// derivations without info.
+func infoSuffix(info []byte) []byte {
+	if len(info) == 0 {
+		return nil
+	}
+	return binary.BigEndian.AppendUint64(bytes.Clone(info), uint64(len(info)))
+}
+
+// deriveKeys implements DeriveKeys for any KeyMaker.
+// It checks all specs before deriving any key.
+func deriveKeys(km KeyMaker, keyContext string, specs []KeySpec) ([][]byte, error) {
+	for _, spec := range specs {
+		if spec.Length < keyMakerMinKeySize {
+			return nil, fmt.Errorf("%w: key for party %q", ErrRequestedKeyLengthTooSmall, spec.Party)
+		}
+	}
+
+	keys := make([][]byte, 0, len(specs))
+	for _, spec := range specs {
+		key, err := km.DeriveKey(keyContext, spec.Party, spec.Length)
+		if err != nil {
+			for _, k := range keys {
+				clear(k)
+			}
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
 // Blake3Keymaker implements KeyMaker using BLAKE3 key derivation.
 type Blake3Keymaker struct {
 	material []byte
@@ -79,14 +202,89 @@ func (b3km *Blake3Keymaker) DeriveKey(keyContext, keyParty string, keyLength int
 }
 
 func (b3km *Blake3Keymaker) DeriveKeyInto(keyContext, keyParty string, dst []byte) error {
+	return b3km.DeriveKeyIntoWithInfo(keyContext, keyParty, nil, dst)
+}
+
+// DeriveKeyIntoWithInfo appends info to the key material.
+func (b3km *Blake3Keymaker) DeriveKeyIntoWithInfo(keyContext, keyParty string, info, dst []byte) error {
 	if len(dst) < keyMakerMinKeySize {
 		return ErrRequestedKeyLengthTooSmall
 	}
 
-	blake3.DeriveKey(keyMakerBaseContext+keyContext+keyParty, b3km.material, dst)
-	return nil
+	if len(info) == 0 {
+		blake3.DeriveKey(b3km.DerivationContext(keyContext, keyParty), b3km.material, dst)
+		return nil
+	}
+	hasher := blake3.NewDeriveKey(b3km.DerivationContext(keyContext, keyParty))
+	_, _ = hasher.Write(b3km.material) // Never returns an error.
+	_, _ = hasher.Write(infoSuffix(info))
+	_, err := io.ReadFull(hasher.Digest(), dst)
+	return err
+}
+
+func (b3km *Blake3Keymaker) DeriveKeys(keyContext string, specs []KeySpec) ([][]byte, error) {
+	return deriveKeys(b3km, keyContext, specs)
+}
+
+// KeystreamReader uses a separate derivation context from DeriveKey, so that
+// the keystream never reveals a key derived for the same context and party.
+func (b3km *Blake3Keymaker) KeystreamReader(keyContext, keyParty string) (io.Reader, error) {
+	hasher := blake3.NewDeriveKey(keyMakerKeystreamContext + keyContext + keyParty)
+	_, _ = hasher.Write(b3km.material) // Never returns an error.
+	return hasher.Digest(), nil
+}
+
+// DerivationContext returns the exact context string that is used with the
+// BLAKE3 key derivation function for the given key context and party.
+func (b3km *Blake3Keymaker) DerivationContext(keyContext, keyParty string) string {
+	return keyMakerBaseContext + keyContext + keyParty
 }
 
 func (b3km *Blake3Keymaker) Burn() {
 	clear(b3km.material)
 }
+
+// HKDFKeymaker implements KeyMaker using HKDF key derivation.
+type HKDFKeymaker struct {
+	keyMakerType KeyMakerType
+	hash         Hash
+	material     []byte
+}
+
+func (hkm *HKDFKeymaker) Type() KeyMakerType {
+	return hkm.keyMakerType
+}
+
+func (hkm *HKDFKeymaker) DeriveKey(keyContext, keyParty string, keyLength int) ([]byte, error) {
+	dst := make([]byte, keyLength)
+	return dst, hkm.DeriveKeyInto(keyContext, keyParty, dst)
+}
+
+func (hkm *HKDFKeymaker) DeriveKeyInto(keyContext, keyParty string, dst []byte) error {
+	return hkm.DeriveKeyIntoWithInfo(keyContext, keyParty, nil, dst)
+}
+
+// DeriveKeyIntoWithInfo appends info to the HKDF info.
+func (hkm *HKDFKeymaker) DeriveKeyIntoWithInfo(keyContext, keyParty string, info, dst []byte) error {
+	if len(dst) < keyMakerMinKeySize {
+		return ErrRequestedKeyLengthTooSmall
+	}
+
+	hkdfInfo := append([]byte(keyMakerBaseContext+keyContext+keyParty), infoSuffix(info)...)
+	reader := hkdf.New(hkm.hash.New, hkm.material, nil, hkdfInfo)
+	_, err := io.ReadFull(reader, dst)
+	return err
+}
+
+func (hkm *HKDFKeymaker) DeriveKeys(keyContext string, specs []KeySpec) ([][]byte, error) {
+	return deriveKeys(hkm, keyContext, specs)
+}
+
+// KeystreamReader is not supported by HKDF, as its output is limited.
+func (hkm *HKDFKeymaker) KeystreamReader(keyContext, keyParty string) (io.Reader, error) {
+	return nil, fmt.Errorf("%w: keystream with key maker type %s", errors.ErrUnsupported, hkm.keyMakerType)
+}
+
+func (hkm *HKDFKeymaker) Burn() {
+	clear(hkm.material)
+}
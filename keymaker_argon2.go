@@ -0,0 +1,112 @@
+package crop
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2MinSaltSize = 16
+
+// Argon2Params holds the cost parameters for Argon2id.
+type Argon2Params struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is the memory size in KiB.
+	Memory uint32
+	// Threads is the degree of parallelism.
+	Threads uint8
+}
+
+// DefaultArgon2Params are the second recommended parameters of RFC 9106.
+var DefaultArgon2Params = Argon2Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// Argon2idKeymaker implements KeyMaker using Argon2id password hashing.
+// Every key derivation runs the full Argon2id function.
+type Argon2idKeymaker struct {
+	password []byte
+	salt     []byte
+	params   Argon2Params
+}
+
+// NewArgon2idKeyMaker creates a new password based key maker.
+// The salt must be at least 16 bytes and should be random and unique per password.
+// Burn zeroizes the given password slice.
+func NewArgon2idKeyMaker(password, salt []byte, params Argon2Params) (*Argon2idKeymaker, error) {
+	switch {
+	case len(salt) < argon2MinSaltSize:
+		return nil, fmt.Errorf("argon2id salt must be at least %d bytes", argon2MinSaltSize)
+	case params.Time < 1:
+		return nil, errors.New("argon2id time must be at least 1")
+	case params.Threads < 1:
+		return nil, errors.New("argon2id threads must be at least 1")
+	case params.Memory < 8*uint32(params.Threads):
+		return nil, errors.New("argon2id memory must be at least 8 KiB per thread")
+	}
+
+	return &Argon2idKeymaker{
+		password: password,
+		salt:     salt,
+		params:   params,
+	}, nil
+}
+
+func (a2km *Argon2idKeymaker) Type() KeyMakerType {
+	return KeyMakerTypeArgon2id
+}
+
+func (a2km *Argon2idKeymaker) DeriveKey(keyContext, keyParty string, keyLength int) ([]byte, error) {
+	dst := make([]byte, keyLength)
+	return dst, a2km.DeriveKeyInto(keyContext, keyParty, dst)
+}
+
+func (a2km *Argon2idKeymaker) DeriveKeyInto(keyContext, keyParty string, dst []byte) error {
+	return a2km.DeriveKeyIntoWithInfo(keyContext, keyParty, nil, dst)
+}
+
+// DeriveKeyIntoWithInfo binds info to the salt.
+func (a2km *Argon2idKeymaker) DeriveKeyIntoWithInfo(keyContext, keyParty string, info, dst []byte) error {
+	switch {
+	case len(dst) < keyMakerMinKeySize:
+		return ErrRequestedKeyLengthTooSmall
+	case len(dst) > math.MaxUint32:
+		return errors.New("requested key length too big")
+	}
+
+	// Bind the key context to the salt for domain separation.
+	vh := NewValueHasher(BLAKE3.New())
+	vh.AddString(keyMakerBaseContext)
+	vh.Add(a2km.salt)
+	vh.AddString(keyContext)
+	vh.AddString(keyParty)
+	if len(info) > 0 {
+		vh.Add(info)
+	}
+	salt := vh.Sum(nil)
+
+	key := argon2.IDKey(a2km.password, salt, a2km.params.Time, a2km.params.Memory, a2km.params.Threads, uint32(len(dst)))
+	copy(dst, key)
+	clear(key)
+	return nil
+}
+
+func (a2km *Argon2idKeymaker) DeriveKeys(keyContext string, specs []KeySpec) ([][]byte, error) {
+	return deriveKeys(a2km, keyContext, specs)
+}
+
+// KeystreamReader is not supported by Argon2id.
+func (a2km *Argon2idKeymaker) KeystreamReader(keyContext, keyParty string) (io.Reader, error) {
+	return nil, fmt.Errorf("%w: keystream with key maker type %s", errors.ErrUnsupported, KeyMakerTypeArgon2id)
+}
+
+// Burn zeroizes the password material.
+func (a2km *Argon2idKeymaker) Burn() {
+	clear(a2km.password)
+}
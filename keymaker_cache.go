@@ -0,0 +1,118 @@
+package crop
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+// CachingKeyMaker wraps a KeyMaker and caches derived keys by context, party
+// and length, evicting the least recently used keys when full.
+// Evicted keys are zeroized.
+type CachingKeyMaker struct {
+	km         KeyMaker
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[cachedKeyID]*list.Element
+	lru     *list.List
+}
+
+type cachedKeyID struct {
+	keyContext string
+	keyParty   string
+	info       string
+	keyLength  int
+}
+
+type cachedKey struct {
+	id  cachedKeyID
+	key []byte
+}
+
+// NewCachingKeyMaker returns a new CachingKeyMaker that caches up to maxEntries
+// keys derived by the given KeyMaker.
+func NewCachingKeyMaker(km KeyMaker, maxEntries int) *CachingKeyMaker {
+	return &CachingKeyMaker{
+		km:         km,
+		maxEntries: max(maxEntries, 1),
+		entries:    make(map[cachedKeyID]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (ckm *CachingKeyMaker) Type() KeyMakerType {
+	return ckm.km.Type()
+}
+
+func (ckm *CachingKeyMaker) DeriveKey(keyContext, keyParty string, keyLength int) ([]byte, error) {
+	dst := make([]byte, keyLength)
+	return dst, ckm.DeriveKeyInto(keyContext, keyParty, dst)
+}
+
+func (ckm *CachingKeyMaker) DeriveKeyInto(keyContext, keyParty string, dst []byte) error {
+	return ckm.DeriveKeyIntoWithInfo(keyContext, keyParty, nil, dst)
+}
+
+func (ckm *CachingKeyMaker) DeriveKeyIntoWithInfo(keyContext, keyParty string, info, dst []byte) error {
+	ckm.lock.Lock()
+	defer ckm.lock.Unlock()
+
+	id := cachedKeyID{
+		keyContext: keyContext,
+		keyParty:   keyParty,
+		info:       string(info),
+		keyLength:  len(dst),
+	}
+
+	// Return copy of cached key.
+	if elem, ok := ckm.entries[id]; ok {
+		ckm.lru.MoveToFront(elem)
+		copy(dst, elem.Value.(*cachedKey).key) //nolint:forcetypeassert
+		return nil
+	}
+
+	// Derive and cache new key.
+	key := make([]byte, len(dst))
+	if err := ckm.km.DeriveKeyIntoWithInfo(keyContext, keyParty, info, key); err != nil {
+		return err
+	}
+	ckm.entries[id] = ckm.lru.PushFront(&cachedKey{
+		id:  id,
+		key: key,
+	})
+	copy(dst, key)
+
+	// Evict least recently used keys.
+	for ckm.lru.Len() > ckm.maxEntries {
+		ckm.evict(ckm.lru.Back())
+	}
+	return nil
+}
+
+func (ckm *CachingKeyMaker) DeriveKeys(keyContext string, specs []KeySpec) ([][]byte, error) {
+	return deriveKeys(ckm, keyContext, specs)
+}
+
+// KeystreamReader returns the keystream of the wrapped KeyMaker. Keystreams are not cached.
+func (ckm *CachingKeyMaker) KeystreamReader(keyContext, keyParty string) (io.Reader, error) {
+	return ckm.km.KeystreamReader(keyContext, keyParty)
+}
+
+// evict removes the element from the cache and zeroizes the key.
+// The lock must be held.
+func (ckm *CachingKeyMaker) evict(elem *list.Element) {
+	entry := ckm.lru.Remove(elem).(*cachedKey) //nolint:forcetypeassert
+	delete(ckm.entries, entry.id)
+	clear(entry.key)
+}
+
+func (ckm *CachingKeyMaker) Burn() {
+	ckm.lock.Lock()
+	defer ckm.lock.Unlock()
+
+	for ckm.lru.Len() > 0 {
+		ckm.evict(ckm.lru.Back())
+	}
+	ckm.km.Burn()
+}
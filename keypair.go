@@ -1,24 +1,51 @@
 package crop
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
 	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/sign/ed448"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/mr-tron/base58"
 )
 
 // KeyPairType identifies a signing/verification key pair algorithm.
+// Every type has a single canonical encoding for its public and private key,
+// which is used by Export and LoadKeyPair.
 type KeyPairType string
 
 const (
 	// KeyPairTypeEd25519 is the Ed25519 signature scheme.
+	// Public key: 32 bytes (RFC 8032).
+	// Private key: 64 bytes, the seed followed by the public key.
 	KeyPairTypeEd25519 KeyPairType = "Ed25519"
+	// KeyPairTypeEd448 is the Ed448 signature scheme.
+	// Public key: 57 bytes (RFC 8032).
+	// Private key: 114 bytes, the seed followed by the public key.
+	KeyPairTypeEd448 KeyPairType = "Ed448"
+	// KeyPairTypeECDSAP256 is the ECDSA signature scheme over NIST P-256 with SHA2-256.
+	// Public key: 65 bytes, the uncompressed point (SEC 1).
+	// Private key: 32 bytes, the big-endian scalar.
+	KeyPairTypeECDSAP256 KeyPairType = "ECDSA-P256"
+	// KeyPairTypeMLDSA65 is the ML-DSA-65 post-quantum signature scheme.
+	// Public key: 1952 bytes, private key: 4032 bytes (FIPS 204).
+	KeyPairTypeMLDSA65 KeyPairType = "ML-DSA-65"
 )
 
 // AllKeyPairTypes returns all supported key pair types.
 func AllKeyPairTypes() []KeyPairType {
 	return []KeyPairType{
 		KeyPairTypeEd25519,
+		KeyPairTypeEd448,
+		KeyPairTypeECDSAP256,
+		KeyPairTypeMLDSA65,
 	}
 }
 
@@ -27,6 +54,21 @@ func (kpt KeyPairType) IsValid() bool {
 	switch kpt {
 	case KeyPairTypeEd25519:
 		return true
+	case KeyPairTypeEd448:
+		return true
+	case KeyPairTypeECDSAP256:
+		return true
+	case KeyPairTypeMLDSA65:
+		return true
+	}
+	return false
+}
+
+// IsFIPSApproved returns whether this key pair type is FIPS-approved.
+func (kpt KeyPairType) IsFIPSApproved() bool {
+	switch kpt {
+	case KeyPairTypeECDSAP256, KeyPairTypeMLDSA65:
+		return true
 	}
 	return false
 }
@@ -42,6 +84,13 @@ type KeyPair interface {
 	HasPrivate() bool
 	// ToPublic returns a copy containing only the public key.
 	ToPublic() KeyPair
+	// Fingerprint returns a base58 encoded hash of the canonical public key
+	// encoding. It is the same for a key pair and its public-only copy.
+	// Returns an empty string if no public key is available.
+	Fingerprint(h Hash) string
+	// Equal reports whether the other key pair has the same type and public key.
+	// Public keys are compared in constant time.
+	Equal(other KeyPair) bool
 
 	// Sign creates a signature over the data using the private key.
 	Sign(data []byte) (sig []byte, err error)
@@ -49,6 +98,7 @@ type KeyPair interface {
 	Verify(data, sig []byte) error
 
 	// Export serializes the key pair to a StoredKey.
+	// The stored key does not share memory with the key pair.
 	Export() (*StoredKey, error)
 	// Burn securely erases key material from memory.
 	Burn()
@@ -66,57 +116,225 @@ func (kpType KeyPairType) New() (KeyPair, error) {
 
 	switch kpType {
 	case KeyPairTypeEd25519:
-		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		// Generate from seed to only depend on the package random source.
+		var seed [ed25519.SeedSize]byte
+		defer clear(seed[:])
+		err := generateWithRetry(func() (err error) {
+			_, err = io.ReadFull(randReader, seed[:])
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
-		return &Ed25519KeyPair{
-			pubKey:  pub,
-			privKey: priv,
-		}, nil
+		return newEd25519KeyPairFromSeed(seed[:])
+
+	case KeyPairTypeEd448:
+		return newEd448KeyPair()
+
+	case KeyPairTypeECDSAP256:
+		return newECDSAKeyPair()
+
+	case KeyPairTypeMLDSA65:
+		return newMLDSA65KeyPair()
 
 	default:
 		return nil, fmt.Errorf("key pair type %s not yet implemented", kpType)
 	}
 }
 
+// NewFromSeed deterministically derives a key pair of the specified type from
+// the given seed. The same seed always results in the same key pair.
+// Seeds must be exactly SeedSize bytes long and must be kept secret.
+// For ECDSA, the seed is used as the private scalar and is rejected in the
+// very rare case that it is out of range of the curve order.
+func (kpType KeyPairType) NewFromSeed(seed []byte) (KeyPair, error) {
+	if !kpType.IsValid() {
+		return nil, fmt.Errorf("invalid key pair type: %q", kpType)
+	}
+	if len(seed) != kpType.SeedSize() {
+		return nil, fmt.Errorf("%w: %s seed must be %d bytes, got %d", ErrInvalidFormat, kpType, kpType.SeedSize(), len(seed))
+	}
+
+	switch kpType {
+	case KeyPairTypeEd25519:
+		return newEd25519KeyPairFromSeed(seed)
+
+	case KeyPairTypeEd448:
+		return newEd448KeyPairFromSeed(seed)
+
+	case KeyPairTypeECDSAP256:
+		return newECDSAKeyPairFromSeed(seed)
+
+	case KeyPairTypeMLDSA65:
+		return newMLDSA65KeyPairFromSeed(seed)
+
+	default:
+		return nil, fmt.Errorf("key pair type %s not yet implemented", kpType)
+	}
+}
+
+// SeedSize returns the seed size required by NewFromSeed.
+// Returns 0 for invalid types.
+func (kpt KeyPairType) SeedSize() int {
+	switch kpt {
+	case KeyPairTypeEd25519:
+		return ed25519.SeedSize
+	case KeyPairTypeEd448:
+		return ed448.SeedSize
+	case KeyPairTypeECDSAP256:
+		return ecdsaP256KeySize
+	case KeyPairTypeMLDSA65:
+		return mldsa65.SeedSize
+	}
+	return 0
+}
+
 func (kpt KeyPairType) String() string {
 	return string(kpt)
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (kpt KeyPairType) MarshalText() ([]byte, error) {
+	return []byte(kpt), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unknown names are rejected with ErrInvalidFormat.
+func (kpt *KeyPairType) UnmarshalText(text []byte) error {
+	parsed := KeyPairType(text)
+	if !parsed.IsValid() {
+		return fmt.Errorf("%w: unknown key pair type %q", ErrInvalidFormat, text)
+	}
+	*kpt = parsed
+	return nil
+}
+
+// storedKeySize returns the size of the canonical public or private key
+// encoding. Returns 0 for invalid types.
+func (kpt KeyPairType) storedKeySize(private bool) int {
+	switch kpt {
+	case KeyPairTypeEd25519:
+		if private {
+			return ed25519.PrivateKeySize
+		}
+		return ed25519.PublicKeySize
+	case KeyPairTypeEd448:
+		if private {
+			return ed448.PrivateKeySize
+		}
+		return ed448.PublicKeySize
+	case KeyPairTypeECDSAP256:
+		if private {
+			return ecdsaP256KeySize
+		}
+		return ecdsaP256PublicKeySize
+	case KeyPairTypeMLDSA65:
+		if private {
+			return mldsa65.PrivateKeySize
+		}
+		return mldsa65.PublicKeySize
+	}
+	return 0
+}
+
 // LoadKeyPair loads a key pair from a StoredKey.
+// The returned key pair does not share memory with the stored key, so the
+// stored key may be burned after loading.
 func LoadKeyPair(stored *StoredKey) (KeyPair, error) {
 	// Get and check key type.
-	kpType, ok := FindStoredKeyType(stored, []KeyPairType{
-		KeyPairTypeEd25519,
-	})
-	if !ok {
+	kpType := stored.Algorithm()
+	if kpType == "" {
 		return nil, ErrInvalidKeyPairType
 	}
 
 	// Load key.
 	switch kpType {
 	case KeyPairTypeEd25519:
-		key := &Ed25519KeyPair{}
-		if stored.IsPrivate {
-			key.privKey = stored.Key
-			key.pubKey = key.privKey.Public().(ed25519.PublicKey)
-		} else {
-			key.pubKey = stored.Key
-		}
-		return key, nil
+		return loadEd25519KeyPair(stored)
+
+	case KeyPairTypeEd448:
+		return loadEd448KeyPair(stored)
+
+	case KeyPairTypeECDSAP256:
+		return loadECDSAKeyPair(stored)
+
+	case KeyPairTypeMLDSA65:
+		return loadMLDSA65KeyPair(stored)
 
 	default:
 		return nil, fmt.Errorf("key pair type %s not yet implemented", kpType)
 	}
 }
 
+// LoadKeyPairExpecting loads a key pair from a StoredKey like LoadKeyPair, but
+// fails with ErrInvalidKeyPairType if the stored key is not of the expected type.
+// Use it to prevent algorithm confusion when a specific key type is required.
+func LoadKeyPairExpecting(stored *StoredKey, expected KeyPairType) (KeyPair, error) {
+	kpType := stored.Algorithm()
+	if kpType == "" {
+		return nil, ErrInvalidKeyPairType
+	}
+	if kpType != expected {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrInvalidKeyPairType, expected, kpType)
+	}
+	return LoadKeyPair(stored)
+}
+
+// invalidKeySizeError returns an ErrInvalidFormat error describing the size mismatch.
+func invalidKeySizeError(kpType KeyPairType, stored *StoredKey, expected int) error {
+	pubPriv := "public"
+	if stored.IsPrivate {
+		pubPriv = "private"
+	}
+	return fmt.Errorf("%w: %s %s key must be %d bytes, got %d", ErrInvalidFormat, kpType, pubPriv, expected, len(stored.Key))
+}
+
+// fingerprint returns the base58 encoded hash of the public key.
+func fingerprint(h Hash, pubKey []byte) string {
+	if len(pubKey) == 0 {
+		return ""
+	}
+	return base58.Encode(h.Digest(pubKey))
+}
+
+// publicKeysEqual compares two public keys in constant time.
+// Missing keys are never equal.
+func publicKeysEqual(a, b []byte) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// VerifyAnyContext verifies the signature against each of the given keys and
+// returns the first key that matches.
+// It stops early and returns the context error if the context is canceled.
+func VerifyAnyContext(ctx context.Context, pubs []KeyPair, data, sig []byte) (KeyPair, error) {
+	for _, pub := range pubs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if pub.Verify(data, sig) == nil {
+			return pub, nil
+		}
+	}
+	return nil, ErrNoMatchingKey
+}
+
 // Ed25519KeyPair implements the KeyPair interface for Ed25519 signatures.
 type Ed25519KeyPair struct {
 	pubKey  ed25519.PublicKey
 	privKey ed25519.PrivateKey
 }
 
+func newEd25519KeyPairFromSeed(seed []byte) (*Ed25519KeyPair, error) {
+	priv := ed25519.NewKeyFromSeed(seed)
+	return &Ed25519KeyPair{
+		pubKey:  priv.Public().(ed25519.PublicKey),
+		privKey: priv,
+	}, nil
+}
+
 // MakeEd25519KeyPair creates an Ed25519KeyPair from existing key material.
 func MakeEd25519KeyPair(privKey ed25519.PrivateKey, pubKey ed25519.PublicKey) *Ed25519KeyPair {
 	if len(pubKey) == 0 && len(privKey) != 0 {
@@ -128,6 +346,23 @@ func MakeEd25519KeyPair(privKey ed25519.PrivateKey, pubKey ed25519.PublicKey) *E
 	}
 }
 
+func loadEd25519KeyPair(stored *StoredKey) (*Ed25519KeyPair, error) {
+	key := &Ed25519KeyPair{}
+	if stored.IsPrivate {
+		if len(stored.Key) != ed25519.PrivateKeySize {
+			return nil, invalidKeySizeError(KeyPairTypeEd25519, stored, ed25519.PrivateKeySize)
+		}
+		key.privKey = bytes.Clone(stored.Key)
+		key.pubKey = key.privKey.Public().(ed25519.PublicKey)
+	} else {
+		if len(stored.Key) != ed25519.PublicKeySize {
+			return nil, invalidKeySizeError(KeyPairTypeEd25519, stored, ed25519.PublicKeySize)
+		}
+		key.pubKey = bytes.Clone(stored.Key)
+	}
+	return key, nil
+}
+
 func (edkp *Ed25519KeyPair) Type() KeyPairType {
 	return KeyPairTypeEd25519
 }
@@ -146,6 +381,18 @@ func (edkp *Ed25519KeyPair) ToPublic() KeyPair {
 	}
 }
 
+func (edkp *Ed25519KeyPair) Fingerprint(h Hash) string {
+	return fingerprint(h, edkp.pubKey)
+}
+
+func (edkp *Ed25519KeyPair) Equal(other KeyPair) bool {
+	o, ok := other.(*Ed25519KeyPair)
+	if !ok {
+		return false
+	}
+	return publicKeysEqual(edkp.pubKey, o.pubKey)
+}
+
 func (edkp *Ed25519KeyPair) Sign(data []byte) (signature []byte, err error) {
 	if edkp.privKey == nil {
 		return nil, ErrNoPrivateKey
@@ -160,6 +407,74 @@ func (edkp *Ed25519KeyPair) Verify(data, sig []byte) error {
 	return ed25519.VerifyWithOptions(edkp.pubKey, data, sig, &ed25519.Options{})
 }
 
+// BatchItem is a single message and signature to be verified in a batch.
+type BatchItem struct {
+	Data []byte
+	Sig  []byte
+}
+
+// VerifyBatch verifies all items against the public key.
+// It returns whether all signatures are valid and the indexes of the items
+// that failed verification.
+// Note: The standard library does not provide batched Ed25519 verification,
+// so items are currently verified one by one.
+func (edkp *Ed25519KeyPair) VerifyBatch(items []BatchItem) (allOK bool, failures []int, err error) {
+	if edkp.pubKey == nil {
+		return false, nil, ErrNoPublicKey
+	}
+	for i, item := range items {
+		if !ed25519.Verify(edkp.pubKey, item.Data, item.Sig) {
+			failures = append(failures, i)
+		}
+	}
+	return len(failures) == 0, failures, nil
+}
+
+// SignCtx creates an Ed25519ctx signature over the data, domain separated by
+// the given context. Use this when a single key signs for multiple purposes.
+// The context must be at most 255 bytes. An empty context results in a pure
+// Ed25519 signature, as Ed25519ctx does not permit an empty context.
+func (edkp *Ed25519KeyPair) SignCtx(data []byte, context string) (signature []byte, err error) {
+	if edkp.privKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	return edkp.privKey.Sign(nil, data, &ed25519.Options{Context: context})
+}
+
+// VerifyCtx checks that the Ed25519ctx signature is valid for the data and context.
+func (edkp *Ed25519KeyPair) VerifyCtx(data, sig []byte, context string) error {
+	if edkp.pubKey == nil {
+		return ErrNoPublicKey
+	}
+	return ed25519.VerifyWithOptions(edkp.pubKey, data, sig, &ed25519.Options{Context: context})
+}
+
+// SignPrehashed creates an Ed25519ph signature over the given SHA2_512 digest.
+// This allows signing large payloads that were hashed in a streaming manner.
+// Ed25519ph signatures are domain separated from pure Ed25519 signatures, so
+// they only verify with VerifyPrehashed and never with Verify.
+func (edkp *Ed25519KeyPair) SignPrehashed(digest []byte) (signature []byte, err error) {
+	if edkp.privKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	if len(digest) != sha512.Size {
+		return nil, fmt.Errorf("%w: prehashed digest must be %d bytes (SHA2_512)", ErrInvalidFormat, sha512.Size)
+	}
+	return edkp.privKey.Sign(nil, digest, &ed25519.Options{Hash: crypto.SHA512})
+}
+
+// VerifyPrehashed checks that the Ed25519ph signature is valid for the given
+// SHA2_512 digest.
+func (edkp *Ed25519KeyPair) VerifyPrehashed(digest, sig []byte) error {
+	if edkp.pubKey == nil {
+		return ErrNoPublicKey
+	}
+	if len(digest) != sha512.Size {
+		return fmt.Errorf("%w: prehashed digest must be %d bytes (SHA2_512)", ErrInvalidFormat, sha512.Size)
+	}
+	return ed25519.VerifyWithOptions(edkp.pubKey, digest, sig, &ed25519.Options{Hash: crypto.SHA512})
+}
+
 // PublicKeyData returns the raw public key bytes.
 func (edkp *Ed25519KeyPair) PublicKeyData() []byte {
 	return edkp.pubKey
@@ -179,12 +494,12 @@ func (edkp *Ed25519KeyPair) Export() (*StoredKey, error) {
 		if edkp.privKey == nil {
 			return nil, ErrNoPrivateKey
 		}
-		stored.Key = edkp.privKey
+		stored.Key = bytes.Clone(edkp.privKey)
 	} else {
 		if edkp.pubKey == nil {
 			return nil, ErrNoPublicKey
 		}
-		stored.Key = edkp.pubKey
+		stored.Key = bytes.Clone(edkp.pubKey)
 	}
 	return stored, nil
 }
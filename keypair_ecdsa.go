@@ -0,0 +1,167 @@
+package crop
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// ecdsaP256KeySize is the size of a raw P-256 private key.
+const ecdsaP256KeySize = 32
+
+// ecdsaP256PublicKeySize is the size of an uncompressed P-256 public key.
+const ecdsaP256PublicKeySize = 65
+
+// ECDSAKeyPair implements the KeyPair interface for ECDSA signatures over
+// NIST P-256 with SHA2-256. Signatures are ASN.1 DER encoded.
+type ECDSAKeyPair struct {
+	pubKey  *ecdsa.PublicKey
+	privKey *ecdsa.PrivateKey
+}
+
+func newECDSAKeyPair() (*ECDSAKeyPair, error) {
+	// Generate from raw key material to only depend on the package random source.
+	var (
+		keyMaterial [ecdsaP256KeySize]byte
+		kp          *ECDSAKeyPair
+	)
+	defer clear(keyMaterial[:])
+	err := generateWithRetry(func() (err error) {
+		_, err = io.ReadFull(randReader, keyMaterial[:])
+		if err != nil {
+			return err
+		}
+		// Rejects the rare scalars out of range of the curve order.
+		kp, err = newECDSAKeyPairFromSeed(keyMaterial[:])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+func newECDSAKeyPairFromSeed(seed []byte) (*ECDSAKeyPair, error) {
+	privKey, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), seed)
+	if err != nil {
+		return nil, err
+	}
+	return &ECDSAKeyPair{
+		pubKey:  &privKey.PublicKey,
+		privKey: privKey,
+	}, nil
+}
+
+func loadECDSAKeyPair(stored *StoredKey) (*ECDSAKeyPair, error) {
+	if stored.IsPrivate {
+		if len(stored.Key) != ecdsaP256KeySize {
+			return nil, invalidKeySizeError(KeyPairTypeECDSAP256, stored, ecdsaP256KeySize)
+		}
+		privKey, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), stored.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+		}
+		return &ECDSAKeyPair{
+			pubKey:  &privKey.PublicKey,
+			privKey: privKey,
+		}, nil
+	}
+
+	pubKey, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), stored.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	return &ECDSAKeyPair{
+		pubKey: pubKey,
+	}, nil
+}
+
+func (eckp *ECDSAKeyPair) Type() KeyPairType {
+	return KeyPairTypeECDSAP256
+}
+
+func (eckp *ECDSAKeyPair) PublicKey() crypto.PublicKey {
+	return eckp.pubKey
+}
+
+func (eckp *ECDSAKeyPair) HasPrivate() bool {
+	return eckp.privKey != nil
+}
+
+func (eckp *ECDSAKeyPair) ToPublic() KeyPair {
+	return &ECDSAKeyPair{
+		pubKey: eckp.pubKey,
+	}
+}
+
+func (eckp *ECDSAKeyPair) Fingerprint(h Hash) string {
+	if eckp.pubKey == nil {
+		return ""
+	}
+	pubKey, err := eckp.pubKey.Bytes()
+	if err != nil {
+		return ""
+	}
+	return fingerprint(h, pubKey)
+}
+
+func (eckp *ECDSAKeyPair) Equal(other KeyPair) bool {
+	o, ok := other.(*ECDSAKeyPair)
+	if !ok || eckp.pubKey == nil || o.pubKey == nil {
+		return false
+	}
+	a, errA := eckp.pubKey.Bytes()
+	b, errB := o.pubKey.Bytes()
+	if errA != nil || errB != nil {
+		return false
+	}
+	return publicKeysEqual(a, b)
+}
+
+func (eckp *ECDSAKeyPair) Sign(data []byte) (signature []byte, err error) {
+	if eckp.privKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(randReader, eckp.privKey, digest[:])
+}
+
+func (eckp *ECDSAKeyPair) Verify(data, sig []byte) error {
+	if eckp.pubKey == nil {
+		return ErrNoPublicKey
+	}
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(eckp.pubKey, digest[:], sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (eckp *ECDSAKeyPair) Export() (*StoredKey, error) {
+	stored := &StoredKey{
+		Type:      string(eckp.Type()),
+		IsPrivate: eckp.HasPrivate(),
+	}
+	var err error
+	if stored.IsPrivate {
+		stored.Key, err = eckp.privKey.Bytes()
+	} else {
+		if eckp.pubKey == nil {
+			return nil, ErrNoPublicKey
+		}
+		stored.Key, err = eckp.pubKey.Bytes()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (eckp *ECDSAKeyPair) Burn() {
+	// TODO: The ecdsa private key keeps internal copies that cannot be wiped.
+	eckp.privKey = nil
+	eckp.pubKey = nil
+}
@@ -0,0 +1,125 @@
+package crop
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+
+	"github.com/cloudflare/circl/sign/ed448"
+)
+
+// Ed448KeyPair implements the KeyPair interface for Ed448 signatures.
+type Ed448KeyPair struct {
+	pubKey  ed448.PublicKey
+	privKey ed448.PrivateKey
+}
+
+func newEd448KeyPair() (*Ed448KeyPair, error) {
+	// Generate from seed to only depend on the package random source.
+	var seed [ed448.SeedSize]byte
+	defer clear(seed[:])
+	err := generateWithRetry(func() (err error) {
+		_, err = io.ReadFull(randReader, seed[:])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newEd448KeyPairFromSeed(seed[:])
+}
+
+func newEd448KeyPairFromSeed(seed []byte) (*Ed448KeyPair, error) {
+	priv := ed448.NewKeyFromSeed(seed)
+	return &Ed448KeyPair{
+		pubKey:  priv.Public().(ed448.PublicKey),
+		privKey: priv,
+	}, nil
+}
+
+func loadEd448KeyPair(stored *StoredKey) (*Ed448KeyPair, error) {
+	key := &Ed448KeyPair{}
+	if stored.IsPrivate {
+		if len(stored.Key) != ed448.PrivateKeySize {
+			return nil, invalidKeySizeError(KeyPairTypeEd448, stored, ed448.PrivateKeySize)
+		}
+		key.privKey = bytes.Clone(stored.Key)
+		key.pubKey = key.privKey.Public().(ed448.PublicKey)
+	} else {
+		if len(stored.Key) != ed448.PublicKeySize {
+			return nil, invalidKeySizeError(KeyPairTypeEd448, stored, ed448.PublicKeySize)
+		}
+		key.pubKey = bytes.Clone(stored.Key)
+	}
+	return key, nil
+}
+
+func (edkp *Ed448KeyPair) Type() KeyPairType {
+	return KeyPairTypeEd448
+}
+
+func (edkp *Ed448KeyPair) PublicKey() crypto.PublicKey {
+	return edkp.pubKey
+}
+
+func (edkp *Ed448KeyPair) HasPrivate() bool {
+	return edkp.privKey != nil
+}
+
+func (edkp *Ed448KeyPair) ToPublic() KeyPair {
+	return &Ed448KeyPair{
+		pubKey: edkp.pubKey,
+	}
+}
+
+func (edkp *Ed448KeyPair) Fingerprint(h Hash) string {
+	return fingerprint(h, edkp.pubKey)
+}
+
+func (edkp *Ed448KeyPair) Equal(other KeyPair) bool {
+	o, ok := other.(*Ed448KeyPair)
+	if !ok {
+		return false
+	}
+	return publicKeysEqual(edkp.pubKey, o.pubKey)
+}
+
+func (edkp *Ed448KeyPair) Sign(data []byte) (signature []byte, err error) {
+	if edkp.privKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	return ed448.Sign(edkp.privKey, data, ""), nil
+}
+
+func (edkp *Ed448KeyPair) Verify(data, sig []byte) error {
+	if edkp.pubKey == nil {
+		return ErrNoPublicKey
+	}
+	if !ed448.Verify(edkp.pubKey, data, sig, "") {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (edkp *Ed448KeyPair) Export() (*StoredKey, error) {
+	stored := &StoredKey{
+		Type:      string(edkp.Type()),
+		IsPrivate: edkp.HasPrivate(),
+	}
+	if stored.IsPrivate {
+		stored.Key = bytes.Clone(edkp.privKey)
+	} else {
+		if edkp.pubKey == nil {
+			return nil, ErrNoPublicKey
+		}
+		stored.Key = bytes.Clone(edkp.pubKey)
+	}
+	return stored, nil
+}
+
+func (edkp *Ed448KeyPair) Burn() {
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	clear(edkp.privKey)
+	clear(edkp.pubKey)
+	edkp.privKey = nil
+	edkp.pubKey = nil
+}
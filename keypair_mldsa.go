@@ -0,0 +1,146 @@
+package crop
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+)
+
+// MLDSA65KeyPair implements the KeyPair interface for ML-DSA-65 post-quantum
+// signatures. Signatures are hedged with additional randomness.
+type MLDSA65KeyPair struct {
+	pubKey  *mldsa65.PublicKey
+	privKey *mldsa65.PrivateKey
+}
+
+func newMLDSA65KeyPair() (*MLDSA65KeyPair, error) {
+	// Generate from seed to only depend on the package random source.
+	var seed [mldsa65.SeedSize]byte
+	defer clear(seed[:])
+	err := generateWithRetry(func() (err error) {
+		_, err = io.ReadFull(randReader, seed[:])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newMLDSA65KeyPairFromSeed(seed[:])
+}
+
+func newMLDSA65KeyPairFromSeed(seed []byte) (*MLDSA65KeyPair, error) {
+	var seedArray [mldsa65.SeedSize]byte
+	defer clear(seedArray[:])
+	copy(seedArray[:], seed)
+	pub, priv := mldsa65.NewKeyFromSeed(&seedArray)
+	return &MLDSA65KeyPair{
+		pubKey:  pub,
+		privKey: priv,
+	}, nil
+}
+
+func loadMLDSA65KeyPair(stored *StoredKey) (*MLDSA65KeyPair, error) {
+	if stored.IsPrivate {
+		if len(stored.Key) != mldsa65.PrivateKeySize {
+			return nil, invalidKeySizeError(KeyPairTypeMLDSA65, stored, mldsa65.PrivateKeySize)
+		}
+		priv := &mldsa65.PrivateKey{}
+		if err := priv.UnmarshalBinary(stored.Key); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+		}
+		return &MLDSA65KeyPair{
+			pubKey:  priv.Public().(*mldsa65.PublicKey),
+			privKey: priv,
+		}, nil
+	}
+
+	if len(stored.Key) != mldsa65.PublicKeySize {
+		return nil, invalidKeySizeError(KeyPairTypeMLDSA65, stored, mldsa65.PublicKeySize)
+	}
+	pub := &mldsa65.PublicKey{}
+	if err := pub.UnmarshalBinary(stored.Key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	return &MLDSA65KeyPair{
+		pubKey: pub,
+	}, nil
+}
+
+func (mdkp *MLDSA65KeyPair) Type() KeyPairType {
+	return KeyPairTypeMLDSA65
+}
+
+func (mdkp *MLDSA65KeyPair) PublicKey() crypto.PublicKey {
+	return mdkp.pubKey
+}
+
+func (mdkp *MLDSA65KeyPair) HasPrivate() bool {
+	return mdkp.privKey != nil
+}
+
+func (mdkp *MLDSA65KeyPair) ToPublic() KeyPair {
+	return &MLDSA65KeyPair{
+		pubKey: mdkp.pubKey,
+	}
+}
+
+func (mdkp *MLDSA65KeyPair) Fingerprint(h Hash) string {
+	if mdkp.pubKey == nil {
+		return ""
+	}
+	return fingerprint(h, mdkp.pubKey.Bytes())
+}
+
+func (mdkp *MLDSA65KeyPair) Equal(other KeyPair) bool {
+	o, ok := other.(*MLDSA65KeyPair)
+	if !ok || mdkp.pubKey == nil || o.pubKey == nil {
+		return false
+	}
+	return publicKeysEqual(mdkp.pubKey.Bytes(), o.pubKey.Bytes())
+}
+
+// Sign signs the data with hedged randomness from crypto/rand, which bypasses
+// the package random source.
+func (mdkp *MLDSA65KeyPair) Sign(data []byte) (signature []byte, err error) {
+	if mdkp.privKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	sig := make([]byte, mldsa65.SignatureSize)
+	if err := mldsa65.SignTo(mdkp.privKey, data, nil, true, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (mdkp *MLDSA65KeyPair) Verify(data, sig []byte) error {
+	if mdkp.pubKey == nil {
+		return ErrNoPublicKey
+	}
+	if !mldsa65.Verify(mdkp.pubKey, data, nil, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (mdkp *MLDSA65KeyPair) Export() (*StoredKey, error) {
+	stored := &StoredKey{
+		Type:      string(mdkp.Type()),
+		IsPrivate: mdkp.HasPrivate(),
+	}
+	if stored.IsPrivate {
+		stored.Key = mdkp.privKey.Bytes()
+	} else {
+		if mdkp.pubKey == nil {
+			return nil, ErrNoPublicKey
+		}
+		stored.Key = mdkp.pubKey.Bytes()
+	}
+	return stored, nil
+}
+
+func (mdkp *MLDSA65KeyPair) Burn() {
+	// TODO: The mldsa private key is unpacked into internal structures that cannot be wiped.
+	mdkp.privKey = nil
+	mdkp.pubKey = nil
+}
@@ -1,8 +1,11 @@
 package crop
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -95,3 +98,412 @@ func TestKeyPair(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyAnyContext(t *testing.T) {
+	t.Parallel()
+
+	// Create a set of public keys and sign with one of them.
+	var pubs []KeyPair
+	var signer KeyPair
+	for i := range 5 {
+		kp, err := NewKeyPair(KeyPairTypeEd25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, kp.ToPublic())
+		if i == 3 {
+			signer = kp
+		}
+	}
+	sig, err := signer.Sign(signTestData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Valid match within deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	match, err := VerifyAnyContext(ctx, pubs, signTestData, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Same(t, pubs[3], match, "should return matching key")
+
+	// No match.
+	_, err = VerifyAnyContext(ctx, pubs[:3], signTestData, sig)
+	assert.ErrorIs(t, err, ErrNoMatchingKey)
+
+	// Pre-canceled context returns promptly.
+	canceledCtx, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	_, err = VerifyAnyContext(canceledCtx, pubs, signTestData, sig)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestKeyPair_VerifyRejectsInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, kpType := range AllKeyPairTypes() {
+		t.Run(string(kpType), func(t *testing.T) {
+			priv, err := kpType.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub := priv.ToPublic()
+			sig, err := priv.Sign(signTestData)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Wrong data.
+			assert.Error(t, pub.Verify([]byte("other data"), sig))
+			// Tampered signature.
+			tampered := append([]byte(nil), sig...)
+			tampered[len(tampered)-1] ^= 0x01
+			assert.Error(t, pub.Verify(signTestData, tampered))
+			// Public key cannot sign.
+			_, err = pub.Sign(signTestData)
+			assert.ErrorIs(t, err, ErrNoPrivateKey)
+		})
+	}
+}
+
+func TestEd448KeyPair_BurnZeroizes(t *testing.T) {
+	t.Parallel()
+
+	kp, err := NewKeyPair(KeyPairTypeEd448)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := kp.(*Ed448KeyPair)
+	priv := ed.privKey
+	assert.Len(t, priv, 114, "Ed448 private key size")
+
+	kp.Burn()
+	assert.Equal(t, make([]byte, len(priv)), []byte(priv), "private key must be zeroed")
+	assert.False(t, kp.HasPrivate())
+}
+
+func TestMLDSA65KeyPair_LargeKeyRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	priv, err := NewKeyPair(KeyPairTypeMLDSA65)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := priv.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Greater(t, len(stored.Key), 4000, "ML-DSA-65 private keys are multiple KB")
+
+	// Round trip through both text and binary encodings.
+	fromText, err := LoadKeyFromText(stored.Text())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := stored.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, err := LoadKeyFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, stored.Key, fromText.Key, "text round trip")
+	assert.Equal(t, stored.Key, fromBytes.Key, "binary round trip")
+
+	// Loaded key must produce signatures verifiable by the original.
+	loaded, err := LoadKeyPair(fromText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := loaded.Sign(signTestData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.ToPublic().Verify(signTestData, sig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEd25519KeyPair_Prehashed(t *testing.T) {
+	t.Parallel()
+
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := kp.(*Ed25519KeyPair)
+	pub := kp.ToPublic().(*Ed25519KeyPair)
+
+	// Hash in a streaming manner and sign the digest.
+	h := SHA2_512.New()
+	_, _ = h.Write(signTestData)
+	digest := h.Sum(nil)
+
+	sig, err := ed.SignPrehashed(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.VerifyPrehashed(digest, sig); err != nil {
+		t.Fatalf("verify prehashed: %v", err)
+	}
+
+	// Prehashed and pure signatures must not be interchangeable.
+	if err := pub.Verify(digest, sig); err == nil {
+		t.Fatal("prehashed signature verified as pure Ed25519")
+	}
+	pureSig, err := ed.Sign(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.VerifyPrehashed(digest, pureSig); err == nil {
+		t.Fatal("pure Ed25519 signature verified as prehashed")
+	}
+
+	// Digest must be SHA2_512 sized.
+	if _, err := ed.SignPrehashed(digest[:32]); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("expected ErrInvalidFormat for short digest, got: %v", err)
+	}
+}
+
+func TestEd25519KeyPair_Context(t *testing.T) {
+	t.Parallel()
+
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := kp.(*Ed25519KeyPair)
+	pub := kp.ToPublic().(*Ed25519KeyPair)
+
+	sig, err := ed.SignCtx(signTestData, "subsystem-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.VerifyCtx(signTestData, sig, "subsystem-a"); err != nil {
+		t.Fatalf("verify with context: %v", err)
+	}
+
+	// Signatures must not cross contexts or verify as pure Ed25519.
+	if err := pub.VerifyCtx(signTestData, sig, "subsystem-b"); err == nil {
+		t.Fatal("signature verified with different context")
+	}
+	if err := pub.Verify(signTestData, sig); err == nil {
+		t.Fatal("context signature verified as pure Ed25519")
+	}
+
+	// Context is limited to 255 bytes.
+	if _, err := ed.SignCtx(signTestData, string(make([]byte, 256))); err == nil {
+		t.Fatal("expected error for overlong context")
+	}
+}
+
+func TestKeyPair_Fingerprint(t *testing.T) {
+	t.Parallel()
+
+	for _, kpType := range AllKeyPairTypes() {
+		t.Run(string(kpType), func(t *testing.T) {
+			priv, err := kpType.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := kpType.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fp := priv.Fingerprint(BLAKE3)
+			assert.NotEmpty(t, fp)
+			assert.Equal(t, fp, priv.ToPublic().Fingerprint(BLAKE3), "private and public fingerprint must match")
+			assert.NotEqual(t, fp, priv.Fingerprint(SHA2_256), "hash must affect fingerprint")
+			assert.NotEqual(t, fp, other.Fingerprint(BLAKE3), "different keys must differ")
+
+			// Fingerprint must survive export and import.
+			stored, err := priv.ToPublic().Export()
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadKeyPair(stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, fp, loaded.Fingerprint(BLAKE3))
+		})
+	}
+}
+
+func TestKeyPair_Equal(t *testing.T) {
+	t.Parallel()
+
+	var previous KeyPair
+	for _, kpType := range AllKeyPairTypes() {
+		priv, err := kpType.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := kpType.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, priv.Equal(priv), "%s: key must equal itself", kpType)
+		assert.True(t, priv.Equal(priv.ToPublic()), "%s: key must equal its public copy", kpType)
+		assert.True(t, priv.ToPublic().Equal(priv), "%s: public copy must equal key", kpType)
+		assert.False(t, priv.Equal(other), "%s: different keys must not be equal", kpType)
+		if previous != nil {
+			assert.False(t, priv.Equal(previous), "%s: different types must not be equal", kpType)
+		}
+		previous = priv
+	}
+}
+
+func TestKeyPairType_NewFromSeed(t *testing.T) {
+	t.Parallel()
+
+	for _, kpType := range AllKeyPairTypes() {
+		t.Run(string(kpType), func(t *testing.T) {
+			seed := make([]byte, kpType.SeedSize())
+			for i := range seed {
+				seed[i] = byte(i + 1)
+			}
+
+			a, err := kpType.NewFromSeed(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := kpType.NewFromSeed(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(t, a.Equal(b), "same seed must result in same key")
+			assert.Equal(t, kpType, a.Type())
+
+			// Derived keys must be usable.
+			sig, err := a.Sign(signTestData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := b.ToPublic().Verify(signTestData, sig); err != nil {
+				t.Fatal(err)
+			}
+
+			// Different seed results in a different key.
+			seed[0] ^= 0xFF
+			c, err := kpType.NewFromSeed(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.False(t, a.Equal(c), "different seed must result in different key")
+
+			// Wrong seed length is rejected.
+			_, err = kpType.NewFromSeed(seed[1:])
+			assert.ErrorIs(t, err, ErrInvalidFormat)
+		})
+	}
+
+	_, err := KeyPairType("INVALID").NewFromSeed(make([]byte, 32))
+	assert.Error(t, err)
+}
+
+func TestEd25519KeyPair_VerifyBatch(t *testing.T) {
+	t.Parallel()
+
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := kp.ToPublic().(*Ed25519KeyPair)
+
+	items := make([]BatchItem, 10)
+	for i := range items {
+		data := []byte(fmt.Sprintf("record %d", i))
+		sig, err := kp.Sign(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items[i] = BatchItem{Data: data, Sig: sig}
+	}
+
+	allOK, failures, err := pub.VerifyBatch(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, allOK)
+	assert.Empty(t, failures)
+
+	// Tamper with some items.
+	items[2].Data = []byte("tampered")
+	items[7].Sig = items[6].Sig
+	allOK, failures, err = pub.VerifyBatch(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, allOK)
+	assert.Equal(t, []int{2, 7}, failures)
+
+	_, _, err = (&Ed25519KeyPair{}).VerifyBatch(items)
+	assert.ErrorIs(t, err, ErrNoPublicKey)
+}
+
+func TestLoadKeyPair_InvalidKeyLength(t *testing.T) {
+	t.Parallel()
+
+	for _, kpType := range AllKeyPairTypes() {
+		t.Run(string(kpType), func(t *testing.T) {
+			kp, err := kpType.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, export := range []func() (*StoredKey, error){kp.Export, kp.ToPublic().Export} {
+				stored, err := export()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, keyData := range [][]byte{
+					stored.Key[:10],
+					stored.Key[:len(stored.Key)-1],
+					append(stored.Key, 0),
+					nil,
+				} {
+					_, err := LoadKeyPair(&StoredKey{
+						Type:      stored.Type,
+						IsPrivate: stored.IsPrivate,
+						Key:       keyData,
+					})
+					assert.ErrorIs(t, err, ErrInvalidFormat, "key length %d", len(keyData))
+				}
+			}
+		})
+	}
+}
+
+func TestLoadKeyPairExpecting(t *testing.T) {
+	t.Parallel()
+
+	kp, err := KeyPairTypeEd25519.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := kp.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Matching type loads.
+	loaded, err := LoadKeyPairExpecting(stored, KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, kp.Equal(loaded))
+
+	// Mismatching type fails.
+	_, err = LoadKeyPairExpecting(stored, KeyPairTypeECDSAP256)
+	assert.ErrorIs(t, err, ErrInvalidKeyPairType)
+
+	// Unknown type fails.
+	_, err = LoadKeyPairExpecting(&StoredKey{Type: "unknown", Key: stored.Key}, KeyPairTypeEd25519)
+	assert.ErrorIs(t, err, ErrInvalidKeyPairType)
+}
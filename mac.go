@@ -1,8 +1,8 @@
 package crop
 
 import (
+	"bytes"
 	"crypto/hmac"
-	"crypto/rand"
 	"crypto/subtle"
 	"encoding/binary"
 	"fmt"
@@ -20,11 +20,26 @@ const (
 	MsgAuthCodeTypeHMACBlake3 MsgAuthCodeType = "HMAC-BLAKE3"
 	// MsgAuthCodeTypeBlake3 uses keyed BLAKE3.
 	MsgAuthCodeTypeBlake3 MsgAuthCodeType = "BLAKE3"
+	// MsgAuthCodeTypePoly1305 uses Poly1305 with one-time keys derived per message.
+	// Requires 32 byte keys.
+	MsgAuthCodeTypePoly1305 MsgAuthCodeType = "Poly1305"
 
 	macMinNonceSize = 8
 	macNonceSize    = 16
+	macMinTagSize   = 12
+
+	blake3KeySize = 32
 )
 
+// AllMsgAuthCodeTypes returns all supported MAC types.
+func AllMsgAuthCodeTypes() []MsgAuthCodeType {
+	return []MsgAuthCodeType{
+		MsgAuthCodeTypeHMACBlake3,
+		MsgAuthCodeTypeBlake3,
+		MsgAuthCodeTypePoly1305,
+	}
+}
+
 // IsValid returns whether this MAC type is supported.
 func (act MsgAuthCodeType) IsValid() bool {
 	switch act {
@@ -32,63 +47,180 @@ func (act MsgAuthCodeType) IsValid() bool {
 		return true
 	case MsgAuthCodeTypeBlake3:
 		return true
+	case MsgAuthCodeTypePoly1305:
+		return true
 	}
 	return false
 }
 
+// IsFIPSApproved returns whether this MAC type is FIPS-approved.
+func (act MsgAuthCodeType) IsFIPSApproved() bool {
+	return false
+}
+
+// MACOptions holds optional settings for MAC handlers.
+type MACOptions struct {
+	// NonceSize is the size of the random nonce (salt) added to every MAC.
+	// Defaults to 16 bytes and is raised to the minimum of 8 bytes.
+	// Verification accepts any nonce size above the minimum.
+	NonceSize int
+	// TagSize truncates the checksum to the given size in bytes, reducing
+	// overhead at the cost of security: forging a MAC takes about 2^(8*TagSize)
+	// attempts. Defaults to the full hash size and must be at least 12 bytes.
+	// Both peers must use the same tag size.
+	TagSize int
+	// Concurrent lets hash based MACs sign and verify from multiple goroutines
+	// in parallel, using a pool of hashers instead of a single locked one.
+	// This uses more memory and is only worth it under high concurrency.
+	Concurrent bool
+}
+
+// withDefaults returns the options with defaults and minimums applied.
+func (opts MACOptions) withDefaults() MACOptions {
+	switch {
+	case opts.NonceSize == 0:
+		opts.NonceSize = macNonceSize
+	case opts.NonceSize < macMinNonceSize:
+		opts.NonceSize = macMinNonceSize
+	}
+	return opts
+}
+
+// tagSize returns the configured tag size for the given hash size.
+func (opts MACOptions) tagSize(hashSize int) (int, error) {
+	switch {
+	case opts.TagSize == 0:
+		return hashSize, nil
+	case opts.TagSize < macMinTagSize:
+		return 0, fmt.Errorf("MAC tag size must be at least %d bytes", macMinTagSize)
+	case opts.TagSize > hashSize:
+		return 0, fmt.Errorf("MAC tag size must be at most %d bytes", hashSize)
+	default:
+		return opts.TagSize, nil
+	}
+}
+
 // NewAuthCodeHandler creates a new MAC handler with separate keys for signing and verification.
 func NewAuthCodeHandler(act MsgAuthCodeType, signKey, verifyKey []byte, seqChecker SequenceChecker) (MsgAuthCodeHandler, error) {
 	return act.New(signKey, verifyKey, seqChecker)
 }
 
+// NewAuthCodeHandlerWithOptions creates a new MAC handler with separate keys
+// for signing and verification and the given options.
+func NewAuthCodeHandlerWithOptions(act MsgAuthCodeType, signKey, verifyKey []byte, seqChecker SequenceChecker, opts MACOptions) (MsgAuthCodeHandler, error) {
+	return act.NewWithOptions(signKey, verifyKey, seqChecker, opts)
+}
+
 func (act MsgAuthCodeType) New(signKey, verifyKey []byte, seqChecker SequenceChecker) (MsgAuthCodeHandler, error) {
+	return act.NewWithOptions(signKey, verifyKey, seqChecker, MACOptions{})
+}
+
+func (act MsgAuthCodeType) NewWithOptions(signKey, verifyKey []byte, seqChecker SequenceChecker, opts MACOptions) (MsgAuthCodeHandler, error) {
 	if !act.IsValid() {
 		return nil, fmt.Errorf("invalid auth code type: %q", act)
 	}
+	opts = opts.withDefaults()
 
 	// Create handler based on type.
 	switch act {
-	case MsgAuthCodeTypeHMACBlake3:
-		return &HashBasedMAC{
-			handlerType: MsgAuthCodeTypeHMACBlake3,
-			seqChecker:  seqChecker,
-			signer:      hmac.New(BLAKE3.New, signKey),
-			verifier:    hmac.New(BLAKE3.New, verifyKey),
-		}, nil
-
-	case MsgAuthCodeTypeBlake3:
-		signer, err := blake3.NewKeyed(signKey)
+	case MsgAuthCodeTypeHMACBlake3, MsgAuthCodeTypeBlake3:
+		signer, err := newMACHasher(act, signKey)
+		if err != nil {
+			return nil, err
+		}
+		verifier, err := newMACHasher(act, verifyKey)
 		if err != nil {
 			return nil, err
 		}
-		verifier, err := blake3.NewKeyed(verifyKey)
+		tagSize, err := opts.tagSize(signer.Size())
 		if err != nil {
 			return nil, err
 		}
-		return &HashBasedMAC{
-			handlerType: MsgAuthCodeTypeBlake3,
+		hbm := &HashBasedMAC{
+			handlerType: act,
 			seqChecker:  seqChecker,
+			nonceSize:   opts.NonceSize,
+			tagSize:     tagSize,
+			signKey:     bytes.Clone(signKey),
 			signer:      signer,
+			verifyKey:   bytes.Clone(verifyKey),
 			verifier:    verifier,
-		}, nil
+		}
+		if opts.Concurrent {
+			hbm.concurrent = true
+			hbm.signer = nil
+			hbm.verifier = nil
+		}
+		return hbm, nil
+
+	case MsgAuthCodeTypePoly1305:
+		return newPoly1305MAC(signKey, verifyKey, seqChecker, opts)
 
 	default:
 		return nil, fmt.Errorf("auth code type %s not yet implemented", act)
 	}
 }
 
+// newMACHasher returns a new keyed hash for the given hash based MAC type.
+func newMACHasher(act MsgAuthCodeType, key []byte) (hash.Hash, error) {
+	switch act {
+	case MsgAuthCodeTypeHMACBlake3:
+		return hmac.New(BLAKE3.New, key), nil
+	case MsgAuthCodeTypeBlake3:
+		return blake3.NewKeyed(key)
+	default:
+		return nil, fmt.Errorf("auth code type %s is not hash based", act)
+	}
+}
+
 func (act MsgAuthCodeType) String() string {
 	return string(act)
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (act MsgAuthCodeType) MarshalText() ([]byte, error) {
+	return []byte(act), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unknown names are rejected with ErrInvalidFormat.
+func (act *MsgAuthCodeType) UnmarshalText(text []byte) error {
+	parsed := MsgAuthCodeType(text)
+	if !parsed.IsValid() {
+		return fmt.Errorf("%w: unknown auth code type %q", ErrInvalidFormat, text)
+	}
+	*act = parsed
+	return nil
+}
+
 // MsgAuthCodeHandler generates and verifies message authentication codes.
 type MsgAuthCodeHandler interface {
 	// Type returns the MAC algorithm type.
 	Type() MsgAuthCodeType
 	// Sign generates an authentication code for the data.
+	// Returns nil if the handler was burned.
 	Sign(context string, data []byte) (mac []byte)
 	// Verify checks that the MAC is valid for the data.
 	Verify(context string, data []byte, mac []byte) error
+	// VerifyWithSeq checks that the MAC is valid for the data and returns the
+	// verified sequence number.
+	VerifyWithSeq(context string, data []byte, mac []byte) (seq uint64, err error)
+	// SignTyped generates an authentication code for the data and message type.
+	SignTyped(context string, msgType byte, data []byte) (mac []byte)
+	// VerifyTyped checks that the MAC is valid for the data and message type.
+	VerifyTyped(context string, msgType byte, data []byte, mac []byte) error
+	// SignDetached generates an authentication code for the data using the
+	// given sequence number, which is authenticated but not included in the MAC.
+	// Use this when the transport already carries the sequence number.
+	// The sequence checker is not used for signing.
+	SignDetached(context string, data []byte, seq uint64) (mac []byte)
+	// VerifyDetached checks that the detached MAC is valid for the data and
+	// sequence number. The sequence number is checked with the sequence checker.
+	VerifyDetached(context string, data []byte, seq uint64, mac []byte) error
+	// Rekey replaces the signing and verification keys in place.
+	// Calls running concurrently use either the old or the new keys.
+	// The sequence checker is not reset, call its Reset if needed.
+	Rekey(signKey, verifyKey []byte) error
 	// Burn securely erases key material from memory.
 	Burn()
 }
@@ -97,12 +229,54 @@ type MsgAuthCodeHandler interface {
 type HashBasedMAC struct {
 	handlerType MsgAuthCodeType
 	seqChecker  SequenceChecker
+	nonceSize   int
+	tagSize     int
 
+	signKey  []byte
 	signer   hash.Hash
+	signVH   ValueHasher // Reused to avoid allocations.
 	signLock sync.Mutex
 
-	verifier   hash.Hash
-	verifyLock sync.Mutex
+	verifyKey      []byte
+	verifier       hash.Hash
+	verifyVH       ValueHasher // Reused to avoid allocations.
+	verifyChecksum [64]byte
+	verifyLock     sync.Mutex
+
+	// Concurrent mode: signer and verifier are nil and hashers are taken
+	// from the pools instead. The pool lock is only held exclusively by
+	// Rekey and Burn. Pooled hashers of previous keys are discarded.
+	concurrent bool
+	poolLock   sync.RWMutex
+	signPool   sync.Pool
+	verifyPool sync.Pool
+	keyGen     uint64
+}
+
+// pooledHasher is a hasher with buffers for use in concurrent mode.
+type pooledHasher struct {
+	hasher   hash.Hash
+	vh       ValueHasher
+	checksum [64]byte
+	keyGen   uint64
+}
+
+// getPooledHasher returns a hasher for the given key from the pool, or a new
+// one if the pool is empty or only holds hashers of previous keys.
+// The pool lock must be held.
+func (hbm *HashBasedMAC) getPooledHasher(pool *sync.Pool, key []byte) *pooledHasher {
+	if ph, ok := pool.Get().(*pooledHasher); ok && ph.keyGen == hbm.keyGen {
+		return ph
+	}
+	hasher, err := newMACHasher(hbm.handlerType, key)
+	if err != nil {
+		// Key was already checked when it was set.
+		panic(err)
+	}
+	return &pooledHasher{
+		hasher: hasher,
+		keyGen: hbm.keyGen,
+	}
 }
 
 func (hbm *HashBasedMAC) Type() MsgAuthCodeType {
@@ -110,79 +284,253 @@ func (hbm *HashBasedMAC) Type() MsgAuthCodeType {
 }
 
 func (hbm *HashBasedMAC) Sign(context string, data []byte) (mac []byte) {
-	hbm.signLock.Lock()
-	defer hbm.signLock.Unlock()
-	defer hbm.signer.Reset()
+	return hbm.sign(context, nil, data, false, 0)
+}
+
+func (hbm *HashBasedMAC) SignTyped(context string, msgType byte, data []byte) (mac []byte) {
+	return hbm.sign(context, []byte{msgType}, data, false, 0)
+}
+
+func (hbm *HashBasedMAC) SignDetached(context string, data []byte, seq uint64) (mac []byte) {
+	return hbm.sign(context, nil, data, true, seq)
+}
+
+// sign generates the MAC. If msgType is not nil, it is added as an additional field.
+// If detached is set, the given sequence number is used and left out of the MAC.
+func (hbm *HashBasedMAC) sign(context string, msgType []byte, data []byte, detached bool, sequence uint64) (mac []byte) {
+	// Get hasher and check if burned.
+	var (
+		signer hash.Hash
+		vh     *ValueHasher
+	)
+	if hbm.concurrent {
+		hbm.poolLock.RLock()
+		defer hbm.poolLock.RUnlock()
+		if hbm.signKey == nil {
+			return nil
+		}
+		ph := hbm.getPooledHasher(&hbm.signPool, hbm.signKey)
+		defer hbm.signPool.Put(ph)
+		signer, vh = ph.hasher, &ph.vh
+	} else {
+		hbm.signLock.Lock()
+		defer hbm.signLock.Unlock()
+		if hbm.signer == nil {
+			return nil
+		}
+		signer, vh = hbm.signer, &hbm.signVH
+	}
+	defer signer.Reset()
 
 	// Create slice for the new MAC.
-	mac = make([]byte, 9+macNonceSize+hbm.signer.Size())
+	mac = make([]byte, binary.MaxVarintLen64+hbm.nonceSize+signer.Size())
 
-	// Create value hasher with signer.
-	vh := NewValueHasher(hbm.signer)
+	// Reset value hasher with signer.
+	vh.reset(signer)
 	vh.AddString(context)
 
 	// Increment and add sequence number for replay protection.
-	sequence := hbm.seqChecker.NextOutSequence()
+	var size int
+	if !detached {
+		sequence = hbm.seqChecker.NextOutSequence()
+		size = binary.PutUvarint(mac, sequence)
+	}
 	vh.AddUint(sequence)
-	size := binary.PutUvarint(mac, sequence)
 
 	// Add nonce to prevent MAC reuse.
-	//nolint:errcheck,gosec // crypto/rand.Read cannot fail
-	rand.Read(mac[size : size+macNonceSize])
-	vh.Add(mac[size : size+macNonceSize])
-	size += macNonceSize
+	readRandom(mac[size : size+hbm.nonceSize])
+	vh.Add(mac[size : size+hbm.nonceSize])
+	size += hbm.nonceSize
+
+	// Add message type, if set.
+	if msgType != nil {
+		vh.Add(msgType)
+	}
 
 	// Add data and generate checksum.
 	vh.Add(data)
-	vh.Sum(mac[size:])
-	size += hbm.signer.Size()
+	vh.Sum(mac[size:size])
+	size += hbm.tagSize
 
-	// Return full MAC without extra bytes.
+	// Return MAC without extra bytes, truncating the checksum if configured.
 	return mac[:size]
 }
 
 func (hbm *HashBasedMAC) Verify(context string, data []byte, mac []byte) error {
-	hbm.verifyLock.Lock()
-	defer hbm.verifyLock.Unlock()
-	defer hbm.verifier.Reset()
+	_, err := hbm.verify(context, nil, data, mac, false, 0)
+	return err
+}
+
+func (hbm *HashBasedMAC) VerifyWithSeq(context string, data []byte, mac []byte) (seq uint64, err error) {
+	return hbm.verify(context, nil, data, mac, false, 0)
+}
+
+func (hbm *HashBasedMAC) VerifyTyped(context string, msgType byte, data []byte, mac []byte) error {
+	_, err := hbm.verify(context, []byte{msgType}, data, mac, false, 0)
+	return err
+}
+
+func (hbm *HashBasedMAC) VerifyDetached(context string, data []byte, seq uint64, mac []byte) error {
+	_, err := hbm.verify(context, nil, data, mac, true, seq)
+	return err
+}
+
+// verify checks the MAC and returns the verified sequence number.
+// If msgType is not nil, it is added as an additional field.
+// If detached is set, the given sequence number is used instead of reading it from the MAC.
+func (hbm *HashBasedMAC) verify(context string, msgType []byte, data []byte, mac []byte, detached bool, seqNum uint64) (seq uint64, err error) {
+	// Get hasher and check if burned.
+	var (
+		verifier hash.Hash
+		vh       *ValueHasher
+		checksum []byte
+	)
+	if hbm.concurrent {
+		hbm.poolLock.RLock()
+		defer hbm.poolLock.RUnlock()
+		if hbm.verifyKey == nil {
+			return 0, fmt.Errorf("%w: %w", ErrAuthCodeInvalid, ErrBurned)
+		}
+		ph := hbm.getPooledHasher(&hbm.verifyPool, hbm.verifyKey)
+		defer hbm.verifyPool.Put(ph)
+		verifier, vh, checksum = ph.hasher, &ph.vh, ph.checksum[:0]
+	} else {
+		hbm.verifyLock.Lock()
+		defer hbm.verifyLock.Unlock()
+		if hbm.verifier == nil {
+			return 0, fmt.Errorf("%w: %w", ErrAuthCodeInvalid, ErrBurned)
+		}
+		verifier, vh, checksum = hbm.verifier, &hbm.verifyVH, hbm.verifyChecksum[:0]
+	}
+	defer verifier.Reset()
 
-	// Create value hasher with verifier.
-	vh := NewValueHasher(hbm.verifier)
+	// Reset value hasher with verifier.
+	vh.reset(verifier)
 	vh.AddString(context)
 
 	// Extract sequence number (validated after MAC verification).
-	seqNum, seqSize := binary.Uvarint(mac)
-	if seqSize <= 0 {
-		return fmt.Errorf("%w: too short", ErrAuthCodeInvalid)
+	seqNum, seqSize, err := macSequence(mac, detached, seqNum)
+	if err != nil {
+		return 0, err
 	}
 	vh.AddUint(seqNum)
 
 	// Check nonce size.
-	nonceSize := len(mac) - seqSize - hbm.verifier.Size()
+	nonceSize := len(mac) - seqSize - hbm.tagSize
 	if nonceSize < macMinNonceSize {
-		return fmt.Errorf("%w: too short", ErrAuthCodeInvalid)
+		return 0, fmt.Errorf("%w: too short", ErrAuthCodeInvalid)
 	}
 	vh.Add(mac[seqSize : seqSize+nonceSize])
 
+	// Add message type, if set.
+	if msgType != nil {
+		vh.Add(msgType)
+	}
+
 	// Generate checksum.
 	vh.Add(data)
-	var compareChecksumBuf [64]byte
-	compareChecksum := compareChecksumBuf[:hbm.verifier.Size()]
-	vh.Sum(compareChecksum)
+	compareChecksum := vh.Sum(checksum)
 
 	// Compare checksum.
-	if subtle.ConstantTimeCompare(mac[seqSize+nonceSize:], compareChecksum) != 1 {
-		return ErrAuthCodeInvalid
+	if subtle.ConstantTimeCompare(mac[seqSize+nonceSize:], compareChecksum[:hbm.tagSize]) != 1 {
+		return 0, ErrAuthCodeInvalid
 	}
 
 	// Check sequence number.
 	if !hbm.seqChecker.CheckInSequence(seqNum) {
-		return fmt.Errorf("%w: sequence violation", ErrAuthCodeInvalid)
+		return 0, fmt.Errorf("%w: sequence violation", ErrAuthCodeInvalid)
+	}
+
+	return seqNum, nil
+}
+
+// macSequence returns the sequence number and the size it occupies in the MAC.
+// If detached is set, the given sequence number is returned with a size of zero.
+func macSequence(mac []byte, detached bool, seq uint64) (seqNum uint64, seqSize int, err error) {
+	if detached {
+		return seq, 0, nil
+	}
+
+	seqNum, seqSize = binary.Uvarint(mac)
+	switch {
+	case seqSize == 0:
+		return 0, 0, fmt.Errorf("%w: too short", ErrAuthCodeInvalid)
+	case seqSize < 0:
+		return 0, 0, fmt.Errorf("%w: sequence overflow", ErrAuthCodeInvalid)
+	}
+	return seqNum, seqSize, nil
+}
+
+// Rekey replaces the keys and hashers in place.
+// Calls running concurrently use either the old or the new keys.
+func (hbm *HashBasedMAC) Rekey(signKey, verifyKey []byte) error {
+	// Create new hashers first, which also checks the keys.
+	signer, err := newMACHasher(hbm.handlerType, signKey)
+	if err != nil {
+		return err
+	}
+	verifier, err := newMACHasher(hbm.handlerType, verifyKey)
+	if err != nil {
+		return err
+	}
+
+	hbm.signLock.Lock()
+	defer hbm.signLock.Unlock()
+	hbm.verifyLock.Lock()
+	defer hbm.verifyLock.Unlock()
+	hbm.poolLock.Lock()
+	defer hbm.poolLock.Unlock()
+
+	// Check if burned.
+	if hbm.signKey == nil {
+		return ErrBurned
 	}
 
+	// Replace keys.
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	// The hashers keep internal copies of the keys that cannot be wiped.
+	clear(hbm.signKey)
+	clear(hbm.verifyKey)
+	hbm.signKey = bytes.Clone(signKey)
+	hbm.verifyKey = bytes.Clone(verifyKey)
+
+	// Replace hashers.
+	if hbm.concurrent {
+		hbm.keyGen++
+	} else {
+		hbm.signer.Reset()
+		hbm.verifier.Reset()
+		hbm.signer = signer
+		hbm.verifier = verifier
+	}
 	return nil
 }
 
+// Burn zeroizes the key material and disables the handler.
+// Afterwards, Sign returns nil and Verify fails with ErrBurned.
 func (hbm *HashBasedMAC) Burn() {
-	// TODO: Any way we can burn the hash constructs?
+	hbm.signLock.Lock()
+	defer hbm.signLock.Unlock()
+	hbm.verifyLock.Lock()
+	defer hbm.verifyLock.Unlock()
+	hbm.poolLock.Lock()
+	defer hbm.poolLock.Unlock()
+
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	// The hashers keep internal copies of the keys that cannot be wiped.
+	// Pooled hashers of the concurrent mode are released by the garbage collector.
+	clear(hbm.signKey)
+	clear(hbm.verifyKey)
+	clear(hbm.verifyChecksum[:])
+	hbm.signKey = nil
+	hbm.verifyKey = nil
+	if hbm.signer != nil {
+		hbm.signer.Reset()
+		hbm.signer = nil
+	}
+	if hbm.verifier != nil {
+		hbm.verifier.Reset()
+		hbm.verifier = nil
+	}
+	hbm.seqChecker = nil
 }
@@ -0,0 +1,210 @@
+package crop
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/poly1305" //nolint:staticcheck // One-time keys are derived per message.
+)
+
+// Poly1305MAC implements MsgAuthCodeHandler using Poly1305 with a one-time
+// key per message. The one-time key is derived with keyed BLAKE3 from the
+// base key, context, sequence number, nonce and message type.
+// The wire format is the same as for HashBasedMAC with a 16 byte tag.
+type Poly1305MAC struct {
+	seqChecker SequenceChecker
+	nonceSize  int
+
+	lock      sync.RWMutex
+	signKey   []byte
+	verifyKey []byte
+}
+
+func newPoly1305MAC(signKey, verifyKey []byte, seqChecker SequenceChecker, opts MACOptions) (*Poly1305MAC, error) {
+	switch {
+	case len(signKey) != blake3KeySize || len(verifyKey) != blake3KeySize:
+		return nil, fmt.Errorf("auth code type %s requires %d byte keys", MsgAuthCodeTypePoly1305, blake3KeySize)
+	case opts.TagSize != 0 && opts.TagSize != poly1305.TagSize:
+		return nil, fmt.Errorf("auth code type %s does not support truncated tags", MsgAuthCodeTypePoly1305)
+	}
+
+	return &Poly1305MAC{
+		seqChecker: seqChecker,
+		nonceSize:  opts.NonceSize,
+		signKey:    bytes.Clone(signKey),
+		verifyKey:  bytes.Clone(verifyKey),
+	}, nil
+}
+
+func (pm *Poly1305MAC) Type() MsgAuthCodeType {
+	return MsgAuthCodeTypePoly1305
+}
+
+func (pm *Poly1305MAC) Sign(context string, data []byte) (mac []byte) {
+	return pm.sign(context, nil, data, false, 0)
+}
+
+func (pm *Poly1305MAC) SignTyped(context string, msgType byte, data []byte) (mac []byte) {
+	return pm.sign(context, []byte{msgType}, data, false, 0)
+}
+
+func (pm *Poly1305MAC) SignDetached(context string, data []byte, seq uint64) (mac []byte) {
+	return pm.sign(context, nil, data, true, seq)
+}
+
+// sign generates the MAC. If msgType is not nil, it is added as an additional field.
+// If detached is set, the given sequence number is used and left out of the MAC.
+func (pm *Poly1305MAC) sign(context string, msgType []byte, data []byte, detached bool, sequence uint64) (mac []byte) {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+
+	// Check if burned.
+	if pm.signKey == nil {
+		return nil
+	}
+
+	// Create slice for the new MAC.
+	mac = make([]byte, binary.MaxVarintLen64+pm.nonceSize+poly1305.TagSize)
+
+	// Add sequence number and nonce.
+	var size int
+	if !detached {
+		sequence = pm.seqChecker.NextOutSequence()
+		size = binary.PutUvarint(mac, sequence)
+	}
+	nonce := mac[size : size+pm.nonceSize]
+	readRandom(nonce)
+	size += pm.nonceSize
+
+	// Derive one-time key and generate tag.
+	oneTimeKey := pm.oneTimeKey(pm.signKey, context, sequence, nonce, msgType)
+	defer clear(oneTimeKey[:])
+	var tag [poly1305.TagSize]byte
+	poly1305.Sum(&tag, data, &oneTimeKey)
+	size += copy(mac[size:], tag[:])
+
+	// Return full MAC without extra bytes.
+	return mac[:size]
+}
+
+func (pm *Poly1305MAC) Verify(context string, data []byte, mac []byte) error {
+	_, err := pm.verify(context, nil, data, mac, false, 0)
+	return err
+}
+
+func (pm *Poly1305MAC) VerifyWithSeq(context string, data []byte, mac []byte) (seq uint64, err error) {
+	return pm.verify(context, nil, data, mac, false, 0)
+}
+
+func (pm *Poly1305MAC) VerifyTyped(context string, msgType byte, data []byte, mac []byte) error {
+	_, err := pm.verify(context, []byte{msgType}, data, mac, false, 0)
+	return err
+}
+
+func (pm *Poly1305MAC) VerifyDetached(context string, data []byte, seq uint64, mac []byte) error {
+	_, err := pm.verify(context, nil, data, mac, true, seq)
+	return err
+}
+
+// verify checks the MAC and returns the verified sequence number.
+// If msgType is not nil, it is added as an additional field.
+// If detached is set, the given sequence number is used instead of reading it from the MAC.
+func (pm *Poly1305MAC) verify(context string, msgType []byte, data []byte, mac []byte, detached bool, seqNum uint64) (seq uint64, err error) {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+
+	// Check if burned.
+	if pm.verifyKey == nil {
+		return 0, fmt.Errorf("%w: %w", ErrAuthCodeInvalid, ErrBurned)
+	}
+
+	// Extract sequence number (validated after MAC verification).
+	seqNum, seqSize, err := macSequence(mac, detached, seqNum)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check nonce size.
+	nonceSize := len(mac) - seqSize - poly1305.TagSize
+	if nonceSize < macMinNonceSize {
+		return 0, fmt.Errorf("%w: too short", ErrAuthCodeInvalid)
+	}
+	nonce := mac[seqSize : seqSize+nonceSize]
+
+	// Derive one-time key and generate tag.
+	oneTimeKey := pm.oneTimeKey(pm.verifyKey, context, seqNum, nonce, msgType)
+	defer clear(oneTimeKey[:])
+	var tag [poly1305.TagSize]byte
+	poly1305.Sum(&tag, data, &oneTimeKey)
+
+	// Compare tag.
+	if subtle.ConstantTimeCompare(mac[seqSize+nonceSize:], tag[:]) != 1 {
+		return 0, ErrAuthCodeInvalid
+	}
+
+	// Check sequence number.
+	if !pm.seqChecker.CheckInSequence(seqNum) {
+		return 0, fmt.Errorf("%w: sequence violation", ErrAuthCodeInvalid)
+	}
+
+	return seqNum, nil
+}
+
+// oneTimeKey derives the Poly1305 key for a single message.
+func (pm *Poly1305MAC) oneTimeKey(baseKey []byte, context string, sequence uint64, nonce, msgType []byte) (key [32]byte) {
+	hasher, err := blake3.NewKeyed(baseKey)
+	if err != nil {
+		// Key size is checked in the constructor.
+		panic(err)
+	}
+	vh := NewValueHasher(hasher)
+	vh.AddString(context)
+	vh.AddUint(sequence)
+	vh.Add(nonce)
+	if msgType != nil {
+		vh.Add(msgType)
+	}
+	vh.Sum(key[:0])
+	return key
+}
+
+// Rekey replaces the keys in place.
+// Calls running concurrently use either the old or the new keys.
+func (pm *Poly1305MAC) Rekey(signKey, verifyKey []byte) error {
+	if len(signKey) != blake3KeySize || len(verifyKey) != blake3KeySize {
+		return fmt.Errorf("auth code type %s requires %d byte keys", MsgAuthCodeTypePoly1305, blake3KeySize)
+	}
+
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	// Check if burned.
+	if pm.signKey == nil {
+		return ErrBurned
+	}
+
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	clear(pm.signKey)
+	clear(pm.verifyKey)
+	pm.signKey = bytes.Clone(signKey)
+	pm.verifyKey = bytes.Clone(verifyKey)
+	return nil
+}
+
+// Burn zeroizes the key material and disables the handler.
+// Afterwards, Sign returns nil and Verify fails with ErrBurned.
+func (pm *Poly1305MAC) Burn() {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	clear(pm.signKey)
+	clear(pm.verifyKey)
+	pm.signKey = nil
+	pm.verifyKey = nil
+	pm.seqChecker = nil
+}
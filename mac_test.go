@@ -3,18 +3,19 @@ package crop
 // Note: Partly LLM-Generated.
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	mathRand "math/rand"
 	"strconv"
+	"sync"
 	"testing"
 )
 
 func TestAuthCode_SignVerify_Simple(t *testing.T) {
-	acts := []MsgAuthCodeType{
-		MsgAuthCodeTypeHMACBlake3,
-	}
+	acts := AllMsgAuthCodeTypes()
 
 	for _, act := range acts {
 		t.Run(string(act), func(t *testing.T) {
@@ -57,9 +58,7 @@ func TestAuthCode_SignVerify_Simple(t *testing.T) {
 }
 
 func TestAuthCode_SignVerify_Randomized_BothDirections(t *testing.T) {
-	acts := []MsgAuthCodeType{
-		MsgAuthCodeTypeHMACBlake3,
-	}
+	acts := AllMsgAuthCodeTypes()
 
 	type entry struct {
 		id   string
@@ -132,9 +131,7 @@ func TestAuthCode_SignVerify_Randomized_BothDirections(t *testing.T) {
 func TestAuthCode_ErrorCases(t *testing.T) {
 	t.Parallel()
 
-	acts := []MsgAuthCodeType{
-		MsgAuthCodeTypeHMACBlake3,
-	}
+	acts := AllMsgAuthCodeTypes()
 
 	for _, act := range acts {
 		t.Run(string(act), func(t *testing.T) {
@@ -239,3 +236,522 @@ func TestAuthCode_ErrorCases(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthCode_SignVerifyTyped(t *testing.T) {
+	t.Parallel()
+
+	acts := AllMsgAuthCodeTypes()
+
+	const (
+		msgTypeA byte = 1
+		msgTypeB byte = 2
+	)
+
+	for _, act := range acts {
+		t.Run(string(act), func(t *testing.T) {
+			aKey := make([]byte, 32)
+			bKey := make([]byte, 32)
+			rand.Read(aKey)
+			rand.Read(bKey)
+
+			signer, err := NewAuthCodeHandler(act, aKey, bKey, NewLooseSequenceChecker())
+			if err != nil {
+				t.Fatalf("create signer: %v", err)
+			}
+			verifier, err := NewAuthCodeHandler(act, bKey, aKey, NewLooseSequenceChecker())
+			if err != nil {
+				t.Fatalf("create verifier: %v", err)
+			}
+
+			data := []byte("payload")
+
+			// Correct type verifies.
+			mac := signer.SignTyped("typed", msgTypeA, data)
+			if err := verifier.VerifyTyped("typed", msgTypeA, data, mac); err != nil {
+				t.Fatalf("verify typed failed: %v", err)
+			}
+
+			// Type confusion is rejected.
+			mac = signer.SignTyped("typed", msgTypeA, data)
+			if err := verifier.VerifyTyped("typed", msgTypeB, data, mac); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrAuthCodeInvalid for wrong message type, got: %v", err)
+			}
+
+			// Typed and untyped MACs are not interchangeable.
+			mac = signer.SignTyped("typed", msgTypeA, data)
+			if err := verifier.Verify("typed", data, mac); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrAuthCodeInvalid for typed MAC verified untyped, got: %v", err)
+			}
+			mac = signer.Sign("typed", data)
+			if err := verifier.VerifyTyped("typed", msgTypeA, data, mac); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrAuthCodeInvalid for untyped MAC verified typed, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthCode_SignVerifyDetached(t *testing.T) {
+	t.Parallel()
+
+	for _, act := range AllMsgAuthCodeTypes() {
+		t.Run(string(act), func(t *testing.T) {
+			aKey := make([]byte, 32)
+			bKey := make([]byte, 32)
+			rand.Read(aKey)
+			rand.Read(bKey)
+
+			signer, err := NewAuthCodeHandler(act, aKey, bKey, NewLooseSequenceChecker())
+			if err != nil {
+				t.Fatalf("create signer: %v", err)
+			}
+			verifier, err := NewAuthCodeHandler(act, bKey, aKey, NewLooseSequenceChecker())
+			if err != nil {
+				t.Fatalf("create verifier: %v", err)
+			}
+
+			data := []byte("payload")
+
+			// Detached MAC verifies and does not contain the sequence number.
+			mac := signer.SignDetached("detached", data, 1000)
+			embedded := signer.Sign("detached", data)
+			if len(mac) >= len(embedded) {
+				t.Fatalf("expected detached MAC to be shorter than %d bytes, got %d", len(embedded), len(mac))
+			}
+			if err := verifier.VerifyDetached("detached", data, 1000, mac); err != nil {
+				t.Fatalf("verify detached failed: %v", err)
+			}
+
+			// Wrong sequence number is rejected.
+			mac = signer.SignDetached("detached", data, 1001)
+			if err := verifier.VerifyDetached("detached", data, 1002, mac); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrAuthCodeInvalid for wrong sequence, got: %v", err)
+			}
+
+			// Replay is rejected by the sequence checker.
+			if err := verifier.VerifyDetached("detached", data, 1001, mac); err != nil {
+				t.Fatalf("verify detached failed: %v", err)
+			}
+			if err := verifier.VerifyDetached("detached", data, 1001, mac); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrAuthCodeInvalid for replay, got: %v", err)
+			}
+
+			// Detached and embedded MACs are not interchangeable.
+			mac = signer.SignDetached("detached", data, 1003)
+			if err := verifier.Verify("detached", data, mac); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrAuthCodeInvalid for detached MAC verified embedded, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthCode_Verify_SequenceOverflow(t *testing.T) {
+	t.Parallel()
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	verifier, err := NewAuthCodeHandler(MsgAuthCodeTypeHMACBlake3, key, key, NewStrictSequenceChecker())
+	if err != nil {
+		t.Fatalf("create verifier: %v", err)
+	}
+
+	// Overlong uvarint: 11 continuation bytes.
+	mac := make([]byte, 11+macNonceSize+32)
+	for i := range 11 {
+		mac[i] = 0xFF
+	}
+	err = verifier.Verify("", []byte("data"), mac)
+	if !errors.Is(err, ErrAuthCodeInvalid) {
+		t.Fatalf("expected ErrAuthCodeInvalid for overlong sequence, got: %v", err)
+	}
+}
+
+func TestAuthCode_VerifyWithSeq(t *testing.T) {
+	t.Parallel()
+
+	for _, act := range AllMsgAuthCodeTypes() {
+		t.Run(string(act), func(t *testing.T) {
+			key := NewSecret(32)
+			signer, err := NewAuthCodeHandler(act, key, key, NewLooseSequenceChecker())
+			if err != nil {
+				t.Fatalf("create signer: %v", err)
+			}
+			verifier, err := NewAuthCodeHandler(act, key, key, NewLooseSequenceChecker())
+			if err != nil {
+				t.Fatalf("create verifier: %v", err)
+			}
+
+			macs := make([][]byte, 3)
+			for i := range macs {
+				macs[i] = signer.Sign("seq", []byte{byte(i)})
+			}
+			for _, i := range []int{2, 0, 1} {
+				seq, err := verifier.VerifyWithSeq("seq", []byte{byte(i)}, macs[i])
+				if err != nil {
+					t.Fatalf("verify %d: %v", i, err)
+				}
+				if seq != uint64(i+1) {
+					t.Fatalf("seq = %d, want %d", seq, i+1)
+				}
+			}
+
+			// Failed verification returns no sequence number.
+			seq, err := verifier.VerifyWithSeq("seq", []byte("bad"), signer.Sign("seq", []byte("good")))
+			if !errors.Is(err, ErrAuthCodeInvalid) || seq != 0 {
+				t.Fatalf("expected ErrAuthCodeInvalid and seq 0, got %d, %v", seq, err)
+			}
+		})
+	}
+}
+
+func TestAuthCode_NonceSizeOption(t *testing.T) {
+	t.Parallel()
+
+	key := NewSecret(32)
+	verifier, err := NewAuthCodeHandler(MsgAuthCodeTypeBlake3, key, key, NewNoopSequenceChecker())
+	if err != nil {
+		t.Fatalf("create verifier: %v", err)
+	}
+
+	for _, tc := range []struct{ nonceSize, want int }{
+		{0, macNonceSize},
+		{1, macMinNonceSize},
+		{8, 8},
+		{32, 32},
+	} {
+		signer, err := NewAuthCodeHandlerWithOptions(MsgAuthCodeTypeBlake3, key, key, NewLooseSequenceChecker(), MACOptions{NonceSize: tc.nonceSize})
+		if err != nil {
+			t.Fatalf("create signer: %v", err)
+		}
+		data := []byte("payload")
+		mac := signer.Sign("nonce", data)
+		// Sequence 1 is encoded in a single byte.
+		if got := len(mac) - 1 - 32; got != tc.want {
+			t.Fatalf("nonce size %d: got %d, want %d", tc.nonceSize, got, tc.want)
+		}
+		if err := verifier.Verify("nonce", data, mac); err != nil {
+			t.Fatalf("nonce size %d: verify: %v", tc.nonceSize, err)
+		}
+	}
+}
+
+func TestAuthCode_Burn(t *testing.T) {
+	t.Parallel()
+
+	for _, act := range AllMsgAuthCodeTypes() {
+		t.Run(string(act), func(t *testing.T) {
+			key := NewSecret(32)
+			handler, err := NewAuthCodeHandler(act, key, key, NewLooseSequenceChecker())
+			if err != nil {
+				t.Fatalf("create handler: %v", err)
+			}
+			mac := handler.Sign("burn", []byte("data"))
+
+			handler.Burn()
+			switch h := handler.(type) {
+			case *HashBasedMAC:
+				if h.signKey != nil || h.verifyKey != nil {
+					t.Fatalf("expected keys to be removed")
+				}
+			case *Poly1305MAC:
+				if h.signKey != nil || h.verifyKey != nil {
+					t.Fatalf("expected keys to be removed")
+				}
+			}
+
+			if mac := handler.Sign("burn", []byte("data")); mac != nil {
+				t.Fatalf("expected nil MAC after burn, got %x", mac)
+			}
+			err = handler.Verify("burn", []byte("data"), mac)
+			if !errors.Is(err, ErrBurned) || !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrBurned after burn, got %v", err)
+			}
+
+			// Caller's key is not affected.
+			if bytes.Equal(key, make([]byte, len(key))) {
+				t.Fatalf("caller's key was zeroed")
+			}
+		})
+	}
+}
+
+func TestAuthCode_TagSizeOption(t *testing.T) {
+	t.Parallel()
+
+	key := NewSecret(32)
+	opts := MACOptions{TagSize: 16}
+	for _, act := range []MsgAuthCodeType{MsgAuthCodeTypeHMACBlake3, MsgAuthCodeTypeBlake3} {
+		t.Run(string(act), func(t *testing.T) {
+			signer, err := NewAuthCodeHandlerWithOptions(act, key, key, NewLooseSequenceChecker(), opts)
+			if err != nil {
+				t.Fatalf("create signer: %v", err)
+			}
+			verifier, err := NewAuthCodeHandlerWithOptions(act, key, key, NewLooseSequenceChecker(), opts)
+			if err != nil {
+				t.Fatalf("create verifier: %v", err)
+			}
+
+			data := []byte("tiny frame")
+			mac := signer.Sign("iot", data)
+			if len(mac) != 1+macNonceSize+16 {
+				t.Fatalf("MAC length = %d, want %d", len(mac), 1+macNonceSize+16)
+			}
+			if err := verifier.Verify("iot", data, mac); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+
+			// Tampering is still detected.
+			mac = signer.Sign("iot", data)
+			mac[len(mac)-1] ^= 0xFF
+			if err := verifier.Verify("iot", data, mac); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("expected ErrAuthCodeInvalid, got %v", err)
+			}
+
+			// Full size verifier rejects truncated MACs.
+			full, err := NewAuthCodeHandler(act, key, key, NewNoopSequenceChecker())
+			if err != nil {
+				t.Fatalf("create verifier: %v", err)
+			}
+			if err := full.Verify("iot", data, signer.Sign("iot", data)); err == nil {
+				t.Fatalf("expected full size verifier to reject truncated MAC")
+			}
+		})
+	}
+
+	// Invalid tag sizes.
+	for _, tagSize := range []int{1, macMinTagSize - 1, 33} {
+		if _, err := NewAuthCodeHandlerWithOptions(MsgAuthCodeTypeBlake3, key, key, NewNoopSequenceChecker(), MACOptions{TagSize: tagSize}); err == nil {
+			t.Fatalf("expected error for tag size %d", tagSize)
+		}
+	}
+	if _, err := NewAuthCodeHandlerWithOptions(MsgAuthCodeTypePoly1305, key, key, NewNoopSequenceChecker(), MACOptions{TagSize: 12}); err == nil {
+		t.Fatalf("expected error for truncated Poly1305 tag")
+	}
+}
+
+func TestAuthCode_Poly1305(t *testing.T) {
+	t.Parallel()
+
+	aKey := NewSecret(32)
+	bKey := NewSecret(32)
+	handler, err := NewAuthCodeHandler(MsgAuthCodeTypePoly1305, aKey, bKey, NewLooseSequenceChecker())
+	if err != nil {
+		t.Fatalf("create handler: %v", err)
+	}
+	if handler.Type() != MsgAuthCodeTypePoly1305 {
+		t.Fatalf("Type() = %q, want %q", handler.Type(), MsgAuthCodeTypePoly1305)
+	}
+
+	// Wire format: sequence, nonce, 16 byte tag.
+	mac := handler.Sign("ctx", []byte("data"))
+	if len(mac) != 1+macNonceSize+16 {
+		t.Fatalf("MAC length = %d, want %d", len(mac), 1+macNonceSize+16)
+	}
+
+	// Each message uses a different one-time key, so identical messages
+	// result in different tags.
+	mac2 := handler.Sign("ctx", []byte("data"))
+	if bytes.Equal(mac[len(mac)-16:], mac2[len(mac2)-16:]) {
+		t.Fatalf("expected different tags for repeated message")
+	}
+
+	// Keys must be 32 bytes.
+	if _, err := NewAuthCodeHandler(MsgAuthCodeTypePoly1305, aKey[:16], bKey, NewLooseSequenceChecker()); err == nil {
+		t.Fatalf("expected error for short key")
+	}
+}
+
+func BenchmarkHashBasedMAC_Sign(b *testing.B) {
+	data := make([]byte, 1024)
+	for _, act := range []MsgAuthCodeType{MsgAuthCodeTypeHMACBlake3, MsgAuthCodeTypeBlake3} {
+		b.Run(string(act), func(b *testing.B) {
+			key := make([]byte, 32)
+			handler, err := NewAuthCodeHandler(act, key, key, NewNoopSequenceChecker())
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				if handler.Sign("bench", data) == nil {
+					b.Fatal("sign failed")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHashBasedMAC_Verify(b *testing.B) {
+	data := make([]byte, 1024)
+	for _, act := range []MsgAuthCodeType{MsgAuthCodeTypeHMACBlake3, MsgAuthCodeTypeBlake3} {
+		b.Run(string(act), func(b *testing.B) {
+			key := make([]byte, 32)
+			handler, err := NewAuthCodeHandler(act, key, key, NewNoopSequenceChecker())
+			if err != nil {
+				b.Fatal(err)
+			}
+			mac := handler.Sign("bench", data)
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := handler.Verify("bench", data, mac); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestHashBasedMAC_Allocations(t *testing.T) {
+	data := make([]byte, 1024)
+	for _, act := range []MsgAuthCodeType{MsgAuthCodeTypeHMACBlake3, MsgAuthCodeTypeBlake3} {
+		key := make([]byte, 32)
+		handler, err := NewAuthCodeHandler(act, key, key, NewNoopSequenceChecker())
+		if err != nil {
+			t.Fatal(err)
+		}
+		mac := handler.Sign("bench", data)
+
+		// Sign only allocates the returned MAC.
+		if allocs := testing.AllocsPerRun(100, func() { handler.Sign("bench", data) }); allocs > 1 {
+			t.Errorf("%s: Sign allocates %.0f times, want at most 1", act, allocs)
+		}
+		if allocs := testing.AllocsPerRun(100, func() { _ = handler.Verify("bench", data, mac) }); allocs > 0 {
+			t.Errorf("%s: Verify allocates %.0f times, want 0", act, allocs)
+		}
+	}
+}
+
+func TestHashBasedMAC_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	for _, act := range []MsgAuthCodeType{MsgAuthCodeTypeHMACBlake3, MsgAuthCodeTypeBlake3} {
+		aKey := make([]byte, 32)
+		bKey := make([]byte, 32)
+		rand.Read(aKey)
+		rand.Read(bKey)
+
+		// Concurrent and locked handlers are compatible.
+		a, err := NewAuthCodeHandlerWithOptions(act, aKey, bKey, NewLooseSequenceCheckerWithWindow(1024), MACOptions{Concurrent: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewAuthCodeHandler(act, bKey, aKey, NewLooseSequenceCheckerWithWindow(1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Sign and verify in parallel.
+		var wg sync.WaitGroup
+		errs := make(chan error, 200)
+		for i := range 100 {
+			wg.Go(func() {
+				data := []byte(strconv.Itoa(i))
+				if err := b.Verify("ctx", data, a.Sign("ctx", data)); err != nil {
+					errs <- fmt.Errorf("a->b: %w", err)
+				}
+				if err := a.Verify("ctx", data, b.Sign("ctx", data)); err != nil {
+					errs <- fmt.Errorf("b->a: %w", err)
+				}
+			})
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("%s: %v", act, err)
+		}
+
+		// Burn disables the concurrent handler.
+		a.Burn()
+		if a.Sign("ctx", nil) != nil {
+			t.Fatalf("%s: expected nil MAC after burn", act)
+		}
+		if err := a.Verify("ctx", nil, b.Sign("ctx", nil)); !errors.Is(err, ErrBurned) {
+			t.Fatalf("%s: expected ErrBurned, got %v", act, err)
+		}
+	}
+}
+
+func BenchmarkHashBasedMAC_SignParallel(b *testing.B) {
+	data := make([]byte, 1024)
+	for _, concurrent := range []bool{false, true} {
+		b.Run(fmt.Sprintf("concurrent=%v", concurrent), func(b *testing.B) {
+			key := make([]byte, 32)
+			handler, err := NewAuthCodeHandlerWithOptions(MsgAuthCodeTypeBlake3, key, key, NewNoopSequenceChecker(), MACOptions{Concurrent: concurrent})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if handler.Sign("bench", data) == nil {
+						b.Fatal("sign failed")
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestAuthCode_Rekey(t *testing.T) {
+	t.Parallel()
+
+	for _, act := range AllMsgAuthCodeTypes() {
+		for _, concurrent := range []bool{false, true} {
+			newKey := func() []byte {
+				key := make([]byte, 32)
+				rand.Read(key)
+				return key
+			}
+			aKey, bKey := newKey(), newKey()
+			aSeq, bSeq := NewLooseSequenceCheckerWithWindow(1024), NewLooseSequenceCheckerWithWindow(1024)
+			opts := MACOptions{Concurrent: concurrent}
+			a, err := NewAuthCodeHandlerWithOptions(act, aKey, bKey, aSeq, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := NewAuthCodeHandlerWithOptions(act, bKey, aKey, bSeq, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			oldMAC := a.Sign("ctx", []byte("data"))
+
+			// Rekey both sides while verifying concurrently. Every verification
+			// either sees the old or the new key, so it must not panic or race.
+			aKey, bKey = newKey(), newKey()
+			var wg sync.WaitGroup
+			for i := range 20 {
+				wg.Go(func() {
+					data := []byte(strconv.Itoa(i))
+					_ = b.Verify("ctx", data, a.Sign("ctx", data))
+				})
+			}
+			if err := a.Rekey(aKey, bKey); err != nil {
+				t.Fatalf("%s: rekey a: %v", act, err)
+			}
+			if err := b.Rekey(bKey, aKey); err != nil {
+				t.Fatalf("%s: rekey b: %v", act, err)
+			}
+			wg.Wait()
+			aSeq.Reset()
+			bSeq.Reset()
+
+			// New keys work, old MACs are rejected.
+			data := []byte("after rekey")
+			if err := b.Verify("ctx", data, a.Sign("ctx", data)); err != nil {
+				t.Fatalf("%s (concurrent=%v): verify after rekey: %v", act, concurrent, err)
+			}
+			if err := b.Verify("ctx", []byte("data"), oldMAC); !errors.Is(err, ErrAuthCodeInvalid) {
+				t.Fatalf("%s (concurrent=%v): expected old MAC to be rejected, got %v", act, concurrent, err)
+			}
+
+			// Invalid keys and burned handlers are rejected.
+			if act != MsgAuthCodeTypeHMACBlake3 {
+				if err := a.Rekey([]byte("short"), bKey); err == nil {
+					t.Fatalf("%s: expected error for short key", act)
+				}
+			}
+			a.Burn()
+			if err := a.Rekey(aKey, bKey); !errors.Is(err, ErrBurned) {
+				t.Fatalf("%s: expected ErrBurned, got %v", act, err)
+			}
+		}
+	}
+}
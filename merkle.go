@@ -0,0 +1,146 @@
+package crop
+
+import (
+	"crypto/subtle"
+	"fmt"
+)
+
+// Domain separation prefixes for Merkle tree nodes.
+const (
+	merkleLeafPrefix     = 0x00
+	merkleInternalPrefix = 0x01
+	merklePaddingPrefix  = 0x02
+)
+
+// MerkleTree builds a binary hash tree over a list of leaves.
+// Leaves and internal nodes are hashed with different prefixes to prevent
+// second-preimage attacks. If a level has an odd number of nodes, the last
+// node is paired with a padding node, so that every level is complete and
+// proofs only depend on the leaf index.
+// MerkleTree is not safe for concurrent use.
+type MerkleTree struct {
+	hash   Hash
+	leaves [][]byte // Leaf hashes.
+}
+
+// NewMerkleTree returns a new, empty Merkle tree using the given hash.
+func NewMerkleTree(h Hash) (*MerkleTree, error) {
+	if !h.IsValid() {
+		return nil, fmt.Errorf("invalid hash algorithm: %q", h)
+	}
+	return &MerkleTree{
+		hash: h,
+	}, nil
+}
+
+// Add adds a leaf to the tree.
+func (mt *MerkleTree) Add(leaf []byte) {
+	mt.leaves = append(mt.leaves, merkleLeafHash(mt.hash, leaf))
+}
+
+// Len returns the number of leaves in the tree.
+func (mt *MerkleTree) Len() int {
+	return len(mt.leaves)
+}
+
+// Root returns the root hash of the tree.
+// The root of an empty tree is the digest of no data.
+func (mt *MerkleTree) Root() []byte {
+	if len(mt.leaves) == 0 {
+		return mt.hash.Digest(nil)
+	}
+
+	level := mt.leaves
+	for len(level) > 1 {
+		level = mt.nextLevel(level)
+	}
+	return level[0]
+}
+
+// Proof returns the sibling hashes from the leaf at the given index up to the
+// root. Verify it with VerifyMerkleProof.
+func (mt *MerkleTree) Proof(index int) ([][]byte, error) {
+	if index < 0 || index >= len(mt.leaves) {
+		return nil, fmt.Errorf("leaf index %d out of range", index)
+	}
+
+	var proof [][]byte
+	level := mt.leaves
+	for len(level) > 1 {
+		// Add sibling of the current node.
+		if index%2 == 0 {
+			if index+1 < len(level) {
+				proof = append(proof, level[index+1])
+			} else {
+				proof = append(proof, merklePaddingHash(mt.hash))
+			}
+		} else {
+			proof = append(proof, level[index-1])
+		}
+
+		// Go up one level.
+		level = mt.nextLevel(level)
+		index /= 2
+	}
+	return proof, nil
+}
+
+// nextLevel returns the parent nodes of the given level.
+func (mt *MerkleTree) nextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 < len(level) {
+			next = append(next, merkleInternalHash(mt.hash, level[i], level[i+1]))
+		} else {
+			next = append(next, merkleInternalHash(mt.hash, level[i], merklePaddingHash(mt.hash)))
+		}
+	}
+	return next
+}
+
+// VerifyMerkleProof checks whether the leaf at the given index is part of the
+// tree with the given root, using a proof created by MerkleTree.Proof.
+func VerifyMerkleProof(root, leaf []byte, proof [][]byte, index int, h Hash) bool {
+	// Check inputs.
+	switch {
+	case !h.IsValid():
+		return false
+	case index < 0:
+		return false
+	case len(proof) < 63 && index>>len(proof) != 0:
+		// Index must fit into the tree height.
+		return false
+	}
+
+	// Walk up the tree.
+	node := merkleLeafHash(h, leaf)
+	for _, sibling := range proof {
+		if index%2 == 0 {
+			node = merkleInternalHash(h, node, sibling)
+		} else {
+			node = merkleInternalHash(h, sibling, node)
+		}
+		index /= 2
+	}
+
+	return subtle.ConstantTimeCompare(node, root) == 1
+}
+
+func merkleLeafHash(h Hash, leaf []byte) []byte {
+	hasher := h.New()
+	_, _ = hasher.Write([]byte{merkleLeafPrefix}) // Never returns an error.
+	_, _ = hasher.Write(leaf)
+	return hasher.Sum(nil)
+}
+
+func merkleInternalHash(h Hash, left, right []byte) []byte {
+	hasher := h.New()
+	_, _ = hasher.Write([]byte{merkleInternalPrefix}) // Never returns an error.
+	_, _ = hasher.Write(left)
+	_, _ = hasher.Write(right)
+	return hasher.Sum(nil)
+}
+
+func merklePaddingHash(h Hash) []byte {
+	return h.Digest([]byte{merklePaddingPrefix})
+}
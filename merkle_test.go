@@ -0,0 +1,81 @@
+package crop
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestMerkleTree(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{1, 2, 3, 4, 5, 7, 8, 13, 64} {
+		mt, err := NewMerkleTree(BLAKE3)
+		if err != nil {
+			t.Fatalf("create tree: %v", err)
+		}
+		for i := range size {
+			mt.Add(fmt.Appendf(nil, "leaf %d", i))
+		}
+		root := mt.Root()
+
+		// Every leaf can be proven.
+		for i := range size {
+			leaf := fmt.Appendf(nil, "leaf %d", i)
+			proof, err := mt.Proof(i)
+			if err != nil {
+				t.Fatalf("size %d: proof %d: %v", size, i, err)
+			}
+			if !VerifyMerkleProof(root, leaf, proof, i, BLAKE3) {
+				t.Fatalf("size %d: proof %d does not verify", size, i)
+			}
+
+			// Wrong leaf, index or hash fails.
+			if VerifyMerkleProof(root, []byte("other"), proof, i, BLAKE3) {
+				t.Fatalf("size %d: proof %d verifies wrong leaf", size, i)
+			}
+			if VerifyMerkleProof(root, leaf, proof, i+1, BLAKE3) {
+				t.Fatalf("size %d: proof %d verifies wrong index", size, i)
+			}
+			if VerifyMerkleProof(root, leaf, proof, i, SHA2_256) {
+				t.Fatalf("size %d: proof %d verifies with wrong hash", size, i)
+			}
+		}
+
+		// Out of range.
+		if _, err := mt.Proof(size); err == nil {
+			t.Fatalf("size %d: expected error for out of range proof", size)
+		}
+	}
+}
+
+func TestMerkleTree_DomainSeparation(t *testing.T) {
+	t.Parallel()
+
+	// Duplicating the last leaf changes the root.
+	a, _ := NewMerkleTree(SHA2_256)
+	b, _ := NewMerkleTree(SHA2_256)
+	for _, leaf := range []string{"a", "b", "c"} {
+		a.Add([]byte(leaf))
+		b.Add([]byte(leaf))
+	}
+	b.Add([]byte("c"))
+	if bytes.Equal(a.Root(), b.Root()) {
+		t.Fatal("duplicated last leaf yields same root")
+	}
+
+	// An internal node cannot be presented as a leaf.
+	c, _ := NewMerkleTree(SHA2_256)
+	c.Add([]byte("a"))
+	c.Add([]byte("b"))
+	d, _ := NewMerkleTree(SHA2_256)
+	d.Add(append(merkleLeafHash(SHA2_256, []byte("a")), merkleLeafHash(SHA2_256, []byte("b"))...))
+	if bytes.Equal(c.Root(), d.Root()) {
+		t.Fatal("internal node accepted as leaf")
+	}
+
+	// Invalid hash.
+	if _, err := NewMerkleTree("invalid"); err == nil {
+		t.Fatal("expected error for invalid hash")
+	}
+}
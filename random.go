@@ -0,0 +1,24 @@
+package crop
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// randReader is the source of randomness for all operations of this package.
+// It is only replaced by the deterministic test mode.
+// Exceptions, which are not reproducible in the deterministic test mode:
+//   - ML-DSA-65 signing draws its hedging randomness from crypto/rand, as circl
+//     does not expose a way to supply it.
+//   - ML-KEM-768 encapsulation by the responder draws from crypto/rand, as
+//     crypto/mlkem does not expose a derandomized encapsulation.
+var randReader io.Reader = rand.Reader
+
+// readRandom fills b with random data.
+func readRandom(b []byte) {
+	// Note: crypto/rand never returns an error and the deterministic test mode
+	// cannot fail either. If things are so bad that it does, it is okay to panic.
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		panic(err)
+	}
+}
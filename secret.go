@@ -1,19 +1,94 @@
 package crop
 
-import "crypto/rand"
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+)
 
 const minSecretLength = 32 // 256 bits
 
 // NewSecret returns a new random secret with the given length (minimum 32 bytes).
 func NewSecret(length int) []byte {
+	secret, err := NewSecretFrom(randReader, length)
+	if err != nil {
+		// Note: crypto/rand never returns an error and the deterministic test mode
+		// cannot fail either. If things are so bad that it does, it is okay to panic.
+		panic(err)
+	}
+	return secret
+}
+
+// NewSecretFrom returns a new secret with the given length (minimum 32 bytes),
+// read from the given reader.
+func NewSecretFrom(r io.Reader, length int) ([]byte, error) {
 	// Enforce minimum of 32 bytes.
 	if length < minSecretLength {
 		length = minSecretLength
 	}
 
-	// Read random data into secret.
+	// Read data into secret.
 	secret := make([]byte, length)
-	//nolint:errcheck,gosec // crypto/rand.Read cannot fail
-	rand.Read(secret)
-	return secret
+	if _, err := io.ReadFull(r, secret); err != nil {
+		return nil, fmt.Errorf("read secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Secret holds secret material, such as a key or challenge.
+// Burn it as soon as it is no longer needed.
+type Secret []byte
+
+// NewSecretValue returns a new random Secret with the given length
+// (minimum 32 bytes).
+func NewSecretValue(length int) Secret {
+	return Secret(NewSecret(length))
+}
+
+// Bytes returns the secret material. It shares memory with the Secret.
+func (s Secret) Bytes() []byte {
+	return s
+}
+
+// Equal returns whether both secrets are equal, in constant time.
+// Empty or burned secrets are never equal.
+func (s Secret) Equal(other Secret) bool {
+	if len(s) == 0 || len(other) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(s, other) == 1
+}
+
+// Burn zeroizes the secret material and empties the Secret.
+func (s *Secret) Burn() {
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	clear(*s)
+	*s = nil
+}
+
+// SplitSecret splits the material into non-overlapping parts of the given
+// lengths. Each part is copied into its own memory, so that burning one part
+// does not affect the others. Remaining material is ignored.
+// Burn the material afterwards, if it is no longer needed.
+func SplitSecret(material []byte, lengths ...int) ([][]byte, error) {
+	// Check lengths.
+	var total int
+	for _, length := range lengths {
+		if length <= 0 {
+			return nil, fmt.Errorf("invalid part length %d", length)
+		}
+		total += length
+	}
+	if total > len(material) {
+		return nil, fmt.Errorf("material too short: need %d bytes, got %d", total, len(material))
+	}
+
+	// Copy parts.
+	parts := make([][]byte, 0, len(lengths))
+	for _, length := range lengths {
+		parts = append(parts, bytes.Clone(material[:length]))
+		material = material[length:]
+	}
+	return parts, nil
 }
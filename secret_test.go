@@ -0,0 +1,103 @@
+package crop
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestNewSecretFrom(t *testing.T) {
+	t.Parallel()
+
+	// Deterministic reader.
+	source := bytes.Repeat([]byte{0x42}, 64)
+	secret, err := NewSecretFrom(bytes.NewReader(source), 48)
+	if err != nil {
+		t.Fatalf("NewSecretFrom error: %v", err)
+	}
+	if !bytes.Equal(secret, source[:48]) {
+		t.Fatalf("secret not read from reader")
+	}
+
+	// Minimum length is enforced.
+	secret, err = NewSecretFrom(bytes.NewReader(source), 8)
+	if err != nil {
+		t.Fatalf("NewSecretFrom error: %v", err)
+	}
+	if len(secret) != minSecretLength {
+		t.Fatalf("secret length = %d, want %d", len(secret), minSecretLength)
+	}
+
+	// Errors are returned instead of panicking.
+	_, err = NewSecretFrom(bytes.NewReader(source[:16]), 32)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got: %v", err)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	t.Parallel()
+
+	a := NewSecretValue(32)
+	b := Secret(bytes.Clone(a.Bytes()))
+	if len(a.Bytes()) != 32 {
+		t.Fatalf("secret length = %d, want 32", len(a.Bytes()))
+	}
+	if !a.Equal(b) {
+		t.Fatal("expected equal secrets")
+	}
+	if a.Equal(NewSecretValue(32)) {
+		t.Fatal("expected different secrets to not be equal")
+	}
+
+	// Burning zeroizes the material.
+	raw := b.Bytes()
+	b.Burn()
+	if b.Bytes() != nil {
+		t.Fatal("expected burned secret to be empty")
+	}
+	if !bytes.Equal(raw, make([]byte, 32)) {
+		t.Fatal("expected burned secret material to be zeroed")
+	}
+	if a.Equal(b) || b.Equal(b) {
+		t.Fatal("expected burned secret to never be equal")
+	}
+}
+
+func TestSplitSecret(t *testing.T) {
+	t.Parallel()
+
+	material := NewSecret(64)
+	parts, err := SplitSecret(material, 16, 32, 8)
+	if err != nil {
+		t.Fatalf("SplitSecret error: %v", err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3", len(parts))
+	}
+	if !bytes.Equal(bytes.Join(parts, nil), material[:56]) {
+		t.Fatal("parts do not match material")
+	}
+
+	// Parts do not share memory.
+	clear(parts[0])
+	if !bytes.Equal(parts[1], material[16:48]) || bytes.Equal(material[:16], parts[0]) {
+		t.Fatal("burning one part affected other memory")
+	}
+	clear(material)
+	if bytes.Equal(parts[1], make([]byte, 32)) {
+		t.Fatal("burning material affected parts")
+	}
+
+	// Invalid requests.
+	if _, err := SplitSecret(make([]byte, 32), 16, 17); err == nil {
+		t.Fatal("expected error for too short material")
+	}
+	if _, err := SplitSecret(make([]byte, 32), 16, 0); err == nil {
+		t.Fatal("expected error for zero length")
+	}
+	if _, err := SplitSecret(make([]byte, 32), 40, -8); err == nil {
+		t.Fatal("expected error for negative length")
+	}
+}
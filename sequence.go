@@ -1,8 +1,12 @@
 package crop
 
 import (
+	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
 // SequenceChecker checks sequence numbers and mitigates replay attacks.
@@ -13,6 +17,76 @@ type SequenceChecker interface {
 	// CheckInSequence checks the sequence number of an incoming message.
 	// It returns whether the sequence number is okay and the message may be accepted.
 	CheckInSequence(n uint64) (ok bool)
+
+	// Reset resets the checker to the state of a new checker, for example
+	// after rekeying. Configuration is kept.
+	Reset()
+}
+
+// SequenceStats holds statistics of checked incoming sequence numbers.
+type SequenceStats struct {
+	// Accepted is the number of accepted sequence numbers.
+	Accepted uint64
+	// Duplicate is the number of sequence numbers rejected as already received.
+	Duplicate uint64
+	// TooOld is the number of sequence numbers rejected as too far behind.
+	TooOld uint64
+	// TooFarAhead is the number of sequence numbers rejected by the horizon.
+	TooFarAhead uint64
+}
+
+// Rejected returns the total number of rejected sequence numbers.
+func (stats SequenceStats) Rejected() uint64 {
+	return stats.Duplicate + stats.TooOld + stats.TooFarAhead
+}
+
+type seqResult uint8
+
+const (
+	seqAccepted seqResult = iota
+	seqDuplicate
+	seqTooOld
+	seqTooFarAhead
+)
+
+func (stats *SequenceStats) record(result seqResult) {
+	switch result {
+	case seqAccepted:
+		stats.Accepted++
+	case seqDuplicate:
+		stats.Duplicate++
+	case seqTooOld:
+		stats.TooOld++
+	case seqTooFarAhead:
+		stats.TooFarAhead++
+	}
+}
+
+// NoopSequenceChecker accepts all sequence numbers and does not provide any
+// replay protection. Only use it where replayed or reordered messages are
+// harmless, for example when authenticating data at rest.
+type NoopSequenceChecker struct {
+	outSeq atomic.Uint64
+}
+
+// NewNoopSequenceChecker returns a new NoopSequenceChecker.
+func NewNoopSequenceChecker() *NoopSequenceChecker {
+	return &NoopSequenceChecker{}
+}
+
+// NextOutSequence returns the next sequence number for an outgoing message.
+func (nsc *NoopSequenceChecker) NextOutSequence() uint64 {
+	return nsc.outSeq.Add(1)
+}
+
+// CheckInSequence always accepts the sequence number.
+func (nsc *NoopSequenceChecker) CheckInSequence(n uint64) (ok bool) {
+	return true
+}
+
+// Reset resets the outgoing sequence number.
+func (nsc *NoopSequenceChecker) Reset() {
+	nsc.outSeq.Store(0)
 }
 
 // StrictSequenceChecker only allows sequence numbers higher than the highest
@@ -20,8 +94,10 @@ type SequenceChecker interface {
 // Note: Using this on message without guaranteed delivery order will result in lost messages.
 // Note: Does not roll over and will stop accepting sequence numbers after 2⁶⁴ messages.
 type StrictSequenceChecker struct {
-	inLock sync.Mutex
-	inSeq  uint64
+	inLock    sync.Mutex
+	inSeq     uint64
+	inHorizon uint64
+	stats     SequenceStats
 
 	outSeq atomic.Uint64
 }
@@ -31,6 +107,16 @@ func NewStrictSequenceChecker() *StrictSequenceChecker {
 	return &StrictSequenceChecker{}
 }
 
+// SetHorizon sets the maximum accepted jump from the highest received sequence
+// number. Sequence numbers further ahead are rejected as implausible.
+// A horizon of 0 disables the check.
+func (ssc *StrictSequenceChecker) SetHorizon(horizon uint64) {
+	ssc.inLock.Lock()
+	defer ssc.inLock.Unlock()
+
+	ssc.inHorizon = horizon
+}
+
 // NextOutSequence returns the next sequence number for an outgoing message.
 func (ssc *StrictSequenceChecker) NextOutSequence() uint64 {
 	return ssc.outSeq.Add(1)
@@ -42,35 +128,148 @@ func (ssc *StrictSequenceChecker) CheckInSequence(n uint64) (ok bool) {
 	ssc.inLock.Lock()
 	defer ssc.inLock.Unlock()
 
+	result := ssc.check(n)
+	ssc.stats.record(result)
+	return result == seqAccepted
+}
+
+func (ssc *StrictSequenceChecker) check(n uint64) seqResult {
 	// Check if sequence is equal or smaller than the current sequence.
-	if n <= ssc.inSeq {
-		return false
+	switch {
+	case n == ssc.inSeq:
+		return seqDuplicate
+	case n < ssc.inSeq:
+		return seqTooOld
+	}
+
+	// Check if sequence is implausibly far ahead.
+	if ssc.inHorizon > 0 && n-ssc.inSeq > ssc.inHorizon {
+		return seqTooFarAhead
 	}
 
 	// Save new sequence number.
 	ssc.inSeq = n
-	return true
+	return seqAccepted
+}
+
+// Reset resets the checker to the state of a new checker, for example after
+// rekeying. The horizon is configuration and is kept.
+func (ssc *StrictSequenceChecker) Reset() {
+	ssc.inLock.Lock()
+	defer ssc.inLock.Unlock()
+
+	ssc.inSeq = 0
+	ssc.stats = SequenceStats{}
+	ssc.outSeq.Store(0)
+}
+
+// Highest returns the highest accepted sequence number.
+func (ssc *StrictSequenceChecker) Highest() uint64 {
+	ssc.inLock.Lock()
+	defer ssc.inLock.Unlock()
+
+	return ssc.inSeq
+}
+
+// Stats returns the statistics of checked incoming sequence numbers.
+func (ssc *StrictSequenceChecker) Stats() SequenceStats {
+	ssc.inLock.Lock()
+	defer ssc.inLock.Unlock()
+
+	return ssc.stats
+}
+
+// MarshalBinary returns the checker state, so that it can be restored after a restart.
+func (ssc *StrictSequenceChecker) MarshalBinary() ([]byte, error) {
+	ssc.inLock.Lock()
+	defer ssc.inLock.Unlock()
+
+	return cbor.Marshal(&sequenceState{
+		InHighest: ssc.inSeq,
+		OutSeq:    ssc.outSeq.Load(),
+	})
+}
+
+// UnmarshalBinary restores the checker state from MarshalBinary.
+// The horizon is configuration and is not part of the state.
+func (ssc *StrictSequenceChecker) UnmarshalBinary(data []byte) error {
+	state := &sequenceState{}
+	if err := cbor.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	ssc.inLock.Lock()
+	defer ssc.inLock.Unlock()
+
+	ssc.inSeq = state.InHighest
+	ssc.outSeq.Store(state.OutSeq)
+	return nil
 }
 
-// LooseSequenceChecker allows some reordering of sequence numbers, up to 64 messages.
+// LooseSequenceChecker allows some reordering of sequence numbers, up to 64
+// messages by default or the configured window size.
+// A window of n tracks the n sequence numbers directly below the highest
+// received one: a late sequence number is accepted once if the difference to
+// the highest is at most n. With the default window, a difference of 64 is
+// accepted and a difference of 65 is rejected.
 // Note: Does not roll over and will stop accepting sequence numbers after 2⁶⁴ messages.
 type LooseSequenceChecker struct {
 	inLock    sync.Mutex
-	inBitMap  uint64
+	inBitMap  []uint64
+	inWindow  uint64
 	inHighest uint64
+	inHorizon uint64
+	stats     SequenceStats
 
 	outSeq atomic.Uint64
 }
 
-const fullBitMap = 0xFFFF_FFFF_FFFF_FFFF
+const (
+	fullBitMap = 0xFFFF_FFFF_FFFF_FFFF
 
-// NewLooseSequenceChecker returns a new LooseSequenceChecker.
+	defaultSequenceWindow = 64
+	maxSequenceWindow     = 1 << 16
+)
+
+// NewLooseSequenceChecker returns a new LooseSequenceChecker with a window of 64 messages.
 func NewLooseSequenceChecker() *LooseSequenceChecker {
+	return NewLooseSequenceCheckerWithWindow(defaultSequenceWindow)
+}
+
+// NewLooseSequenceCheckerWithWindow returns a new LooseSequenceChecker that
+// tolerates reordering of up to the given amount of messages (minimum 64,
+// maximum 65536).
+func NewLooseSequenceCheckerWithWindow(bits int) *LooseSequenceChecker {
+	// Enforce minimum of 64 bits and maximum of 65536 bits.
+	switch {
+	case bits < defaultSequenceWindow:
+		bits = defaultSequenceWindow
+	case bits > maxSequenceWindow:
+		bits = maxSequenceWindow
+	}
+
+	// Start with full bit map.
+	bitMap := make([]uint64, (bits+63)/64)
+	for i := range bitMap {
+		bitMap[i] = fullBitMap
+	}
+
 	return &LooseSequenceChecker{
-		inBitMap: fullBitMap, // Start with full bit map.
+		inBitMap: bitMap,
+		inWindow: uint64(bits),
 	}
 }
 
+// SetHorizon sets the maximum accepted jump from the highest received sequence
+// number. Sequence numbers further ahead are rejected as implausible.
+// A horizon of 0 disables the check.
+func (lsc *LooseSequenceChecker) SetHorizon(horizon uint64) {
+	lsc.inLock.Lock()
+	defer lsc.inLock.Unlock()
+
+	lsc.inHorizon = horizon
+}
+
 // NextOutSequence returns the next sequence number for an outgoing message.
 func (lsc *LooseSequenceChecker) NextOutSequence() uint64 {
 	return lsc.outSeq.Add(1)
@@ -82,21 +281,36 @@ func (lsc *LooseSequenceChecker) CheckInSequence(seqNum uint64) (ok bool) {
 	lsc.inLock.Lock()
 	defer lsc.inLock.Unlock()
 
+	result := lsc.check(seqNum)
+	lsc.stats.record(result)
+	return result == seqAccepted
+}
+
+func (lsc *LooseSequenceChecker) check(seqNum uint64) seqResult {
 	switch {
 	case seqNum == lsc.inHighest:
 		// This is the same as the highest sequence number we already received.
 		// Must be a duplicate.
-		return false
+		return seqDuplicate
 
 	case seqNum > lsc.inHighest:
 		// The received sequence number is higher than the previous highest sequence number.
 		// Update view bitmap and highest sequence number.
 		diff := seqNum - lsc.inHighest
+		// Reject if implausibly far ahead.
+		if lsc.inHorizon > 0 && diff > lsc.inHorizon {
+			return seqTooFarAhead
+		}
 		// Shift bitmap by diff
-		lsc.inBitMap <<= diff
+		shiftBitMap(lsc.inBitMap, diff)
+		// Mark previous highest as received, as it moves into the bitmap.
+		// The initial highest of 0 is never accepted as the highest.
+		if lsc.inHighest != 0 {
+			markBitMap(lsc.inBitMap, lsc.inWindow, diff)
+		}
 		// Update highest value
 		lsc.inHighest = seqNum
-		return true
+		return seqAccepted
 
 	case seqNum < lsc.inHighest:
 		// The received sequence number is lower the previous highest sequence number.
@@ -104,21 +318,139 @@ func (lsc *LooseSequenceChecker) CheckInSequence(seqNum uint64) (ok bool) {
 		// Check the view bitmap.
 		diff := lsc.inHighest - seqNum
 		// Return if the position would be out of view of the bitmap.
-		if diff > 64 {
-			return false
+		if diff > lsc.inWindow {
+			return seqTooOld
 		}
-		// Calculate position in view bitmap.
-		var bitMapPosition uint64 = 1 << (diff - 1)
-		// Check if received flag is set in view bitmap.
-		if lsc.inBitMap&bitMapPosition > 0 {
-			// Received flag is set, this must be a duplicate.
-			return false
+		// Set the received flag, if it was set before, this must be a duplicate.
+		if testAndMarkBitMap(lsc.inBitMap, diff) {
+			return seqDuplicate
 		}
-		// Otherwise, set the received flag.
-		lsc.inBitMap |= bitMapPosition
-		return true
+		return seqAccepted
 	}
 
 	// In case something goes wrong, don't accept the message.
-	return false
+	return seqDuplicate
+}
+
+// Reset resets the checker to the state of a new checker, for example after
+// rekeying. The window size and horizon are configuration and are kept.
+func (lsc *LooseSequenceChecker) Reset() {
+	lsc.inLock.Lock()
+	defer lsc.inLock.Unlock()
+
+	for i := range lsc.inBitMap {
+		lsc.inBitMap[i] = fullBitMap
+	}
+	lsc.inHighest = 0
+	lsc.stats = SequenceStats{}
+	lsc.outSeq.Store(0)
+}
+
+// Highest returns the highest accepted sequence number.
+func (lsc *LooseSequenceChecker) Highest() uint64 {
+	lsc.inLock.Lock()
+	defer lsc.inLock.Unlock()
+
+	return lsc.inHighest
+}
+
+// Window returns a copy of the received flags bitmap below the highest
+// sequence number. Bit 0 of the first word represents the sequence number
+// directly below the highest.
+func (lsc *LooseSequenceChecker) Window() []uint64 {
+	lsc.inLock.Lock()
+	defer lsc.inLock.Unlock()
+
+	return slices.Clone(lsc.inBitMap)
+}
+
+// Stats returns the statistics of checked incoming sequence numbers.
+func (lsc *LooseSequenceChecker) Stats() SequenceStats {
+	lsc.inLock.Lock()
+	defer lsc.inLock.Unlock()
+
+	return lsc.stats
+}
+
+// MarshalBinary returns the checker state, so that it can be restored after a restart.
+func (lsc *LooseSequenceChecker) MarshalBinary() ([]byte, error) {
+	lsc.inLock.Lock()
+	defer lsc.inLock.Unlock()
+
+	return cbor.Marshal(&sequenceState{
+		InHighest: lsc.inHighest,
+		BitMap:    lsc.inBitMap,
+		Window:    lsc.inWindow,
+		OutSeq:    lsc.outSeq.Load(),
+	})
+}
+
+// UnmarshalBinary restores the checker state, including the window size, from
+// MarshalBinary. The horizon is configuration and is not part of the state.
+func (lsc *LooseSequenceChecker) UnmarshalBinary(data []byte) error {
+	state := &sequenceState{}
+	if err := cbor.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	switch {
+	case state.Window < defaultSequenceWindow || state.Window > maxSequenceWindow:
+		return fmt.Errorf("%w: window size %d out of range", ErrInvalidFormat, state.Window)
+	case uint64(len(state.BitMap))*64 < state.Window || len(state.BitMap) > maxSequenceWindow/64:
+		return fmt.Errorf("%w: window size does not match bitmap", ErrInvalidFormat)
+	}
+
+	lsc.inLock.Lock()
+	defer lsc.inLock.Unlock()
+
+	lsc.inHighest = state.InHighest
+	lsc.inBitMap = state.BitMap
+	lsc.inWindow = state.Window
+	lsc.outSeq.Store(state.OutSeq)
+	return nil
+}
+
+// sequenceState is the serialized state of a sequence checker.
+type sequenceState struct {
+	InHighest uint64   `cbor:"h"`
+	BitMap    []uint64 `cbor:"b,omitempty"`
+	Window    uint64   `cbor:"w,omitempty"`
+	OutSeq    uint64   `cbor:"o"`
+}
+
+// markBitMap sets the received flag of the sequence number that is diff below
+// the highest, if it is within the window.
+func markBitMap(bitMap []uint64, window, diff uint64) {
+	if diff == 0 || diff > window {
+		return
+	}
+	bitMap[(diff-1)/64] |= 1 << ((diff - 1) % 64)
+}
+
+// testAndMarkBitMap sets the received flag of the sequence number that is diff
+// below the highest and returns whether it was already set.
+// The caller must ensure that diff is within the window.
+func testAndMarkBitMap(bitMap []uint64, diff uint64) (wasSet bool) {
+	word := (diff - 1) / 64
+	var bitMapPosition uint64 = 1 << ((diff - 1) % 64)
+	wasSet = bitMap[word]&bitMapPosition > 0
+	bitMap[word] |= bitMapPosition
+	return wasSet
+}
+
+// shiftBitMap shifts the multi-word bitmap towards higher positions by n bits.
+// The first word holds the lowest positions.
+func shiftBitMap(bitMap []uint64, n uint64) {
+	wordShift := n / 64
+	bitShift := n % 64
+	for i := len(bitMap) - 1; i >= 0; i-- {
+		var v uint64
+		if uint64(i) >= wordShift {
+			src := i - int(wordShift)
+			v = bitMap[src] << bitShift
+			if bitShift > 0 && src > 0 {
+				v |= bitMap[src-1] >> (64 - bitShift)
+			}
+		}
+		bitMap[i] = v
+	}
 }
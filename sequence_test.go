@@ -3,10 +3,14 @@ package crop
 // Note: LLM-Generated.
 
 import (
+	"errors"
+	"math"
 	"runtime"
 	"sort"
 	"sync"
 	"testing"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
 func TestStrictSequenceChecker_CheckInSequence_Basic(t *testing.T) {
@@ -161,6 +165,41 @@ func TestLooseSequenceChecker_AcceptsLateWithinWindowAndRejectsBeyond(t *testing
 	}
 }
 
+func TestLooseSequenceChecker_WindowBoundaries(t *testing.T) {
+	t.Parallel()
+
+	// Late sequence numbers at diff 63 and 64 are in the window, 65 is not.
+	for diff, want := range map[uint64]bool{63: true, 64: true, 65: false} {
+		lsc := NewLooseSequenceChecker()
+		if ok := lsc.CheckInSequence(100); !ok {
+			t.Fatalf("expected seq=100 to be accepted")
+		}
+		if ok := lsc.CheckInSequence(100 - diff); ok != want {
+			t.Errorf("late diff=%d: accepted=%v, want %v", diff, ok, want)
+		}
+	}
+
+	// The previous highest cannot be replayed after the highest advanced.
+	newCheckers := map[string]func() SequenceChecker{
+		"loose":    func() SequenceChecker { return NewLooseSequenceChecker() },
+		"wrapping": func() SequenceChecker { return NewWrappingSequenceChecker(64) },
+	}
+	for name, newChecker := range newCheckers {
+		for _, advance := range []uint64{1, 63, 64, 65} {
+			checker := newChecker()
+			if ok := checker.CheckInSequence(1000); !ok {
+				t.Fatalf("%s: expected seq=1000 to be accepted", name)
+			}
+			if ok := checker.CheckInSequence(1000 + advance); !ok {
+				t.Fatalf("%s: expected seq=%d to be accepted", name, 1000+advance)
+			}
+			if ok := checker.CheckInSequence(1000); ok {
+				t.Errorf("%s: advance=%d: expected previous highest to be rejected", name, advance)
+			}
+		}
+	}
+}
+
 func TestLooseSequenceChecker_OutOfOrderSeriesWithinWindow(t *testing.T) {
 	t.Parallel()
 
@@ -249,3 +288,366 @@ func TestLooseSequenceChecker_NextOutSequence_SequentialAndConcurrent(t *testing
 		}
 	}
 }
+
+func TestSequenceChecker_Horizon(t *testing.T) {
+	t.Parallel()
+
+	checkers := map[string]interface {
+		SequenceChecker
+		SetHorizon(horizon uint64)
+	}{
+		"strict": NewStrictSequenceChecker(),
+		"loose":  NewLooseSequenceChecker(),
+	}
+
+	for name, sc := range checkers {
+		sc.SetHorizon(1000)
+
+		// Normal sequence.
+		for n := uint64(1); n <= 3; n++ {
+			if !sc.CheckInSequence(n) {
+				t.Fatalf("%s: expected seq=%d to be accepted", name, n)
+			}
+		}
+		// Modest jump within horizon.
+		if !sc.CheckInSequence(503) {
+			t.Fatalf("%s: expected modest jump to be accepted", name)
+		}
+		// Implausible jump beyond horizon.
+		if sc.CheckInSequence(503 + 1001) {
+			t.Fatalf("%s: expected implausible jump to be rejected", name)
+		}
+		if sc.CheckInSequence(1 << 62) {
+			t.Fatalf("%s: expected huge jump to be rejected", name)
+		}
+		// Rejected jumps do not advance state.
+		if !sc.CheckInSequence(504) {
+			t.Fatalf("%s: expected next seq to be accepted after rejected jump", name)
+		}
+		// Jump exactly at horizon is accepted.
+		if !sc.CheckInSequence(504 + 1000) {
+			t.Fatalf("%s: expected jump at horizon to be accepted", name)
+		}
+	}
+}
+
+func TestLooseSequenceChecker_WithWindow(t *testing.T) {
+	t.Parallel()
+
+	for _, window := range []int{64, 100, 128, 1000} {
+		lsc := NewLooseSequenceCheckerWithWindow(window)
+		highest := uint64(5000)
+
+		if ok := lsc.CheckInSequence(highest); !ok {
+			t.Fatalf("window %d: expected seq=%d to be accepted", window, highest)
+		}
+
+		// Exactly at the window edge is accepted once.
+		edge := highest - uint64(window)
+		if ok := lsc.CheckInSequence(edge); !ok {
+			t.Fatalf("window %d: expected seq=%d at window edge to be accepted", window, edge)
+		}
+		if ok := lsc.CheckInSequence(edge); ok {
+			t.Fatalf("window %d: expected duplicate seq=%d to be rejected", window, edge)
+		}
+		// Just beyond the window edge is rejected.
+		if ok := lsc.CheckInSequence(edge - 1); ok {
+			t.Fatalf("window %d: expected seq=%d beyond window to be rejected", window, edge-1)
+		}
+	}
+}
+
+func TestLooseSequenceChecker_WindowShiftAcrossWords(t *testing.T) {
+	t.Parallel()
+
+	lsc := NewLooseSequenceCheckerWithWindow(256)
+
+	// Receive every other late message, then move forward by less than a word.
+	if ok := lsc.CheckInSequence(200); !ok {
+		t.Fatalf("expected seq=200 to be accepted")
+	}
+	for n := uint64(1); n < 200; n += 2 {
+		if ok := lsc.CheckInSequence(n); !ok {
+			t.Fatalf("expected seq=%d to be accepted", n)
+		}
+	}
+	if ok := lsc.CheckInSequence(230); !ok {
+		t.Fatalf("expected seq=230 to be accepted")
+	}
+
+	// Late messages that were received before are still rejected after the
+	// shift, while missing ones are accepted.
+	for n := uint64(3); n < 199; n += 2 {
+		if ok := lsc.CheckInSequence(n); ok {
+			t.Fatalf("expected duplicate seq=%d to be rejected", n)
+		}
+		if ok := lsc.CheckInSequence(n + 1); !ok {
+			t.Fatalf("expected missing seq=%d to be accepted", n+1)
+		}
+	}
+}
+
+func TestWrappingSequenceChecker_Rollover(t *testing.T) {
+	t.Parallel()
+
+	wsc := NewWrappingSequenceChecker(64)
+
+	// Advance close to the maximum in steps of less than half the space.
+	for _, n := range []uint64{1 << 62, 2 << 62, 3 << 62} {
+		if ok := wsc.CheckInSequence(n); !ok {
+			t.Fatalf("expected seq=%d to be accepted", n)
+		}
+	}
+
+	near := uint64(math.MaxUint64 - 2)
+	if ok := wsc.CheckInSequence(near); !ok {
+		t.Fatalf("expected seq=%d to be accepted", near)
+	}
+	if ok := wsc.CheckInSequence(math.MaxUint64); !ok {
+		t.Fatalf("expected seq=MaxUint64 to be accepted")
+	}
+
+	// Small wrapped values are newer.
+	if ok := wsc.CheckInSequence(0); !ok {
+		t.Fatalf("expected wrapped seq=0 to be accepted")
+	}
+	if ok := wsc.CheckInSequence(3); !ok {
+		t.Fatalf("expected wrapped seq=3 to be accepted")
+	}
+
+	// Late messages from before the wrap are accepted once.
+	if ok := wsc.CheckInSequence(near + 1); !ok {
+		t.Fatalf("expected late seq=%d to be accepted", near+1)
+	}
+	if ok := wsc.CheckInSequence(near + 1); ok {
+		t.Fatalf("expected duplicate seq=%d to be rejected", near+1)
+	}
+	if ok := wsc.CheckInSequence(1); !ok {
+		t.Fatalf("expected late seq=1 to be accepted")
+	}
+	if ok := wsc.CheckInSequence(3); ok {
+		t.Fatalf("expected duplicate highest seq=3 to be rejected")
+	}
+
+	// Beyond window is rejected.
+	beyond := uint64(3)
+	beyond -= 65
+	if ok := wsc.CheckInSequence(beyond); ok {
+		t.Fatalf("expected seq beyond window to be rejected")
+	}
+}
+
+func TestWrappingSequenceChecker_AcceptsAnyFirstSequence(t *testing.T) {
+	t.Parallel()
+
+	for _, first := range []uint64{0, 1, serialHalf, serialHalf + 1, math.MaxUint64} {
+		wsc := NewWrappingSequenceChecker(64)
+		if ok := wsc.CheckInSequence(first); !ok {
+			t.Fatalf("expected first seq=%d to be accepted", first)
+		}
+		if ok := wsc.CheckInSequence(first); ok {
+			t.Fatalf("expected duplicate first seq=%d to be rejected", first)
+		}
+		if ok := wsc.CheckInSequence(first + 1); !ok {
+			t.Fatalf("expected seq=%d after first to be accepted", first+1)
+		}
+		if ok := wsc.CheckInSequence(first - 1); !ok {
+			t.Fatalf("expected late seq=%d before first to be accepted", first-1)
+		}
+		if ok := wsc.CheckInSequence(first - 1); ok {
+			t.Fatalf("expected duplicate seq=%d before first to be rejected", first-1)
+		}
+	}
+
+	// Reordering at the start of a session is tolerated, also after a reset.
+	wsc := NewWrappingSequenceChecker(64)
+	for range 2 {
+		for _, n := range []uint64{3, 1, 2} {
+			if ok := wsc.CheckInSequence(n); !ok {
+				t.Fatalf("expected reordered seq=%d to be accepted", n)
+			}
+		}
+		wsc.Reset()
+	}
+}
+
+func TestWrappingSequenceChecker_NextOutSequenceWraps(t *testing.T) {
+	t.Parallel()
+
+	wsc := NewWrappingSequenceChecker(64)
+	wsc.outSeq.Store(math.MaxUint64 - 1)
+	if n := wsc.NextOutSequence(); n != math.MaxUint64 {
+		t.Fatalf("NextOutSequence = %d, want MaxUint64", n)
+	}
+	if n := wsc.NextOutSequence(); n != 0 {
+		t.Fatalf("NextOutSequence = %d, want 0 after wrap", n)
+	}
+}
+
+func TestSequenceChecker_MarshalBinary(t *testing.T) {
+	t.Parallel()
+
+	type persistentChecker interface {
+		SequenceChecker
+		MarshalBinary() ([]byte, error)
+		UnmarshalBinary(data []byte) error
+	}
+
+	for name, mk := range map[string]func() persistentChecker{
+		"strict": func() persistentChecker { return NewStrictSequenceChecker() },
+		"loose":  func() persistentChecker { return NewLooseSequenceChecker() },
+		"loose-wide": func() persistentChecker {
+			return NewLooseSequenceCheckerWithWindow(200)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := mk()
+			for _, n := range []uint64{10, 50, 150, 149, 120, 151} {
+				original.CheckInSequence(n)
+			}
+			original.NextOutSequence()
+			original.NextOutSequence()
+
+			data, err := original.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			var target persistentChecker = NewStrictSequenceChecker()
+			if name != "strict" {
+				target = NewLooseSequenceChecker()
+			}
+			if err := target.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary: %v", err)
+			}
+
+			// Both must make identical decisions from here on.
+			if a, b := original.NextOutSequence(), target.NextOutSequence(); a != b {
+				t.Fatalf("NextOutSequence mismatch: %d != %d", a, b)
+			}
+			for n := uint64(0); n < 200; n++ {
+				if a, b := original.CheckInSequence(n), target.CheckInSequence(n); a != b {
+					t.Fatalf("CheckInSequence(%d) mismatch: original=%v restored=%v", n, a, b)
+				}
+			}
+		})
+	}
+
+	if err := NewLooseSequenceChecker().UnmarshalBinary([]byte("garbage")); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("expected ErrInvalidFormat, got %v", err)
+	}
+}
+
+func TestLooseSequenceChecker_UnmarshalBinary_RejectsBadWindow(t *testing.T) {
+	t.Parallel()
+
+	for name, state := range map[string]sequenceState{
+		"overflowing window": {Window: math.MaxUint64},
+		"oversized window":   {Window: maxSequenceWindow + 64, BitMap: make([]uint64, maxSequenceWindow/64+1)},
+		"short bitmap":       {Window: 128, BitMap: make([]uint64, 1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := cbor.Marshal(&state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lsc := NewLooseSequenceChecker()
+			if err := lsc.UnmarshalBinary(data); !errors.Is(err, ErrInvalidFormat) {
+				t.Fatalf("expected ErrInvalidFormat, got %v", err)
+			}
+			// The checker must still be usable.
+			if !lsc.CheckInSequence(50) {
+				t.Fatal("expected checker to keep working after rejected state")
+			}
+		})
+	}
+}
+
+func TestNoopSequenceChecker(t *testing.T) {
+	t.Parallel()
+
+	nsc := NewNoopSequenceChecker()
+	for _, n := range []uint64{0, 5, 5, 1, math.MaxUint64, 1} {
+		if ok := nsc.CheckInSequence(n); !ok {
+			t.Fatalf("expected seq=%d to be accepted", n)
+		}
+	}
+	if a, b := nsc.NextOutSequence(), nsc.NextOutSequence(); b <= a {
+		t.Fatalf("NextOutSequence not increasing: %d, %d", a, b)
+	}
+
+	// Replayed MACs are accepted.
+	key := NewSecret(32)
+	handler, err := NewAuthCodeHandler(MsgAuthCodeTypeBlake3, key, key, NewNoopSequenceChecker())
+	if err != nil {
+		t.Fatalf("create handler: %v", err)
+	}
+	mac := handler.Sign("blob", []byte("data"))
+	for range 3 {
+		if err := handler.Verify("blob", []byte("data"), mac); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+	}
+}
+
+func TestSequenceChecker_Diagnostics(t *testing.T) {
+	t.Parallel()
+
+	lsc := NewLooseSequenceChecker()
+	lsc.SetHorizon(1000)
+	for _, n := range []uint64{100, 98, 98, 100, 5000, 1} {
+		lsc.CheckInSequence(n)
+	}
+	if lsc.Highest() != 100 {
+		t.Fatalf("Highest = %d, want 100", lsc.Highest())
+	}
+	// Seq 99 (bit 0) is missing, seq 98 (bit 1) was received.
+	if w := lsc.Window(); w[0]&0b11 != 0b10 {
+		t.Fatalf("Window = %b, want lowest bits 10", w[0])
+	}
+	want := SequenceStats{Accepted: 2, Duplicate: 2, TooOld: 1, TooFarAhead: 1}
+	if stats := lsc.Stats(); stats != want {
+		t.Fatalf("Stats = %+v, want %+v", stats, want)
+	}
+
+	ssc := NewStrictSequenceChecker()
+	for _, n := range []uint64{5, 5, 3, 7} {
+		ssc.CheckInSequence(n)
+	}
+	if ssc.Highest() != 7 {
+		t.Fatalf("Highest = %d, want 7", ssc.Highest())
+	}
+	want = SequenceStats{Accepted: 2, Duplicate: 1, TooOld: 1}
+	if stats := ssc.Stats(); stats != want || stats.Rejected() != 2 {
+		t.Fatalf("Stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestSequenceChecker_Reset(t *testing.T) {
+	t.Parallel()
+
+	for name, mk := range map[string]func() SequenceChecker{
+		"noop":     func() SequenceChecker { return NewNoopSequenceChecker() },
+		"strict":   func() SequenceChecker { return NewStrictSequenceChecker() },
+		"loose":    func() SequenceChecker { return NewLooseSequenceChecker() },
+		"wrapping": func() SequenceChecker { return NewWrappingSequenceChecker(64) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			used := mk()
+			for _, n := range []uint64{10, 50, 49, 30, 100} {
+				used.CheckInSequence(n)
+			}
+			used.NextOutSequence()
+			used.Reset()
+
+			fresh := mk()
+			if a, b := used.NextOutSequence(), fresh.NextOutSequence(); a != b {
+				t.Fatalf("NextOutSequence mismatch: reset=%d fresh=%d", a, b)
+			}
+			for _, n := range []uint64{0, 1, 3, 2, 2, 70, 5, 4, 6, 100, 30} {
+				if a, b := used.CheckInSequence(n), fresh.CheckInSequence(n); a != b {
+					t.Fatalf("CheckInSequence(%d) mismatch: reset=%v fresh=%v", n, a, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package crop
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WrappingSequenceChecker allows some reordering of sequence numbers, like
+// LooseSequenceChecker, but rolls over after 2⁶⁴ messages.
+// Sequence numbers are compared using serial number arithmetic (RFC 1982),
+// so a small wrapped value is accepted as newer than one near the maximum.
+// The first received sequence number is always accepted, regardless of its
+// value, and only later ones are compared to the highest.
+// Note: This assumes that no two in-flight sequence numbers are more than
+// half of the sequence number space (2⁶³) apart.
+type WrappingSequenceChecker struct {
+	inLock    sync.Mutex
+	inBitMap  []uint64
+	inWindow  uint64
+	inHighest uint64
+	inHorizon uint64
+	inStarted bool // Whether the highest was received.
+
+	outSeq atomic.Uint64
+}
+
+// serialHalf is half of the sequence number space.
+const serialHalf = 1 << 63
+
+// NewWrappingSequenceChecker returns a new WrappingSequenceChecker that
+// tolerates reordering of up to the given amount of messages (minimum 64).
+func NewWrappingSequenceChecker(windowBits int) *WrappingSequenceChecker {
+	// Reuse loose checker setup.
+	lsc := NewLooseSequenceCheckerWithWindow(windowBits)
+	return &WrappingSequenceChecker{
+		inBitMap: lsc.inBitMap,
+		inWindow: lsc.inWindow,
+	}
+}
+
+// SetHorizon sets the maximum accepted jump from the highest received sequence
+// number. Sequence numbers further ahead are rejected as implausible.
+// A horizon of 0 disables the check.
+func (wsc *WrappingSequenceChecker) SetHorizon(horizon uint64) {
+	wsc.inLock.Lock()
+	defer wsc.inLock.Unlock()
+
+	wsc.inHorizon = horizon
+}
+
+// NextOutSequence returns the next sequence number for an outgoing message.
+// Wraps around to zero after the maximum value.
+func (wsc *WrappingSequenceChecker) NextOutSequence() uint64 {
+	return wsc.outSeq.Add(1)
+}
+
+// CheckInSequence checks the sequence number of an incoming message.
+// It returns whether the sequence number is okay and the message may be accepted.
+func (wsc *WrappingSequenceChecker) CheckInSequence(seqNum uint64) (ok bool) {
+	wsc.inLock.Lock()
+	defer wsc.inLock.Unlock()
+
+	// Accept the first sequence number, as there is no highest to compare to yet.
+	// Clear the bitmap, so that earlier sequence numbers within the window are
+	// accepted once.
+	if !wsc.inStarted {
+		clear(wsc.inBitMap)
+		wsc.inHighest = seqNum
+		wsc.inStarted = true
+		return true
+	}
+
+	// Calculate distance using modular arithmetic.
+	diff := seqNum - wsc.inHighest
+
+	switch {
+	case diff == 0:
+		// This is the same as the highest sequence number we already received.
+		// Must be a duplicate.
+		return false
+
+	case diff < serialHalf:
+		// The received sequence number is ahead of the previous highest sequence number.
+		// Reject if implausibly far ahead.
+		if wsc.inHorizon > 0 && diff > wsc.inHorizon {
+			return false
+		}
+		// Shift bitmap by diff
+		shiftBitMap(wsc.inBitMap, diff)
+		// Mark previous highest as received, as it moves into the bitmap.
+		markBitMap(wsc.inBitMap, wsc.inWindow, diff)
+		// Update highest value
+		wsc.inHighest = seqNum
+		return true
+
+	default:
+		// The received sequence number is behind the previous highest sequence number.
+		// This means this is either a duplicate or late message.
+		back := wsc.inHighest - seqNum
+		// Return if the position would be out of view of the bitmap.
+		if back > wsc.inWindow {
+			return false
+		}
+		// Set the received flag, if it was set before, this must be a duplicate.
+		return !testAndMarkBitMap(wsc.inBitMap, back)
+	}
+}
+
+// Reset resets the checker to the state of a new checker, for example after
+// rekeying. The window size and horizon are configuration and are kept.
+func (wsc *WrappingSequenceChecker) Reset() {
+	wsc.inLock.Lock()
+	defer wsc.inLock.Unlock()
+
+	for i := range wsc.inBitMap {
+		wsc.inBitMap[i] = fullBitMap
+	}
+	wsc.inHighest = 0
+	wsc.inStarted = false
+	wsc.outSeq.Store(0)
+}
@@ -0,0 +1,229 @@
+package crop
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	sessionMACContext  = "crop session mac"
+	sessionAEADContext = "crop session aead"
+	sessionMACKeySize  = 32
+
+	sessionInitiatorParty = "initiator"
+	sessionResponderParty = "responder"
+)
+
+var errSessionNotEstablished = errors.New("session not established")
+
+// Session ties together the key exchange, key derivation, message
+// authentication and encryption of a suite for a single peer connection.
+// It derives separate keys for each direction and owns the sequence checkers.
+// Incoming messages may be reordered within the window of the
+// LooseSequenceChecker, but are never accepted twice.
+//
+// The initiator sends its exchange message first. The responder establishes
+// the session with it and then sends its own exchange message, which the
+// initiator uses to establish the session.
+type Session struct {
+	suite    Suite
+	exchange KeyExchange
+	exchMsg  []byte
+
+	lock sync.RWMutex
+	mac  MsgAuthCodeHandler
+	aead AEAD
+}
+
+// NewInitiator returns a new session for the initiating side.
+func (s Suite) NewInitiator() (*Session, error) {
+	return s.newSession(false)
+}
+
+// NewResponder returns a new session for the responding side.
+func (s Suite) NewResponder() (*Session, error) {
+	return s.newSession(true)
+}
+
+func (s Suite) newSession(responder bool) (*Session, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		exchange KeyExchange
+		err      error
+	)
+	if responder {
+		exchange, err = s.keyExchange.NewResponder()
+	} else {
+		exchange, err = s.keyExchange.New()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create key exchange: %w", err)
+	}
+
+	return &Session{
+		suite:    s,
+		exchange: exchange,
+	}, nil
+}
+
+// Suite returns the suite of the session.
+func (sess *Session) Suite() Suite {
+	return sess.suite
+}
+
+// IsInitiator returns whether this is the initiating side of the session.
+func (sess *Session) IsInitiator() bool {
+	return sess.exchange.IsInitiator()
+}
+
+// ExchangeMsg returns the key exchange message to send to the peer.
+// For key encapsulation mechanisms, the responder's exchange message is only
+// available after Establish.
+func (sess *Session) ExchangeMsg() ([]byte, error) {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+
+	if sess.exchMsg == nil {
+		exchMsg, err := sess.exchange.ExchangeMsg()
+		if err != nil {
+			return nil, err
+		}
+		sess.exchMsg = exchMsg
+	}
+	return sess.exchMsg, nil
+}
+
+// Establish completes the key exchange with the peer's exchange message and
+// derives the session keys. The key exchange is burned afterwards.
+func (sess *Session) Establish(peerExchMsg []byte) error {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+
+	if sess.mac != nil {
+		return fmt.Errorf("session already established: %w", ErrCannotReuse)
+	}
+
+	// Complete key exchange.
+	keyMaker, err := sess.exchange.MakeKeys(peerExchMsg, sess.suite.keyMaker)
+	if err != nil {
+		return fmt.Errorf("make keys: %w", err)
+	}
+	defer keyMaker.Burn()
+
+	// Keep exchange message for the responder.
+	if sess.exchMsg == nil {
+		sess.exchMsg, err = sess.exchange.ExchangeMsg()
+		if err != nil {
+			return err
+		}
+	}
+	sess.exchange.Burn()
+
+	// Sort parties by direction.
+	localParty, peerParty := sessionInitiatorParty, sessionResponderParty
+	if !sess.exchange.IsInitiator() {
+		localParty, peerParty = peerParty, localParty
+	}
+
+	// Derive MAC keys.
+	macKeys, err := keyMaker.DeriveKeys(sessionMACContext, []KeySpec{
+		{Party: localParty, Length: sessionMACKeySize},
+		{Party: peerParty, Length: sessionMACKeySize},
+	})
+	if err != nil {
+		return fmt.Errorf("derive MAC keys: %w", err)
+	}
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	defer clear(macKeys[0])
+	defer clear(macKeys[1])
+	mac, err := sess.suite.msgAuthCode.New(macKeys[0], macKeys[1], NewLooseSequenceChecker())
+	if err != nil {
+		return fmt.Errorf("create MAC handler: %w", err)
+	}
+
+	// Create AEAD.
+	aead, err := sess.suite.aead.New(keyMaker, sessionAEADContext, localParty, peerParty, NewLooseSequenceChecker())
+	if err != nil {
+		mac.Burn()
+		return fmt.Errorf("create AEAD: %w", err)
+	}
+
+	sess.mac = mac
+	sess.aead = aead
+	return nil
+}
+
+// IsEstablished returns whether the session keys have been derived.
+func (sess *Session) IsEstablished() bool {
+	sess.lock.RLock()
+	defer sess.lock.RUnlock()
+
+	return sess.mac != nil
+}
+
+// Sign returns a MAC for the data, bound to the given context.
+// Returns nil if the session is not established or burned.
+func (sess *Session) Sign(context string, data []byte) (mac []byte) {
+	sess.lock.RLock()
+	defer sess.lock.RUnlock()
+
+	if sess.mac == nil {
+		return nil
+	}
+	return sess.mac.Sign(context, data)
+}
+
+// Verify checks a MAC of the peer for the data and context.
+func (sess *Session) Verify(context string, data []byte, mac []byte) error {
+	sess.lock.RLock()
+	defer sess.lock.RUnlock()
+
+	if sess.mac == nil {
+		return fmt.Errorf("%w: %w", ErrAuthCodeInvalid, errSessionNotEstablished)
+	}
+	return sess.mac.Verify(context, data, mac)
+}
+
+// Seal encrypts the plaintext for the peer and authenticates the additional data.
+// Returns nil if the session is not established or burned.
+func (sess *Session) Seal(plaintext, aad []byte) (ciphertext []byte) {
+	sess.lock.RLock()
+	defer sess.lock.RUnlock()
+
+	if sess.aead == nil {
+		return nil
+	}
+	return sess.aead.Seal(plaintext, aad)
+}
+
+// Open decrypts a ciphertext of the peer and authenticates the additional data.
+func (sess *Session) Open(ciphertext, aad []byte) ([]byte, error) {
+	sess.lock.RLock()
+	defer sess.lock.RUnlock()
+
+	if sess.aead == nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, errSessionNotEstablished)
+	}
+	return sess.aead.Open(ciphertext, aad)
+}
+
+// Burn securely erases all key material of the session.
+// Afterwards, the session cannot be used anymore.
+func (sess *Session) Burn() {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+
+	sess.exchange.Burn()
+	if sess.mac != nil {
+		sess.mac.Burn()
+		sess.mac = nil
+	}
+	if sess.aead != nil {
+		sess.aead.Burn()
+		sess.aead = nil
+	}
+}
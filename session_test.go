@@ -0,0 +1,146 @@
+package crop
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newTestSessionPair(t *testing.T, s Suite) (initiator, responder *Session) {
+	t.Helper()
+
+	initiator, err := s.NewInitiator()
+	if err != nil {
+		t.Fatalf("create initiator: %v", err)
+	}
+	responder, err = s.NewResponder()
+	if err != nil {
+		t.Fatalf("create responder: %v", err)
+	}
+
+	// Initiator sends first, responder answers after establishing.
+	initMsg, err := initiator.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("initiator exchange msg: %v", err)
+	}
+	if err := responder.Establish(initMsg); err != nil {
+		t.Fatalf("responder establish: %v", err)
+	}
+	respMsg, err := responder.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("responder exchange msg: %v", err)
+	}
+	if err := initiator.Establish(respMsg); err != nil {
+		t.Fatalf("initiator establish: %v", err)
+	}
+	return initiator, responder
+}
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	for _, kxt := range AllKeyExchangeTypes() {
+		s, err := NewSuite(WithKeyExchange(kxt))
+		if err != nil {
+			// Not all key exchanges support the default key maker.
+			continue
+		}
+		initiator, responder := newTestSessionPair(t, s)
+		if !initiator.IsInitiator() || responder.IsInitiator() {
+			t.Fatalf("%s: unexpected roles", kxt)
+		}
+
+		// MAC in both directions.
+		mac := initiator.Sign("test", []byte("data"))
+		if err := responder.Verify("test", []byte("data"), mac); err != nil {
+			t.Fatalf("%s: responder verify: %v", kxt, err)
+		}
+		mac = responder.Sign("test", []byte("data"))
+		if err := initiator.Verify("test", []byte("data"), mac); err != nil {
+			t.Fatalf("%s: initiator verify: %v", kxt, err)
+		}
+
+		// Own MACs are not accepted.
+		mac = initiator.Sign("test", []byte("data"))
+		if err := initiator.Verify("test", []byte("data"), mac); !errors.Is(err, ErrAuthCodeInvalid) {
+			t.Fatalf("%s: expected own MAC to fail, got: %v", kxt, err)
+		}
+
+		// Encryption in both directions.
+		ciphertext := initiator.Seal([]byte("secret"), nil)
+		plaintext, err := responder.Open(ciphertext, nil)
+		if err != nil {
+			t.Fatalf("%s: responder open: %v", kxt, err)
+		}
+		if !bytes.Equal(plaintext, []byte("secret")) {
+			t.Fatalf("%s: opened %q", kxt, plaintext)
+		}
+		ciphertext = responder.Seal([]byte("secret"), nil)
+		if _, err := initiator.Open(ciphertext, nil); err != nil {
+			t.Fatalf("%s: initiator open: %v", kxt, err)
+		}
+
+		// Replays are rejected.
+		if _, err := initiator.Open(ciphertext, nil); !errors.Is(err, ErrDecryptionFailed) {
+			t.Fatalf("%s: expected replay to fail, got: %v", kxt, err)
+		}
+	}
+}
+
+func TestSession_NotEstablished(t *testing.T) {
+	t.Parallel()
+
+	sess, err := Default.NewInitiator()
+	if err != nil {
+		t.Fatalf("create initiator: %v", err)
+	}
+	if sess.IsEstablished() {
+		t.Fatal("expected session to not be established")
+	}
+	if sess.Sign("test", []byte("data")) != nil {
+		t.Fatal("expected Sign to return nil")
+	}
+	if err := sess.Verify("test", []byte("data"), []byte("mac")); !errors.Is(err, ErrAuthCodeInvalid) {
+		t.Fatalf("expected ErrAuthCodeInvalid, got: %v", err)
+	}
+	if sess.Seal([]byte("data"), nil) != nil {
+		t.Fatal("expected Seal to return nil")
+	}
+	if _, err := sess.Open([]byte("data"), nil); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("expected ErrDecryptionFailed, got: %v", err)
+	}
+
+	// Invalid suites are rejected.
+	if _, err := (Suite{}).NewInitiator(); !errors.Is(err, ErrInvalidSuite) {
+		t.Fatalf("expected ErrInvalidSuite, got: %v", err)
+	}
+}
+
+func TestSession_Burn(t *testing.T) {
+	t.Parallel()
+
+	initiator, responder := newTestSessionPair(t, Default)
+	if !initiator.IsEstablished() {
+		t.Fatal("expected session to be established")
+	}
+
+	// Sessions cannot be established twice.
+	msg, err := responder.ExchangeMsg()
+	if err != nil {
+		t.Fatalf("exchange msg: %v", err)
+	}
+	if err := initiator.Establish(msg); !errors.Is(err, ErrCannotReuse) {
+		t.Fatalf("expected ErrCannotReuse, got: %v", err)
+	}
+
+	initiator.Burn()
+	if initiator.IsEstablished() {
+		t.Fatal("expected burned session to not be established")
+	}
+	if initiator.Sign("test", []byte("data")) != nil {
+		t.Fatal("expected burned session to not sign")
+	}
+	if err := initiator.Establish(msg); err == nil {
+		t.Fatal("expected burned session to not establish again")
+	}
+}
@@ -1,7 +1,11 @@
 package crop
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"strings"
 
@@ -16,6 +20,47 @@ type StoredKey struct {
 	Key       []byte `cbor:"k,omitzero" json:"k,omitzero"`
 }
 
+// StoredKeyTypeSecret is the default stored key type for symmetric secrets.
+const StoredKeyTypeSecret = "secret"
+
+// ExportSecret returns a StoredKey holding the given symmetric secret, such as
+// the output of a KeyMaker or NewSecret. The type describes the secret and
+// defaults to StoredKeyTypeSecret if empty. Key pair types are not allowed.
+// The stored key does not share memory with the given material.
+func ExportSecret(material []byte, typ string) (*StoredKey, error) {
+	if typ == "" {
+		typ = StoredKeyTypeSecret
+	}
+	if len(material) == 0 {
+		return nil, fmt.Errorf("%w: empty secret", ErrInvalidFormat)
+	}
+	if _, ok := FindStoredKeyType(&StoredKey{Type: typ}, AllKeyPairTypes()); ok {
+		return nil, fmt.Errorf("secret type %q collides with key pair type", typ)
+	}
+
+	return &StoredKey{
+		Type:      typ,
+		IsPrivate: true,
+		Key:       bytes.Clone(material),
+	}, nil
+}
+
+// LoadSecret returns the symmetric secret held by the stored key.
+// The returned secret does not share memory with the stored key.
+func LoadSecret(stored *StoredKey) ([]byte, error) {
+	switch {
+	case !stored.IsPrivate:
+		return nil, fmt.Errorf("%w: secret must be private", ErrInvalidFormat)
+	case len(stored.Key) == 0:
+		return nil, fmt.Errorf("%w: empty secret", ErrInvalidFormat)
+	}
+	if _, ok := FindStoredKeyType(stored, AllKeyPairTypes()); ok {
+		return nil, fmt.Errorf("%w: stored key is a key pair", ErrInvalidFormat)
+	}
+
+	return bytes.Clone(stored.Key), nil
+}
+
 // IsType checks whether the stored key is of the expected type, using case
 // insensitive matching.
 func (sk *StoredKey) IsType(expected string) bool {
@@ -34,28 +79,85 @@ func FindStoredKeyType[T ~string](sk *StoredKey, acceptable []T) (found T, ok bo
 	return zero, false
 }
 
+// Algorithm returns the key pair type of the stored key, using case
+// insensitive matching. Returns an empty type if the stored key does not hold
+// a supported key pair, for example a symmetric secret.
+func (sk *StoredKey) Algorithm() KeyPairType {
+	kpType, _ := FindStoredKeyType(sk, AllKeyPairTypes())
+	return kpType
+}
+
+// Burn zeroizes the key data and resets the stored key.
+// Callers that still hold the original key data slice, for example the input
+// to a loading function, are responsible for wiping their copy.
+func (sk *StoredKey) Burn() {
+	// TODO: Use guaranteed memory wiping as soon as Go supports it.
+	clear(sk.Key)
+	sk.Key = nil
+	sk.Type = ""
+	sk.IsPrivate = false
+}
+
+// textChecksumSize is the size of the truncated checksum in the text format.
+const textChecksumSize = 4
+
 // Text returns the stored key formatted in text format.
+// The last field is a truncated BLAKE3 checksum of the key data, which
+// catches transcription errors.
 func (sk *StoredKey) Text() string {
+	return sk.text(base58.Encode)
+}
+
+// URLText returns the stored key formatted in text format, like Text, but
+// with base64url (without padding) instead of base58. It is better suited for
+// embedding keys in URLs and tokens.
+func (sk *StoredKey) URLText() string {
+	return sk.text(base64.RawURLEncoding.EncodeToString)
+}
+
+func (sk *StoredKey) text(encode func([]byte) string) string {
 	pubPriv := "public"
 	if sk.IsPrivate {
 		pubPriv = "private"
 	}
 
 	return fmt.Sprintf(
-		"%s:%s:%s",
+		"%s:%s:%s:%s",
 		sk.Type,
 		pubPriv,
-		base58.Encode(sk.Key),
+		encode(sk.Key),
+		encode(textChecksum(sk.Key)),
 	)
 }
 
+// textChecksum returns the truncated checksum of the key data.
+func textChecksum(keyData []byte) []byte {
+	return BLAKE3.Digest(keyData)[:textChecksumSize]
+}
+
 // LoadKeyFromText loads a stored key from the text format.
+// Returns ErrChecksumMismatch if the checksum does not match the key data.
+// Text without the checksum field is still accepted for backward
+// compatibility, but support for it will be removed in a future version.
 func LoadKeyFromText(text string) (*StoredKey, error) {
+	return loadKeyFromText(text, base58.Decode, false)
+}
+
+// LoadKeyFromURLText loads a stored key from the text format created by
+// URLText. The checksum is required.
+func LoadKeyFromURLText(text string) (*StoredKey, error) {
+	return loadKeyFromText(text, base64.RawURLEncoding.DecodeString, true)
+}
+
+func loadKeyFromText(text string, decode func(string) ([]byte, error), requireChecksum bool) (*StoredKey, error) {
 	key := &StoredKey{}
 
 	// Split into chunks.
 	chunks := strings.Split(text, ":")
-	if len(chunks) != 3 {
+	switch {
+	case len(chunks) == 4:
+	case len(chunks) == 3 && !requireChecksum:
+	default:
 		return nil, ErrInvalidFormat
 	}
 
@@ -77,22 +179,61 @@ func LoadKeyFromText(text string) (*StoredKey, error) {
 	}
 
 	// Parse key data.
-	keyData, err := base58.Decode(chunks[2])
+	keyData, err := decode(chunks[2])
 	if err != nil {
 		return nil, ErrInvalidFormat
 	}
 	key.Key = keyData
 
+	// Verify checksum, if present.
+	if len(chunks) == 4 {
+		checksum, err := decode(chunks[3])
+		if err != nil {
+			return nil, ErrInvalidFormat
+		}
+		if subtle.ConstantTimeCompare(checksum, textChecksum(keyData)) != 1 {
+			return nil, ErrChecksumMismatch
+		}
+	}
+
 	return key, nil
 }
 
+// Binary format versions.
+const (
+	// storedKeyFormatV1 is a CBOR encoded StoredKey.
+	storedKeyFormatV1 byte = 1
+)
+
 // Bytes returns the stored key formatted in binary format.
+// The first byte is the format version.
 func (sk *StoredKey) Bytes() ([]byte, error) {
-	return cbor.Marshal(sk)
+	data, err := cbor.Marshal(sk)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{storedKeyFormatV1}, data...), nil
 }
 
 // LoadKeyFromBytes loads a stored key from the binary format.
+// Returns ErrInvalidFormat for unknown format versions.
+// Data without a format version is still accepted for backward
+// compatibility, but support for it will be removed in a future version.
 func LoadKeyFromBytes(data []byte) (*StoredKey, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidFormat
+	}
+
+	// Check format version.
+	switch {
+	case data[0] == storedKeyFormatV1:
+		data = data[1:]
+	case data[0]&0xe0 == 0xa0:
+		// Legacy format without version, starts with a CBOR map (major type 5).
+	default:
+		return nil, fmt.Errorf("%w: unknown format version %d", ErrInvalidFormat, data[0])
+	}
+
 	key := &StoredKey{}
 	err := cbor.Unmarshal(data, key)
 	if err != nil {
@@ -104,6 +245,61 @@ func LoadKeyFromBytes(data []byte) (*StoredKey, error) {
 	return key, nil
 }
 
+// LoadKeyFromBytesStrict loads a stored key from the binary format like
+// LoadKeyFromBytes, but additionally checks the key length if the stored key
+// holds a supported key pair type. Other types are passed through unchanged.
+func LoadKeyFromBytesStrict(data []byte) (*StoredKey, error) {
+	key, err := LoadKeyFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if kpType := key.Algorithm(); kpType != "" {
+		if expected := kpType.storedKeySize(key.IsPrivate); len(key.Key) != expected {
+			return nil, invalidKeySizeError(kpType, key, expected)
+		}
+	}
+	return key, nil
+}
+
+// pemBlockType returns the PEM block type for the stored key, eg.
+// "CROP ED25519 PRIVATE KEY".
+func (sk *StoredKey) pemBlockType() string {
+	pubPriv := "PUBLIC"
+	if sk.IsPrivate {
+		pubPriv = "PRIVATE"
+	}
+	return fmt.Sprintf("CROP %s %s KEY", strings.ToUpper(sk.Type), pubPriv)
+}
+
+// PEM returns the stored key in binary format wrapped in a PEM block.
+func (sk *StoredKey) PEM() ([]byte, error) {
+	data, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  sk.pemBlockType(),
+		Bytes: data,
+	}), nil
+}
+
+// LoadKeyFromPEM loads a stored key from a PEM block.
+// The PEM block type must match the contained key.
+func LoadKeyFromPEM(data []byte) (*StoredKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidFormat
+	}
+	key, err := LoadKeyFromBytes(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(block.Type, key.pemBlockType()) {
+		return nil, fmt.Errorf("%w: PEM block type %q does not match key", ErrInvalidFormat, block.Type)
+	}
+	return key, nil
+}
+
 // JSON returns the stored key as json.
 func (sk *StoredKey) JSON() ([]byte, error) {
 	return json.Marshal(sk)
@@ -0,0 +1,115 @@
+package crop
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	encryptedKeyContext  = "stored key encryption"
+	encryptedKeySaltSize = 16
+)
+
+// encryptedStoredKey is the binary format of a password protected stored key.
+type encryptedStoredKey struct {
+	Time    uint32 `cbor:"t"`
+	Memory  uint32 `cbor:"m"`
+	Threads uint8  `cbor:"p"`
+	Salt    []byte `cbor:"s"`
+	Nonce   []byte `cbor:"n"`
+	Data    []byte `cbor:"d"`
+}
+
+// EncryptedBytes returns the stored key in binary format, encrypted with a
+// key derived from the password using Argon2id with the given parameters.
+// The salt and parameters are stored alongside the encrypted key.
+func (sk *StoredKey) EncryptedBytes(password []byte, params Argon2Params) ([]byte, error) {
+	plaintext, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plaintext)
+
+	esk := &encryptedStoredKey{
+		Time:    params.Time,
+		Memory:  params.Memory,
+		Threads: params.Threads,
+		Salt:    make([]byte, encryptedKeySaltSize),
+		Nonce:   make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	readRandom(esk.Salt)
+	readRandom(esk.Nonce)
+
+	aead, err := esk.aead(password)
+	if err != nil {
+		return nil, err
+	}
+	esk.Data = aead.Seal(nil, esk.Nonce, plaintext, nil)
+
+	return cbor.Marshal(esk)
+}
+
+// LoadEncryptedKeyFromBytes loads a stored key from the password protected
+// binary format. Returns ErrDecryptionFailed if the password is wrong or the
+// data was tampered with.
+// The stored Argon2id parameters must not exceed DefaultArgon2Params, use
+// LoadEncryptedKeyFromBytesWithLimit to allow more expensive parameters.
+func LoadEncryptedKeyFromBytes(data, password []byte) (*StoredKey, error) {
+	return LoadEncryptedKeyFromBytesWithLimit(data, password, DefaultArgon2Params)
+}
+
+// LoadEncryptedKeyFromBytesWithLimit is like LoadEncryptedKeyFromBytes, but
+// accepts stored Argon2id parameters up to the given limit.
+// As the parameters are read from untrusted data, this bounds the time and
+// memory spent on key derivation. Returns ErrInvalidFormat if the limit is exceeded.
+func LoadEncryptedKeyFromBytesWithLimit(data, password []byte, limit Argon2Params) (*StoredKey, error) {
+	esk := &encryptedStoredKey{}
+	if err := cbor.Unmarshal(data, esk); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	switch {
+	case len(esk.Nonce) != chacha20poly1305.NonceSizeX:
+		return nil, ErrInvalidFormat
+	case esk.Time > limit.Time, esk.Memory > limit.Memory, esk.Threads > limit.Threads:
+		return nil, fmt.Errorf(
+			"%w: argon2id parameters (t=%d m=%d p=%d) exceed limit (t=%d m=%d p=%d)",
+			ErrInvalidFormat,
+			esk.Time, esk.Memory, esk.Threads,
+			limit.Time, limit.Memory, limit.Threads,
+		)
+	}
+
+	aead, err := esk.aead(password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	plaintext, err := aead.Open(nil, esk.Nonce, esk.Data, nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	defer clear(plaintext)
+
+	return LoadKeyFromBytes(plaintext)
+}
+
+// aead derives the encryption key from the password and returns the AEAD.
+func (esk *encryptedStoredKey) aead(password []byte) (cipher.AEAD, error) {
+	km, err := NewArgon2idKeyMaker(password, esk.Salt, Argon2Params{
+		Time:    esk.Time,
+		Memory:  esk.Memory,
+		Threads: esk.Threads,
+	})
+	if err != nil {
+		return nil, err
+	}
+	key, err := km.DeriveKey(encryptedKeyContext, "", chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	return chacha20poly1305.NewX(key)
+}
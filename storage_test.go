@@ -0,0 +1,507 @@
+package crop
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/pem"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoredKey_PEM(t *testing.T) {
+	t.Parallel()
+
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stored := range []func() (*StoredKey, error){kp.Export, kp.ToPublic().Export} {
+		sk, err := stored()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pemData, err := sk.PEM()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadKeyFromPEM(pemData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, sk, loaded, "PEM round trip must reproduce stored key")
+
+		// Mismatched block type is rejected.
+		block, _ := pem.Decode(pemData)
+		if sk.IsPrivate {
+			block.Type = "CROP ED25519 PUBLIC KEY"
+		} else {
+			block.Type = "CROP ED25519 PRIVATE KEY"
+		}
+		_, err = LoadKeyFromPEM(pem.EncodeToMemory(block))
+		assert.ErrorIs(t, err, ErrInvalidFormat)
+
+		block.Type = "CROP ED448 PUBLIC KEY"
+		_, err = LoadKeyFromPEM(pem.EncodeToMemory(block))
+		assert.ErrorIs(t, err, ErrInvalidFormat)
+	}
+
+	_, err = LoadKeyFromPEM([]byte("not a pem"))
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestStoredKey_EncryptedBytes(t *testing.T) {
+	t.Parallel()
+
+	// Use cheap parameters to keep the test fast.
+	params := Argon2Params{Time: 1, Memory: 8, Threads: 1}
+	password := []byte("correct horse battery staple")
+
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := kp.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := sk.EncryptedBytes(password, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, string(data), string(sk.Key), "key must not be stored in plaintext")
+
+	loaded, err := LoadEncryptedKeyFromBytes(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sk, loaded)
+
+	// Wrong password.
+	_, err = LoadEncryptedKeyFromBytes(data, []byte("wrong password"))
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	// Tampered data.
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 0xFF
+	_, err = LoadEncryptedKeyFromBytes(tampered, password)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	// Garbage.
+	_, err = LoadEncryptedKeyFromBytes([]byte("garbage"), password)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestLoadEncryptedKeyFromBytes_ParamLimit(t *testing.T) {
+	t.Parallel()
+
+	password := []byte("correct horse battery staple")
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := kp.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := sk.EncryptedBytes(password, Argon2Params{Time: 1, Memory: 8, Threads: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Crafted parameters beyond the default limit must be rejected before
+	// running the key derivation.
+	for name, mutate := range map[string]func(esk *encryptedStoredKey){
+		"time":    func(esk *encryptedStoredKey) { esk.Time = math.MaxUint32 },
+		"memory":  func(esk *encryptedStoredKey) { esk.Memory = math.MaxUint32 },
+		"threads": func(esk *encryptedStoredKey) { esk.Threads = math.MaxUint8 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			esk := &encryptedStoredKey{}
+			if err := cbor.Unmarshal(data, esk); err != nil {
+				t.Fatal(err)
+			}
+			mutate(esk)
+			crafted, err := cbor.Marshal(esk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = LoadEncryptedKeyFromBytes(crafted, password)
+			assert.ErrorIs(t, err, ErrInvalidFormat)
+		})
+	}
+
+	// A caller supplied limit below the stored parameters rejects too.
+	_, err = LoadEncryptedKeyFromBytesWithLimit(data, password, Argon2Params{Time: 1, Memory: 4, Threads: 1})
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+
+	// A limit equal to the stored parameters accepts.
+	loaded, err := LoadEncryptedKeyFromBytesWithLimit(data, password, Argon2Params{Time: 1, Memory: 8, Threads: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sk, loaded)
+}
+
+func TestStoredKey_TextChecksum(t *testing.T) {
+	t.Parallel()
+
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := kp.ToPublic().Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := sk.Text()
+	chunks := strings.Split(text, ":")
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 fields, got %q", text)
+	}
+
+	// Valid text loads.
+	loaded, err := LoadKeyFromText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sk, loaded)
+
+	// Transcription error in key data is detected.
+	keyData := []byte(chunks[2])
+	if keyData[5] == 'a' {
+		keyData[5] = 'b'
+	} else {
+		keyData[5] = 'a'
+	}
+	chunks[2] = string(keyData)
+	_, err = LoadKeyFromText(strings.Join(chunks, ":"))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	// Legacy text without checksum is still accepted.
+	legacy := strings.Join(strings.Split(text, ":")[:3], ":")
+	loaded, err = LoadKeyFromText(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sk, loaded)
+}
+
+func TestStoredKey_URLText(t *testing.T) {
+	t.Parallel()
+
+	for _, kpType := range AllKeyPairTypes() {
+		kp, err := kpType.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sk, err := kp.Export()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Both text encodings decode to the same stored key.
+		text := sk.URLText()
+		assert.NotContains(t, text, "=")
+		fromURLText, err := LoadKeyFromURLText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromText, err := LoadKeyFromText(sk.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, sk, fromURLText)
+		assert.Equal(t, fromText, fromURLText)
+	}
+
+	// Checksum is required and verified.
+	sk := &StoredKey{Type: "test", IsPrivate: true, Key: []byte("some key data")}
+	chunks := strings.Split(sk.URLText(), ":")
+	_, err := LoadKeyFromURLText(strings.Join(chunks[:3], ":"))
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	chunks[3] = "AAAAAA"
+	_, err = LoadKeyFromURLText(strings.Join(chunks, ":"))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestStoredKey_Burn(t *testing.T) {
+	t.Parallel()
+
+	for _, kpType := range AllKeyPairTypes() {
+		t.Run(string(kpType), func(t *testing.T) {
+			kp, err := kpType.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Burning an export must not affect the key pair.
+			exported, err := kp.Export()
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyData := exported.Key
+			exported.Burn()
+			assert.Equal(t, make([]byte, len(keyData)), keyData, "key data must be zeroed")
+			assert.Equal(t, &StoredKey{}, exported)
+
+			// Burning the stored key after loading must not affect the loaded key pair.
+			exported, err = kp.Export()
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadKeyPair(exported)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exported.Burn()
+
+			sig, err := loaded.Sign(signTestData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := kp.Verify(signTestData, sig); err != nil {
+				t.Fatalf("key pair damaged by burning stored key: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoredKey_Secret(t *testing.T) {
+	t.Parallel()
+
+	secret := NewSecret(32)
+	stored, err := ExportSecret(secret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, StoredKeyTypeSecret, stored.Type)
+	assert.True(t, stored.IsPrivate)
+
+	// Round trip through all formats.
+	data, err := stored.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBytes, err := LoadKeyFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromText, err := LoadKeyFromText(stored.Text())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sk := range []*StoredKey{fromBytes, fromText} {
+		loaded, err := LoadSecret(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, secret, loaded)
+	}
+
+	// Custom type.
+	stored, err = ExportSecret(secret, "session-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "session-key", stored.Type)
+
+	// Stored secret does not share memory.
+	stored.Burn()
+	assert.NotEqual(t, make([]byte, len(secret)), secret)
+
+	// Invalid inputs.
+	_, err = ExportSecret(nil, "")
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	_, err = ExportSecret(secret, "ed25519")
+	assert.Error(t, err)
+
+	kp, err := NewKeyPair(KeyPairTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpStored, err := kp.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadSecret(kpStored)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	_, err = LoadSecret(&StoredKey{Type: StoredKeyTypeSecret, Key: secret})
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestStoredKey_Algorithm(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, KeyPairTypeEd25519, (&StoredKey{Type: "ed25519"}).Algorithm())
+	assert.Equal(t, KeyPairTypeMLDSA65, (&StoredKey{Type: "ML-DSA-65"}).Algorithm())
+	assert.Equal(t, KeyPairType(""), (&StoredKey{Type: StoredKeyTypeSecret}).Algorithm())
+}
+
+func TestStoredKey_CanonicalEncoding(t *testing.T) {
+	t.Parallel()
+
+	// Golden vectors of the SHA2-256 digest of the exported keys, using a
+	// seed of repeated 0x42 bytes. These must never change.
+	golden := []struct {
+		kpType   KeyPairType
+		pubSize  int
+		pubHash  string
+		privSize int
+		privHash string
+	}{
+		{
+			kpType:   KeyPairTypeEd25519,
+			pubSize:  32,
+			pubHash:  "3097e2dee2cb4a34b53840cdb705aed71067c36f68db0e0f559c3f3fa043315f",
+			privSize: 64,
+			privHash: "cfaed16beb0725df3e19f5d171be7ae89aa5da058a9022af564566bb28d31a30",
+		},
+		{
+			kpType:   KeyPairTypeEd448,
+			pubSize:  57,
+			pubHash:  "123cb7c9d38e5a5c5f539148f2fc47d468c7f9f7ea5db2275a71e97d6ba8b033",
+			privSize: 114,
+			privHash: "e08c10f73665d26e88c3ca594be394db968195899a5937ded1f9675e8f59f30d",
+		},
+		{
+			kpType:   KeyPairTypeECDSAP256,
+			pubSize:  65,
+			pubHash:  "8964233423574cbc50a15c713208ecb85f8c6a62e319bba9680978380dd67859",
+			privSize: 32,
+			privHash: "425ed4e4a36b30ea21b90e21c712c649e8214c29b7eaf68089d1039c6e55384c",
+		},
+		{
+			kpType:   KeyPairTypeMLDSA65,
+			pubSize:  1952,
+			pubHash:  "2f40048b7202cf1d33e0af88f0695e076d00fea5be3d201d667021afe09c23c1",
+			privSize: 4032,
+			privHash: "412b87e56d529ed474e03738ea6622adb8dfa7ef6eca52f737cf3290bdb9810b",
+		},
+	}
+	assert.Len(t, golden, len(AllKeyPairTypes()), "every key pair type needs a golden vector")
+
+	for _, g := range golden {
+		t.Run(string(g.kpType), func(t *testing.T) {
+			kp, err := g.kpType.NewFromSeed(bytes.Repeat([]byte{0x42}, g.kpType.SeedSize()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, export := range []func() (*StoredKey, error){kp.Export, kp.ToPublic().Export} {
+				stored, err := export()
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, g.kpType, stored.Algorithm())
+
+				// Check encoding.
+				if stored.IsPrivate {
+					assert.Len(t, stored.Key, g.privSize)
+					assert.Equal(t, g.privHash, hex.EncodeToString(SHA2_256.Digest(stored.Key)))
+				} else {
+					assert.Len(t, stored.Key, g.pubSize)
+					assert.Equal(t, g.pubHash, hex.EncodeToString(SHA2_256.Digest(stored.Key)))
+				}
+
+				// Reloading and exporting again yields the same encoding.
+				loaded, err := LoadKeyPair(stored)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reexported, err := loaded.Export()
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, stored, reexported)
+			}
+		})
+	}
+}
+
+func TestLoadKeyFromBytesStrict(t *testing.T) {
+	t.Parallel()
+
+	for _, kpType := range AllKeyPairTypes() {
+		kp, err := kpType.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, export := range []func() (*StoredKey, error){kp.Export, kp.ToPublic().Export} {
+			stored, err := export()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Valid key loads.
+			data, err := stored.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadKeyFromBytesStrict(data)
+			if assert.NoError(t, err, kpType) {
+				assert.Equal(t, stored, loaded)
+			}
+
+			// Truncated key is rejected by strict, but not by permissive loading.
+			data, err = (&StoredKey{
+				Type:      stored.Type,
+				IsPrivate: stored.IsPrivate,
+				Key:       stored.Key[:3],
+			}).Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = LoadKeyFromBytesStrict(data)
+			assert.ErrorIs(t, err, ErrInvalidFormat, kpType)
+			_, err = LoadKeyFromBytes(data)
+			assert.NoError(t, err, kpType)
+		}
+	}
+
+	// Unknown types pass through.
+	data, err := (&StoredKey{Type: "custom", Key: []byte{1, 2, 3}}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadKeyFromBytesStrict(data)
+	assert.NoError(t, err)
+}
+
+func TestStoredKey_BytesVersion(t *testing.T) {
+	t.Parallel()
+
+	sk := &StoredKey{Type: "test", IsPrivate: true, Key: []byte("some key data")}
+	data, err := sk.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, storedKeyFormatV1, data[0])
+
+	// Current format loads.
+	loaded, err := LoadKeyFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sk, loaded)
+
+	// Legacy format without version is still accepted.
+	loaded, err = LoadKeyFromBytes(data[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sk, loaded)
+
+	// Unknown versions are rejected.
+	unknown := append([]byte{0x02}, data[1:]...)
+	_, err = LoadKeyFromBytes(unknown)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	_, err = LoadKeyFromBytes(nil)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
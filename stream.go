@@ -0,0 +1,60 @@
+package crop
+
+import (
+	"sync"
+)
+
+// StreamVerifier verifies a stream of sequenced records and reports gaps in
+// the sequence numbers.
+// Sequence checkers silently advance past missing sequence numbers, so this
+// wrapper helps to detect dropped messages.
+type StreamVerifier struct {
+	handler MsgAuthCodeHandler
+	onGap   func(missingFrom, missingTo uint64)
+
+	lock    sync.Mutex
+	lastSeq uint64
+}
+
+// NewStreamVerifier returns a new StreamVerifier that verifies records with
+// the given handler and calls onGap with the (inclusive) range of missing
+// sequence numbers whenever a gap is detected.
+func NewStreamVerifier(handler MsgAuthCodeHandler, onGap func(missingFrom, missingTo uint64)) *StreamVerifier {
+	return &StreamVerifier{
+		handler: handler,
+		onGap:   onGap,
+	}
+}
+
+// Verify checks that the MAC is valid for the record and reports any skipped
+// sequence numbers before the record's sequence number.
+func (sv *StreamVerifier) Verify(context string, data []byte, mac []byte) error {
+	// Verify record first, sequence number is only trusted afterwards.
+	seqNum, err := sv.handler.VerifyWithSeq(context, data, mac)
+	if err != nil {
+		return err
+	}
+
+	// Update highest sequence number and check for gap.
+	sv.lock.Lock()
+	gapFrom := sv.lastSeq + 1
+	hasGap := seqNum > gapFrom
+	if seqNum > sv.lastSeq {
+		sv.lastSeq = seqNum
+	}
+	sv.lock.Unlock()
+
+	// Report gap outside of lock.
+	if hasGap && sv.onGap != nil {
+		sv.onGap(gapFrom, seqNum-1)
+	}
+	return nil
+}
+
+// LastSequence returns the highest verified sequence number.
+func (sv *StreamVerifier) LastSequence() uint64 {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+
+	return sv.lastSeq
+}
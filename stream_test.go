@@ -0,0 +1,59 @@
+package crop
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreamVerifier_ReportsGap(t *testing.T) {
+	t.Parallel()
+
+	aKey := NewSecret(32)
+	bKey := NewSecret(32)
+	signer, err := NewAuthCodeHandler(MsgAuthCodeTypeHMACBlake3, aKey, bKey, NewStrictSequenceChecker())
+	if err != nil {
+		t.Fatalf("create signer: %v", err)
+	}
+	verifier, err := NewAuthCodeHandler(MsgAuthCodeTypeHMACBlake3, bKey, aKey, NewStrictSequenceChecker())
+	if err != nil {
+		t.Fatalf("create verifier: %v", err)
+	}
+
+	type gap struct{ from, to uint64 }
+	var gaps []gap
+	sv := NewStreamVerifier(verifier, func(from, to uint64) {
+		gaps = append(gaps, gap{from, to})
+	})
+
+	// Sign 6 records, but drop records 3 and 4.
+	for i := uint64(1); i <= 6; i++ {
+		data := []byte{byte(i)}
+		mac := signer.Sign("log", data)
+		if i == 3 || i == 4 {
+			continue
+		}
+		if err := sv.Verify("log", data, mac); err != nil {
+			t.Fatalf("verify record %d: %v", i, err)
+		}
+	}
+
+	if len(gaps) != 1 {
+		t.Fatalf("expected 1 gap, got %d: %v", len(gaps), gaps)
+	}
+	if gaps[0].from != 3 || gaps[0].to != 4 {
+		t.Fatalf("gap = %d-%d, want 3-4", gaps[0].from, gaps[0].to)
+	}
+	if sv.LastSequence() != 6 {
+		t.Fatalf("LastSequence = %d, want 6", sv.LastSequence())
+	}
+
+	// Invalid records are rejected and do not affect gap tracking.
+	mac := signer.Sign("log", []byte("good"))
+	err = sv.Verify("log", []byte("bad"), mac)
+	if !errors.Is(err, ErrAuthCodeInvalid) {
+		t.Fatalf("expected ErrAuthCodeInvalid, got: %v", err)
+	}
+	if len(gaps) != 1 || sv.LastSequence() != 6 {
+		t.Fatalf("invalid record changed stream state")
+	}
+}
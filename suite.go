@@ -1,12 +1,19 @@
 package crop
 
-// Default is the default cryptographic suite using X25519, BLAKE3, Ed25519, context hashing, and HMAC-BLAKE3.
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Default is the default cryptographic suite using X25519, BLAKE3, Ed25519, context hashing, HMAC-BLAKE3 and ChaCha20-Poly1305.
 var Default = Suite{
 	keyExchange: KeyExchangeTypeX25519,
 	keyMaker:    KeyMakerTypeBlake3,
 	keyPair:     KeyPairTypeEd25519,
 	challenge:   ChallengeTypeContextHashBl3,
 	msgAuthCode: MsgAuthCodeTypeHMACBlake3,
+	aead:        AEADTypeChaCha20Poly1305,
 }
 
 // Suite defines a collection of cryptographic algorithms to be used together.
@@ -16,6 +23,207 @@ type Suite struct {
 	keyPair     KeyPairType
 	challenge   ChallengeType
 	msgAuthCode MsgAuthCodeType
+	aead        AEADType
+}
+
+// SuiteOption configures a Suite created with NewSuite.
+type SuiteOption func(*Suite)
+
+// NewSuite returns a new suite based on the Default suite, modified by the
+// given options. The resulting suite is validated before it is returned.
+func NewSuite(opts ...SuiteOption) (Suite, error) {
+	s := Default
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if err := s.Validate(); err != nil {
+		return Suite{}, err
+	}
+	return s, nil
+}
+
+// WithKeyExchange sets the key exchange algorithm type of the suite.
+func WithKeyExchange(kxt KeyExchangeType) SuiteOption {
+	return func(s *Suite) {
+		s.keyExchange = kxt
+	}
+}
+
+// WithKeyMaker sets the key derivation algorithm type of the suite.
+func WithKeyMaker(kmt KeyMakerType) SuiteOption {
+	return func(s *Suite) {
+		s.keyMaker = kmt
+	}
+}
+
+// WithKeyPair sets the key pair algorithm type of the suite.
+func WithKeyPair(kpt KeyPairType) SuiteOption {
+	return func(s *Suite) {
+		s.keyPair = kpt
+	}
+}
+
+// WithChallenge sets the challenge algorithm type of the suite.
+func WithChallenge(ct ChallengeType) SuiteOption {
+	return func(s *Suite) {
+		s.challenge = ct
+	}
+}
+
+// WithMsgAuthCode sets the message authentication code algorithm type of the suite.
+func WithMsgAuthCode(act MsgAuthCodeType) SuiteOption {
+	return func(s *Suite) {
+		s.msgAuthCode = act
+	}
+}
+
+// WithAEAD sets the authenticated encryption algorithm type of the suite.
+func WithAEAD(at AEADType) SuiteOption {
+	return func(s *Suite) {
+		s.aead = at
+	}
+}
+
+// Validate checks whether all algorithm types of the suite are valid and
+// whether they are compatible with each other.
+func (s Suite) Validate() error {
+	switch {
+	case !s.keyExchange.IsValid():
+		return fmt.Errorf("%w: key exchange type %q is invalid", ErrInvalidSuite, s.keyExchange)
+	case !s.keyMaker.IsValid():
+		return fmt.Errorf("%w: key maker type %q is invalid", ErrInvalidSuite, s.keyMaker)
+	case !s.keyPair.IsValid():
+		return fmt.Errorf("%w: key pair type %q is invalid", ErrInvalidSuite, s.keyPair)
+	case !s.challenge.IsValid():
+		return fmt.Errorf("%w: challenge type %q is invalid", ErrInvalidSuite, s.challenge)
+	case !s.msgAuthCode.IsValid():
+		return fmt.Errorf("%w: auth code type %q is invalid", ErrInvalidSuite, s.msgAuthCode)
+	case !s.aead.IsValid():
+		return fmt.Errorf("%w: AEAD type %q is invalid", ErrInvalidSuite, s.aead)
+	case !s.keyExchange.SupportsKeyMaker(s.keyMaker):
+		return fmt.Errorf("%w: key exchange type %q does not support key maker type %q", ErrInvalidSuite, s.keyExchange, s.keyMaker)
+	}
+	return nil
+}
+
+// String returns the suite in its compact text format, listing the key
+// exchange, key maker, key pair, challenge, MAC and AEAD types separated by colons.
+func (s Suite) String() string {
+	return strings.Join([]string{
+		s.keyExchange.String(),
+		s.keyMaker.String(),
+		s.keyPair.String(),
+		s.challenge.String(),
+		s.msgAuthCode.String(),
+		s.aead.String(),
+	}, ":")
+}
+
+// ParseSuite parses a suite from its compact text format.
+// The AEAD type may be omitted for compatibility with the earlier format, in
+// which case the AEAD type of the Default suite is used.
+func ParseSuite(text string) (Suite, error) {
+	// Split into components.
+	chunks := strings.Split(text, ":")
+	switch len(chunks) {
+	case 5:
+		chunks = append(chunks, Default.aead.String())
+	case 6:
+	default:
+		return Suite{}, fmt.Errorf("%w: expected 6 suite components, got %d", ErrInvalidFormat, len(chunks))
+	}
+
+	// Check each component.
+	s := Suite{
+		keyExchange: KeyExchangeType(chunks[0]),
+		keyMaker:    KeyMakerType(chunks[1]),
+		keyPair:     KeyPairType(chunks[2]),
+		challenge:   ChallengeType(chunks[3]),
+		msgAuthCode: MsgAuthCodeType(chunks[4]),
+		aead:        AEADType(chunks[5]),
+	}
+	switch {
+	case !s.keyExchange.IsValid():
+		return Suite{}, fmt.Errorf("%w: unknown key exchange type %q", ErrInvalidFormat, chunks[0])
+	case !s.keyMaker.IsValid():
+		return Suite{}, fmt.Errorf("%w: unknown key maker type %q", ErrInvalidFormat, chunks[1])
+	case !s.keyPair.IsValid():
+		return Suite{}, fmt.Errorf("%w: unknown key pair type %q", ErrInvalidFormat, chunks[2])
+	case !s.challenge.IsValid():
+		return Suite{}, fmt.Errorf("%w: unknown challenge type %q", ErrInvalidFormat, chunks[3])
+	case !s.msgAuthCode.IsValid():
+		return Suite{}, fmt.Errorf("%w: unknown auth code type %q", ErrInvalidFormat, chunks[4])
+	case !s.aead.IsValid():
+		return Suite{}, fmt.Errorf("%w: unknown AEAD type %q", ErrInvalidFormat, chunks[5])
+	}
+
+	// Check combination.
+	if err := s.Validate(); err != nil {
+		return Suite{}, err
+	}
+	return s, nil
+}
+
+// suiteJSON is the JSON representation of a Suite.
+type suiteJSON struct {
+	KeyExchange string `json:"keyExchange"`
+	KeyMaker    string `json:"keyMaker"`
+	KeyPair     string `json:"keyPair"`
+	Challenge   string `json:"challenge"`
+	MsgAuthCode string `json:"msgAuthCode"`
+	AEAD        string `json:"aead,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Suite) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&suiteJSON{
+		KeyExchange: s.keyExchange.String(),
+		KeyMaker:    s.keyMaker.String(),
+		KeyPair:     s.keyPair.String(),
+		Challenge:   s.challenge.String(),
+		MsgAuthCode: s.msgAuthCode.String(),
+		AEAD:        s.aead.String(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// Unknown algorithm names are rejected with ErrInvalidFormat, naming the field.
+// The AEAD type may be omitted, in which case the AEAD type of the Default
+// suite is used.
+func (s *Suite) UnmarshalJSON(data []byte) error {
+	var sj suiteJSON
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+	if sj.AEAD == "" {
+		sj.AEAD = Default.aead.String()
+	}
+
+	// Check each component.
+	var parsed Suite
+	for _, field := range []struct {
+		name   string
+		value  string
+		target interface{ UnmarshalText(text []byte) error }
+	}{
+		{"keyExchange", sj.KeyExchange, &parsed.keyExchange},
+		{"keyMaker", sj.KeyMaker, &parsed.keyMaker},
+		{"keyPair", sj.KeyPair, &parsed.keyPair},
+		{"challenge", sj.Challenge, &parsed.challenge},
+		{"msgAuthCode", sj.MsgAuthCode, &parsed.msgAuthCode},
+		{"aead", sj.AEAD, &parsed.aead},
+	} {
+		if err := field.target.UnmarshalText([]byte(field.value)); err != nil {
+			return fmt.Errorf("suite field %s: %w", field.name, err)
+		}
+	}
+
+	// Check combination.
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
 }
 
 // KeyExchangeType returns the key exchange algorithm type for this suite.
@@ -32,3 +240,28 @@ func (s Suite) KeyMakerType() KeyMakerType {
 func (s Suite) KeyPairType() KeyPairType {
 	return s.keyPair
 }
+
+// ChallengeType returns the challenge algorithm type for this suite.
+func (s Suite) ChallengeType() ChallengeType {
+	return s.challenge
+}
+
+// MsgAuthCodeType returns the message authentication code algorithm type for this suite.
+func (s Suite) MsgAuthCodeType() MsgAuthCodeType {
+	return s.msgAuthCode
+}
+
+// AEADType returns the authenticated encryption algorithm type for this suite.
+func (s Suite) AEADType() AEADType {
+	return s.aead
+}
+
+// IsFIPSCompliant returns whether all algorithms of this suite are FIPS-approved.
+func (s Suite) IsFIPSCompliant() bool {
+	return s.keyExchange.IsFIPSApproved() &&
+		s.keyMaker.IsFIPSApproved() &&
+		s.keyPair.IsFIPSApproved() &&
+		s.challenge.IsFIPSApproved() &&
+		s.msgAuthCode.IsFIPSApproved() &&
+		s.aead.IsFIPSApproved()
+}
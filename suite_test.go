@@ -0,0 +1,349 @@
+package crop
+
+import (
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSuite_IsFIPSCompliant(t *testing.T) {
+	t.Parallel()
+
+	// Currently supported non-hash algorithms are not FIPS-approved.
+	if KeyExchangeTypeX25519.IsFIPSApproved() {
+		t.Errorf("expected %s to not be FIPS-approved", KeyExchangeTypeX25519)
+	}
+	if KeyMakerTypeBlake3.IsFIPSApproved() {
+		t.Errorf("expected %s to not be FIPS-approved", KeyMakerTypeBlake3)
+	}
+	if KeyPairTypeEd25519.IsFIPSApproved() {
+		t.Errorf("expected %s to not be FIPS-approved", KeyPairTypeEd25519)
+	}
+	if ChallengeTypeContextHashBl3.IsFIPSApproved() {
+		t.Errorf("expected %s to not be FIPS-approved", ChallengeTypeContextHashBl3)
+	}
+	if MsgAuthCodeTypeHMACBlake3.IsFIPSApproved() {
+		t.Errorf("expected %s to not be FIPS-approved", MsgAuthCodeTypeHMACBlake3)
+	}
+	if MsgAuthCodeTypeBlake3.IsFIPSApproved() {
+		t.Errorf("expected %s to not be FIPS-approved", MsgAuthCodeTypeBlake3)
+	}
+
+	// The BLAKE3-based default suite is not FIPS compliant.
+	if Default.IsFIPSCompliant() {
+		t.Fatalf("expected Default suite to not be FIPS compliant")
+	}
+}
+
+func TestSuite_Getters(t *testing.T) {
+	t.Parallel()
+
+	if Default.KeyExchangeType() != KeyExchangeTypeX25519 {
+		t.Errorf("KeyExchangeType() = %q", Default.KeyExchangeType())
+	}
+	if Default.KeyMakerType() != KeyMakerTypeBlake3 {
+		t.Errorf("KeyMakerType() = %q", Default.KeyMakerType())
+	}
+	if Default.KeyPairType() != KeyPairTypeEd25519 {
+		t.Errorf("KeyPairType() = %q", Default.KeyPairType())
+	}
+	if Default.ChallengeType() != ChallengeTypeContextHashBl3 {
+		t.Errorf("ChallengeType() = %q", Default.ChallengeType())
+	}
+	if Default.MsgAuthCodeType() != MsgAuthCodeTypeHMACBlake3 {
+		t.Errorf("MsgAuthCodeType() = %q", Default.MsgAuthCodeType())
+	}
+}
+
+func TestNewSuite(t *testing.T) {
+	t.Parallel()
+
+	// No options yields the default suite.
+	s, err := NewSuite()
+	if err != nil {
+		t.Fatalf("NewSuite() error: %v", err)
+	}
+	if s != Default {
+		t.Fatalf("NewSuite() = %+v, want Default", s)
+	}
+
+	// Swap only the MAC, keep the rest at default.
+	s, err = NewSuite(WithMsgAuthCode(MsgAuthCodeTypeBlake3))
+	if err != nil {
+		t.Fatalf("NewSuite(WithMsgAuthCode) error: %v", err)
+	}
+	if s.MsgAuthCodeType() != MsgAuthCodeTypeBlake3 {
+		t.Fatalf("MsgAuthCodeType() = %q, want %q", s.MsgAuthCodeType(), MsgAuthCodeTypeBlake3)
+	}
+	if s.KeyExchangeType() != Default.KeyExchangeType() ||
+		s.KeyMakerType() != Default.KeyMakerType() ||
+		s.KeyPairType() != Default.KeyPairType() ||
+		s.ChallengeType() != Default.ChallengeType() {
+		t.Fatalf("unexpected change of other algorithms: %+v", s)
+	}
+
+	// Invalid options are rejected.
+	invalid := []SuiteOption{
+		WithKeyExchange("invalid"),
+		WithKeyMaker("invalid"),
+		WithKeyPair("invalid"),
+		WithChallenge("invalid"),
+		WithMsgAuthCode("invalid"),
+		WithAEAD("invalid"),
+	}
+	for _, opt := range invalid {
+		if _, err := NewSuite(opt); err == nil {
+			t.Errorf("expected error for invalid option")
+		}
+	}
+}
+
+func TestSuite_Validate(t *testing.T) {
+	t.Parallel()
+
+	if err := Default.Validate(); err != nil {
+		t.Fatalf("Default.Validate() error: %v", err)
+	}
+
+	// Zero value suite is invalid.
+	err := Suite{}.Validate()
+	if !errors.Is(err, ErrInvalidSuite) {
+		t.Fatalf("expected ErrInvalidSuite for zero value suite, got: %v", err)
+	}
+
+	// Error names the offending field.
+	tests := map[string]SuiteOption{
+		"key exchange": WithKeyExchange("invalid"),
+		"key maker":    WithKeyMaker("invalid"),
+		"key pair":     WithKeyPair("invalid"),
+		"challenge":    WithChallenge("invalid"),
+		"auth code":    WithMsgAuthCode("invalid"),
+		"AEAD":         WithAEAD("invalid"),
+	}
+	for field, opt := range tests {
+		s := Default
+		opt(&s)
+		err := s.Validate()
+		if !errors.Is(err, ErrInvalidSuite) {
+			t.Errorf("%s: expected ErrInvalidSuite, got: %v", field, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("%s: error does not name field: %v", field, err)
+		}
+	}
+}
+
+func TestSuite_StringParse(t *testing.T) {
+	t.Parallel()
+
+	const defaultText = "X25519:BLAKE3:Ed25519:context-hash-bl3:HMAC-BLAKE3:ChaCha20-Poly1305"
+	if Default.String() != defaultText {
+		t.Fatalf("Default.String() = %q, want %q", Default.String(), defaultText)
+	}
+
+	// Earlier format without AEAD type.
+	legacy, err := ParseSuite("X25519:BLAKE3:Ed25519:context-hash-bl3:HMAC-BLAKE3")
+	if err != nil {
+		t.Fatalf("ParseSuite(legacy) error: %v", err)
+	}
+	if legacy != Default {
+		t.Fatalf("ParseSuite(legacy) = %q, want Default", legacy)
+	}
+
+	// Round trip.
+	custom, err := NewSuite(WithMsgAuthCode(MsgAuthCodeTypeBlake3))
+	if err != nil {
+		t.Fatalf("NewSuite error: %v", err)
+	}
+	for _, s := range []Suite{Default, custom} {
+		parsed, err := ParseSuite(s.String())
+		if err != nil {
+			t.Fatalf("ParseSuite(%q) error: %v", s, err)
+		}
+		if parsed != s {
+			t.Fatalf("ParseSuite(%q) = %q", s, parsed)
+		}
+	}
+
+	// Invalid input.
+	invalid := map[string]string{
+		"":                      "",
+		"X25519:BLAKE3":         "",
+		"X25519:BLAKE3:a:b:c:d": "",
+		"X448:BLAKE3:Ed25519:context-hash-bl3:HMAC-BLAKE3":       "X448",
+		"X25519:BLAKE3:Ed25519:context-hash-bl3:nope":            "nope",
+		"X25519:BLAKE3:Ed25519:context-hash-bl3:HMAC-BLAKE3:AES": "AES",
+	}
+	for text, segment := range invalid {
+		_, err := ParseSuite(text)
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("ParseSuite(%q): expected ErrInvalidFormat, got: %v", text, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), segment) {
+			t.Errorf("ParseSuite(%q): error does not name segment %q: %v", text, segment, err)
+		}
+	}
+}
+
+func TestAlgorithmTypes_TextMarshaling(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Hash        Hash            `json:"hash"`
+		KeyPair     KeyPairType     `json:"keyPair"`
+		KeyExchange KeyExchangeType `json:"keyExchange"`
+		KeyMaker    KeyMakerType    `json:"keyMaker"`
+		Challenge   ChallengeType   `json:"challenge"`
+		MsgAuthCode MsgAuthCodeType `json:"msgAuthCode"`
+		AEAD        AEADType        `json:"aead"`
+	}
+	cfg := config{
+		Hash:        SHA3_256,
+		KeyPair:     KeyPairTypeEd25519,
+		KeyExchange: KeyExchangeTypeX25519,
+		KeyMaker:    KeyMakerTypeBlake3,
+		Challenge:   ChallengeTypeContextHashBl3,
+		MsgAuthCode: MsgAuthCodeTypeHMACBlake3,
+		AEAD:        AEADTypeChaCha20Poly1305,
+	}
+
+	// Round trip.
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	var parsed config
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("unmarshal config: %v", err)
+	}
+	if parsed != cfg {
+		t.Fatalf("parsed config = %+v, want %+v", parsed, cfg)
+	}
+
+	// Unknown names are rejected with an error instead of a panic.
+	for _, field := range []string{"hash", "keyPair", "keyExchange", "keyMaker", "challenge", "msgAuthCode", "aead"} {
+		invalid := strings.Replace(string(data), `"`+field+`":"`, `"`+field+`":"nope`, 1)
+		err := json.Unmarshal([]byte(invalid), &parsed)
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: expected ErrInvalidFormat, got: %v", field, err)
+		}
+	}
+}
+
+func TestAlgorithmTypes_AllListsComplete(t *testing.T) {
+	t.Parallel()
+
+	// Collect all declared algorithm constants from the package source.
+	declared := make(map[string][]string)
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatalf("parse %s: %v", file, err)
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec) //nolint:forcetypeassert
+				typeIdent, ok := vs.Type.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				for _, name := range vs.Names {
+					declared[typeIdent.Name] = append(declared[typeIdent.Name], name.Name)
+				}
+			}
+		}
+	}
+
+	// Every declared constant must be listed and valid, and vice versa.
+	check := func(typeName string, listed []string, isValid func(string) bool) {
+		t.Helper()
+
+		if len(listed) != len(declared[typeName]) {
+			t.Errorf("%s: %d listed, but %d declared: %v", typeName, len(listed), len(declared[typeName]), declared[typeName])
+		}
+		seen := make(map[string]bool)
+		for _, value := range listed {
+			if seen[value] {
+				t.Errorf("%s: %q listed twice", typeName, value)
+			}
+			seen[value] = true
+			if !isValid(value) {
+				t.Errorf("%s: listed %q is not valid", typeName, value)
+			}
+		}
+		if isValid("invalid") {
+			t.Errorf("%s: unknown value is valid", typeName)
+		}
+	}
+	check("Hash", toStrings(AllHashes()), func(v string) bool { return Hash(v).IsValid() })
+	check("KeyPairType", toStrings(AllKeyPairTypes()), func(v string) bool { return KeyPairType(v).IsValid() })
+	check("KeyExchangeType", toStrings(AllKeyExchangeTypes()), func(v string) bool { return KeyExchangeType(v).IsValid() })
+	check("KeyMakerType", toStrings(AllKeyMakerTypes()), func(v string) bool { return KeyMakerType(v).IsValid() })
+	check("ChallengeType", toStrings(AllChallengeTypes()), func(v string) bool { return ChallengeType(v).IsValid() })
+	check("MsgAuthCodeType", toStrings(AllMsgAuthCodeTypes()), func(v string) bool { return MsgAuthCodeType(v).IsValid() })
+	check("AEADType", toStrings(AllAEADTypes()), func(v string) bool { return AEADType(v).IsValid() })
+}
+
+func toStrings[T ~string](values []T) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return out
+}
+
+func TestSuite_JSON(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSuite(WithKeyExchange(KeyExchangeTypeMLKEM768), WithMsgAuthCode(MsgAuthCodeTypeBlake3))
+	if err != nil {
+		t.Fatalf("create suite: %v", err)
+	}
+
+	// Round trip.
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal suite: %v", err)
+	}
+	if !strings.Contains(string(data), `"keyExchange":"ML-KEM-768"`) {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+	var parsed Suite
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("unmarshal suite: %v", err)
+	}
+	if parsed != s {
+		t.Fatalf("parsed suite = %s, want %s", parsed, s)
+	}
+
+	// Unknown names are rejected and name the field.
+	for _, field := range []string{"keyExchange", "keyMaker", "keyPair", "challenge", "msgAuthCode", "aead"} {
+		invalid := strings.Replace(string(data), `"`+field+`":"`, `"`+field+`":"nope`, 1)
+		err := json.Unmarshal([]byte(invalid), &parsed)
+		if !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s: expected ErrInvalidFormat, got: %v", field, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), field) {
+			t.Errorf("%s: expected error to name the field, got: %v", field, err)
+		}
+	}
+}
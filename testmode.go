@@ -0,0 +1,39 @@
+//go:build crop_insecure_test_mode
+
+package crop
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+
+	"github.com/zeebo/blake3"
+)
+
+// EnableDeterministicTestMode replaces all randomness of this package with a
+// deterministic random bit generator seeded by the given seed.
+// The returned function restores the secure random source.
+// INSECURE: Only available with the crop_insecure_test_mode build tag and
+// must never be used outside of tests.
+func EnableDeterministicTestMode(seed []byte) (disable func()) {
+	hasher := blake3.NewDeriveKey("_crop deterministic test mode_")
+	_, _ = hasher.Write(seed) // Never returns an error.
+	randReader = &lockedReader{r: hasher.Digest()}
+
+	return func() {
+		randReader = rand.Reader
+	}
+}
+
+// lockedReader makes a reader safe for concurrent use.
+type lockedReader struct {
+	lock sync.Mutex
+	r    io.Reader
+}
+
+func (lr *lockedReader) Read(p []byte) (int, error) {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+
+	return lr.r.Read(p)
+}
@@ -0,0 +1,11 @@
+//go:build !crop_insecure_test_mode
+
+package crop
+
+// EnableDeterministicTestMode replaces all randomness of this package with a
+// deterministic random bit generator seeded by the given seed.
+// INSECURE: Only available with the crop_insecure_test_mode build tag.
+// Without the build tag, this function always panics.
+func EnableDeterministicTestMode(seed []byte) (disable func()) {
+	panic("crop: refusing to enable insecure deterministic test mode without the crop_insecure_test_mode build tag")
+}
@@ -0,0 +1,16 @@
+//go:build !crop_insecure_test_mode
+
+package crop
+
+import "testing"
+
+func TestDeterministicTestMode_RefusesWithoutBuildTag(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected EnableDeterministicTestMode to panic without build tag")
+		}
+	}()
+	EnableDeterministicTestMode([]byte("seed"))
+}
@@ -0,0 +1,101 @@
+//go:build crop_insecure_test_mode
+
+package crop
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeterministicTestMode_HandshakeReproducible(t *testing.T) {
+	// Note: Must not run in parallel, as it replaces the package random source.
+
+	seed := []byte("fixed test seed")
+	run := func() [][]byte {
+		disable := EnableDeterministicTestMode(seed)
+		defer disable()
+
+		return defaultSuiteHandshake(t)
+	}
+
+	first := run()
+	second := run()
+	if len(first) != len(second) {
+		t.Fatalf("transcript length differs: %d vs %d", len(first), len(second))
+	}
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Fatalf("transcript entry %d differs\n1: %x\n2: %x", i, first[i], second[i])
+		}
+	}
+
+	// Secure random source is restored.
+	if bytes.Equal(defaultSuiteHandshake(t)[0], first[0]) {
+		t.Fatalf("expected random output after disabling test mode")
+	}
+}
+
+// defaultSuiteHandshake runs a full handshake with the default suite and
+// returns all exchanged messages.
+func defaultSuiteHandshake(t *testing.T) (transcript [][]byte) {
+	t.Helper()
+
+	// Identity keys.
+	identity, err := Default.KeyPairType().New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Key exchange.
+	clientKX, err := Default.KeyExchangeType().New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKX, err := Default.KeyExchangeType().New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientMsg, err := clientKX.ExchangeMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverMsg, err := serverKX.ExchangeMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := identity.Sign(serverMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript = append(transcript, clientMsg, serverMsg, sig)
+
+	// Key derivation.
+	clientKM, err := clientKX.MakeKeys(serverMsg, Default.KeyMakerType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendKey, err := clientKM.DeriveKey("mac", "client", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recvKey, err := clientKM.DeriveKey("mac", "server", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Challenge.
+	challenge, err := Default.ChallengeType().New("test", "client", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript = append(transcript, challenge.GetChallenge())
+
+	// Authenticated message.
+	mac, err := Default.MsgAuthCodeType().New(sendKey, recvKey, NewStrictSequenceChecker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript = append(transcript, mac.Sign("msg", []byte("hello")))
+
+	return transcript
+}